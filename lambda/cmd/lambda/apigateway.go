@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// fromRESTAPI converts an API Gateway REST (v1) proxy request. API Gateway
+// decodes query strings where ALB passes them through as sent, so values are
+// escaped again for the handler to decode.
+func fromRESTAPI(request events.APIGatewayProxyRequest) events.ALBTargetGroupRequest {
+	query := make(map[string][]string, len(request.MultiValueQueryStringParameters))
+	for name, values := range request.MultiValueQueryStringParameters {
+		for _, value := range values {
+			query[name] = append(query[name], url.QueryEscape(value))
+		}
+	}
+	for name, value := range request.QueryStringParameters {
+		if _, ok := query[name]; !ok {
+			query[name] = []string{url.QueryEscape(value)}
+		}
+	}
+
+	return events.ALBTargetGroupRequest{
+		HTTPMethod:                      request.HTTPMethod,
+		Path:                            request.Path,
+		Headers:                         request.Headers,
+		MultiValueHeaders:               request.MultiValueHeaders,
+		MultiValueQueryStringParameters: query,
+		Body:                            request.Body,
+		IsBase64Encoded:                 request.IsBase64Encoded,
+	}
+}
+
+// fromHTTPAPI converts an HTTP API (v2) or Function URL request. On a named
+// stage the raw path starts with the stage, which the handler's routes do not
+// expect.
+func fromHTTPAPI(request events.APIGatewayV2HTTPRequest) events.ALBTargetGroupRequest {
+	path := request.RawPath
+	if stage := request.RequestContext.Stage; stage != "" && stage != "$default" {
+		if rest, ok := strings.CutPrefix(path, "/"+stage); ok && (rest == "" || strings.HasPrefix(rest, "/")) {
+			path = rest
+			if path == "" {
+				path = "/"
+			}
+		}
+	}
+
+	headers := make(map[string]string, len(request.Headers)+1)
+	for name, value := range request.Headers {
+		headers[name] = value
+	}
+	if len(request.Cookies) > 0 {
+		headers["cookie"] = strings.Join(request.Cookies, "; ")
+	}
+
+	return events.ALBTargetGroupRequest{
+		HTTPMethod:                      request.RequestContext.HTTP.Method,
+		Path:                            path,
+		Headers:                         headers,
+		MultiValueQueryStringParameters: splitRawQuery(request.RawQueryString),
+		Body:                            request.Body,
+		IsBase64Encoded:                 request.IsBase64Encoded,
+	}
+}
+
+// splitRawQuery splits a query string without decoding it, matching what ALB
+// hands the function.
+func splitRawQuery(raw string) map[string][]string {
+	query := make(map[string][]string)
+	for _, pair := range strings.Split(raw, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		query[name] = append(query[name], value)
+	}
+	return query
+}
+
+// flattenHeaders merges multi-value headers into a single-value map, for
+// integrations that only accept one value per header.
+func flattenHeaders(response events.ALBTargetGroupResponse) map[string]string {
+	headers := make(map[string]string, len(response.Headers)+len(response.MultiValueHeaders))
+	for name, value := range response.Headers {
+		headers[name] = value
+	}
+	for name, values := range response.MultiValueHeaders {
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+func toRESTAPI(response events.ALBTargetGroupResponse) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{
+		StatusCode:        response.StatusCode,
+		Headers:           response.Headers,
+		MultiValueHeaders: response.MultiValueHeaders,
+		Body:              response.Body,
+		IsBase64Encoded:   response.IsBase64Encoded,
+	}
+}
+
+func toHTTPAPI(response events.ALBTargetGroupResponse) events.APIGatewayV2HTTPResponse {
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode:      response.StatusCode,
+		Headers:         flattenHeaders(response),
+		Body:            response.Body,
+		IsBase64Encoded: response.IsBase64Encoded,
+	}
+}
+
+func toFunctionURL(response events.ALBTargetGroupResponse) events.LambdaFunctionURLResponse {
+	return events.LambdaFunctionURLResponse{
+		StatusCode:      response.StatusCode,
+		Headers:         flattenHeaders(response),
+		Body:            response.Body,
+		IsBase64Encoded: response.IsBase64Encoded,
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestFromRESTAPI(t *testing.T) {
+	request := fromRESTAPI(events.APIGatewayProxyRequest{
+		HTTPMethod:                      http.MethodGet,
+		Path:                            "/rbacpolicy/check",
+		Headers:                         map[string]string{"X-Amzn-Oidc-Data": "token"},
+		QueryStringParameters:           map[string]string{"role_id": "a b", "action": "read"},
+		MultiValueQueryStringParameters: map[string][]string{"role_id": {"a&b", "a b"}},
+	})
+
+	want := map[string][]string{
+		"role_id": {"a%26b", "a+b"},
+		"action":  {"read"},
+	}
+	if !reflect.DeepEqual(request.MultiValueQueryStringParameters, want) {
+		t.Errorf("Expected escaped query %v, got %v", want, request.MultiValueQueryStringParameters)
+	}
+	if request.Headers["X-Amzn-Oidc-Data"] != "token" {
+		t.Errorf("Expected headers to be passed through, got %v", request.Headers)
+	}
+}
+
+func TestFromHTTPAPI(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawPath  string
+		stage    string
+		wantPath string
+	}{
+		{name: "Default stage", rawPath: "/rbacpolicy", stage: "$default", wantPath: "/rbacpolicy"},
+		{name: "Named stage", rawPath: "/prod/rbacpolicy", stage: "prod", wantPath: "/rbacpolicy"},
+		{name: "Stage root", rawPath: "/prod", stage: "prod", wantPath: "/"},
+		{name: "Stage is only a prefix of the first segment", rawPath: "/production/rbacpolicy", stage: "prod", wantPath: "/production/rbacpolicy"},
+		{name: "No stage", rawPath: "/rbacpolicy", wantPath: "/rbacpolicy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request events.APIGatewayV2HTTPRequest
+			request.RawPath = tt.rawPath
+			request.RequestContext.Stage = tt.stage
+			if got := fromHTTPAPI(request).Path; got != tt.wantPath {
+				t.Errorf("Expected path %q, got %q", tt.wantPath, got)
+			}
+		})
+	}
+
+	var request events.APIGatewayV2HTTPRequest
+	request.RequestContext.HTTP.Method = http.MethodGet
+	request.RawQueryString = "role_id=a%26b&role_id=c&flag&="
+	request.Headers = map[string]string{"x-forwarded-for": "203.0.113.7"}
+	request.Cookies = []string{"a=1", "b=2"}
+
+	got := fromHTTPAPI(request)
+	if got.HTTPMethod != http.MethodGet {
+		t.Errorf("Expected method from the request context, got %q", got.HTTPMethod)
+	}
+	wantQuery := map[string][]string{"role_id": {"a%26b", "c"}, "flag": {""}, "": {""}}
+	if !reflect.DeepEqual(got.MultiValueQueryStringParameters, wantQuery) {
+		t.Errorf("Expected raw query %v, got %v", wantQuery, got.MultiValueQueryStringParameters)
+	}
+	wantHeaders := map[string]string{"x-forwarded-for": "203.0.113.7", "cookie": "a=1; b=2"}
+	if !reflect.DeepEqual(got.Headers, wantHeaders) {
+		t.Errorf("Expected headers %v, got %v", wantHeaders, got.Headers)
+	}
+}
+
+func TestResponseConversion(t *testing.T) {
+	response := events.ALBTargetGroupResponse{
+		StatusCode:        http.StatusOK,
+		Headers:           map[string]string{"Content-Type": "application/json"},
+		MultiValueHeaders: map[string][]string{"Vary": {"Accept", "Origin"}},
+		Body:              `{"ok":true}`,
+	}
+	flat := map[string]string{"Content-Type": "application/json", "Vary": "Accept, Origin"}
+
+	rest := toRESTAPI(response)
+	if rest.StatusCode != http.StatusOK || rest.Body != response.Body || !reflect.DeepEqual(rest.MultiValueHeaders, response.MultiValueHeaders) {
+		t.Errorf("Unexpected REST API response %+v", rest)
+	}
+
+	v2 := toHTTPAPI(response)
+	if v2.StatusCode != http.StatusOK || v2.Body != response.Body || !reflect.DeepEqual(v2.Headers, flat) {
+		t.Errorf("Unexpected HTTP API response %+v", v2)
+	}
+
+	fnURL := toFunctionURL(response)
+	if fnURL.StatusCode != http.StatusOK || fnURL.Body != response.Body || !reflect.DeepEqual(fnURL.Headers, flat) {
+		t.Errorf("Unexpected Function URL response %+v", fnURL)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// directRequest is the payload for invoking the function directly, e.g. with
+// `aws lambda invoke`. The body is embedded as JSON rather than as an escaped
+// string; a JSON string body is passed through as its contents.
+type directRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   map[string]string `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// directResponse is returned to direct invocations, with a JSON body embedded
+// as-is.
+type directResponse struct {
+	StatusCode int               `json:"status_code"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       json.RawMessage   `json:"body,omitempty"`
+}
+
+func (r directRequest) toALB() events.ALBTargetGroupRequest {
+	query := make(map[string]string, len(r.Query))
+	for name, value := range r.Query {
+		query[name] = url.QueryEscape(value)
+	}
+
+	body := string(r.Body)
+	var s string
+	if err := json.Unmarshal(r.Body, &s); err == nil {
+		body = s
+	}
+
+	return events.ALBTargetGroupRequest{
+		HTTPMethod:            r.Method,
+		Path:                  r.Path,
+		Headers:               r.Headers,
+		QueryStringParameters: query,
+		Body:                  body,
+	}
+}
+
+func toDirect(response events.ALBTargetGroupResponse) directResponse {
+	result := directResponse{
+		StatusCode: response.StatusCode,
+		Headers:    flattenHeaders(response),
+	}
+	switch {
+	case response.Body == "":
+	case json.Valid([]byte(response.Body)):
+		result.Body = json.RawMessage(response.Body)
+	default:
+		result.Body, _ = json.Marshal(response.Body)
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestDirectRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		wantBody string
+	}{
+		{name: "Object body", payload: `{"method": "PUT", "path": "/rbacpolicy", "body": {"custom_roles": []}}`, wantBody: `{"custom_roles": []}`},
+		{name: "String body", payload: `{"method": "PUT", "path": "/rbacpolicy", "body": "{\"custom_roles\":[]}"}`, wantBody: `{"custom_roles":[]}`},
+		{name: "Null body", payload: `{"method": "GET", "path": "/rbacpolicy", "body": null}`, wantBody: ""},
+		{name: "No body", payload: `{"method": "GET", "path": "/rbacpolicy"}`, wantBody: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var direct directRequest
+			if err := json.Unmarshal([]byte(tt.payload), &direct); err != nil {
+				t.Fatalf("Failed to parse payload: %v", err)
+			}
+			if got := direct.toALB().Body; got != tt.wantBody {
+				t.Errorf("Expected body %q, got %q", tt.wantBody, got)
+			}
+		})
+	}
+
+	request := directRequest{Method: http.MethodGet, Path: "/rbacpolicy/check", Query: map[string]string{"role_id": "a&b"}}.toALB()
+	if got := request.QueryStringParameters["role_id"]; got != "a%26b" {
+		t.Errorf("Expected escaped query value, got %q", got)
+	}
+}
+
+func TestToDirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		wantJSON string
+	}{
+		{name: "JSON body", body: `{"ok":true}`, wantJSON: `{"status_code":200,"headers":{"Content-Type":"application/json"},"body":{"ok":true}}`},
+		{name: "Text body", body: "plain", wantJSON: `{"status_code":200,"headers":{"Content-Type":"application/json"},"body":"plain"}`},
+		{name: "Empty body", body: "", wantJSON: `{"status_code":200,"headers":{"Content-Type":"application/json"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := toDirect(events.ALBTargetGroupResponse{
+				StatusCode: http.StatusOK,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Body:       tt.body,
+			})
+			got, err := json.Marshal(response)
+			if err != nil {
+				t.Fatalf("Failed to marshal response: %v", err)
+			}
+			if string(got) != tt.wantJSON {
+				t.Errorf("Expected %s, got %s", tt.wantJSON, got)
+			}
+		})
+	}
+}
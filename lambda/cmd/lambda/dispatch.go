@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/handler"
+)
+
+// scheduledEventType is the detail-type EventBridge gives schedule events.
+const scheduledEventType = "Scheduled Event"
+
+// eventSource identifies which integration delivered a Lambda payload.
+type eventSource string
+
+const (
+	sourceALB         eventSource = "alb"
+	sourceRESTAPI     eventSource = "apigateway_rest"
+	sourceHTTPAPI     eventSource = "apigateway_http"
+	sourceFunctionURL eventSource = "function_url"
+	sourceScheduled   eventSource = "scheduled"
+	sourceDirect      eventSource = "direct"
+)
+
+// errUnknownEvent is returned for payloads no integration produces.
+var errUnknownEvent = errors.New("unrecognized event payload")
+
+// eventHandler is what the Lambda entry point dispatches to.
+type eventHandler interface {
+	HandleRequest(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error)
+	HandleScheduledEvent(ctx context.Context, event events.EventBridgeEvent) (handler.DriftSummary, error)
+}
+
+// eventEnvelope holds just enough of a payload to tell the sources apart.
+type eventEnvelope struct {
+	Version        string `json:"version"`
+	DetailType     string `json:"detail-type"`
+	HTTPMethod     string `json:"httpMethod"`
+	Method         string `json:"method"`
+	RequestContext struct {
+		ELB        json.RawMessage `json:"elb"`
+		HTTP       json.RawMessage `json:"http"`
+		APIID      string          `json:"apiId"`
+		DomainName string          `json:"domainName"`
+	} `json:"requestContext"`
+}
+
+// detectSource works out which integration produced the payload.
+func detectSource(payload json.RawMessage) (eventSource, error) {
+	var envelope eventEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return "", fmt.Errorf("failed to parse event: %w", err)
+	}
+
+	rc := envelope.RequestContext
+	switch {
+	case envelope.DetailType == scheduledEventType:
+		return sourceScheduled, nil
+	case rc.ELB != nil:
+		return sourceALB, nil
+	case envelope.Version == "2.0" && rc.HTTP != nil:
+		// Function URLs use the HTTP API payload format; only the domain differs.
+		if strings.Contains(rc.DomainName, ".lambda-url.") {
+			return sourceFunctionURL, nil
+		}
+		return sourceHTTPAPI, nil
+	case envelope.HTTPMethod != "" && rc.APIID != "":
+		return sourceRESTAPI, nil
+	case envelope.HTTPMethod != "":
+		// ALB-shaped payloads without a request context come from callers
+		// invoking the function with a hand-built ALB event.
+		return sourceALB, nil
+	case envelope.Method != "":
+		return sourceDirect, nil
+	default:
+		return "", errUnknownEvent
+	}
+}
+
+// dispatch returns the Lambda entry point. EventBridge scheduled events run
+// the drift check. Every HTTP-shaped payload, whether from an ALB, API
+// Gateway, a Function URL or a direct invocation, is normalized to the ALB
+// request the handler works on, and the handler's response is converted back
+// to the shape the integration expects.
+func dispatch(h eventHandler) func(context.Context, json.RawMessage) (any, error) {
+	return func(ctx context.Context, payload json.RawMessage) (any, error) {
+		source, err := detectSource(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if source == sourceScheduled {
+			var event events.EventBridgeEvent
+			if err := json.Unmarshal(payload, &event); err != nil {
+				return nil, fmt.Errorf("failed to parse scheduled event: %w", err)
+			}
+			return h.HandleScheduledEvent(ctx, event)
+		}
+
+		request, err := normalizeRequest(source, payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := decodeBody(&request); err != nil {
+			return nil, fmt.Errorf("failed to decode %s request body: %w", source, err)
+		}
+
+		response, err := h.HandleRequest(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		return convertResponse(source, response), nil
+	}
+}
+
+// normalizeRequest parses an HTTP-shaped payload into an ALB request.
+func normalizeRequest(source eventSource, payload json.RawMessage) (events.ALBTargetGroupRequest, error) {
+	switch source {
+	case sourceALB:
+		var request events.ALBTargetGroupRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return request, fmt.Errorf("failed to parse ALB request: %w", err)
+		}
+		return request, nil
+	case sourceRESTAPI:
+		var request events.APIGatewayProxyRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return events.ALBTargetGroupRequest{}, fmt.Errorf("failed to parse API Gateway REST request: %w", err)
+		}
+		return fromRESTAPI(request), nil
+	case sourceHTTPAPI, sourceFunctionURL:
+		var request events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return events.ALBTargetGroupRequest{}, fmt.Errorf("failed to parse %s request: %w", source, err)
+		}
+		return fromHTTPAPI(request), nil
+	case sourceDirect:
+		var request directRequest
+		if err := json.Unmarshal(payload, &request); err != nil {
+			return events.ALBTargetGroupRequest{}, fmt.Errorf("failed to parse direct invocation: %w", err)
+		}
+		return request.toALB(), nil
+	default:
+		return events.ALBTargetGroupRequest{}, errUnknownEvent
+	}
+}
+
+// convertResponse shapes the handler's response for the integration that
+// delivered the request.
+func convertResponse(source eventSource, response events.ALBTargetGroupResponse) any {
+	switch source {
+	case sourceRESTAPI:
+		return toRESTAPI(response)
+	case sourceHTTPAPI:
+		return toHTTPAPI(response)
+	case sourceFunctionURL:
+		return toFunctionURL(response)
+	case sourceDirect:
+		return toDirect(response)
+	default:
+		return response
+	}
+}
+
+// decodeBody replaces a base64 encoded request body with its decoded form, so
+// the handler always sees the raw body.
+func decodeBody(request *events.ALBTargetGroupRequest) error {
+	if !request.IsBase64Encoded {
+		return nil
+	}
+	body, err := base64.StdEncoding.DecodeString(request.Body)
+	if err != nil {
+		return err
+	}
+	request.Body = string(body)
+	request.IsBase64Encoded = false
+	return nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/handler"
+)
+
+// recordingHandler records which entry point an event reached.
+type recordingHandler struct {
+	request  *events.ALBTargetGroupRequest
+	event    *events.EventBridgeEvent
+	response events.ALBTargetGroupResponse
+	err      error
+}
+
+func (r *recordingHandler) HandleRequest(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	r.request = &request
+	if r.response.StatusCode == 0 {
+		return events.ALBTargetGroupResponse{StatusCode: http.StatusOK}, r.err
+	}
+	return r.response, r.err
+}
+
+func (r *recordingHandler) HandleScheduledEvent(ctx context.Context, event events.EventBridgeEvent) (handler.DriftSummary, error) {
+	r.event = &event
+	return handler.DriftSummary{Reports: []handler.DriftReport{{ProjectID: "project-live"}}}, r.err
+}
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		payload       string
+		wantRequest   bool
+		wantScheduled bool
+		wantErr       bool
+	}{
+		{
+			name:        "ALB request",
+			payload:     `{"requestContext": {"elb": {"targetGroupArn": "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/rbac/abc"}}, "httpMethod": "GET", "path": "/rbacpolicy", "headers": {"host": "rbac.example.com"}}`,
+			wantRequest: true,
+		},
+		{
+			name:          "Scheduled event",
+			payload:       `{"version": "0", "id": "event-1", "detail-type": "Scheduled Event", "source": "aws.events", "resources": ["arn:aws:events:us-east-1:123456789012:rule/drift"], "detail": {"reconcile": true}}`,
+			wantScheduled: true,
+		},
+		{
+			name:    "Not JSON",
+			payload: `not json`,
+			wantErr: true,
+		},
+		{
+			name:    "Unrecognized payload",
+			payload: `{"hello": "world"}`,
+			wantErr: true,
+		},
+		{
+			name:    "Malformed ALB request",
+			payload: `{"httpMethod": 1}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &recordingHandler{}
+			result, err := dispatch(h)(context.Background(), json.RawMessage(tt.payload))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("dispatch() expected error but got none")
+				}
+				if h.request != nil || h.event != nil {
+					t.Error("dispatch() reached the handler for an invalid payload")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dispatch() unexpected error: %v", err)
+			}
+
+			if tt.wantRequest {
+				if h.request == nil || h.request.HTTPMethod != http.MethodGet || h.request.Path != "/rbacpolicy" {
+					t.Errorf("Expected GET /rbacpolicy to reach HandleRequest, got %+v", h.request)
+				}
+				if _, ok := result.(events.ALBTargetGroupResponse); !ok {
+					t.Errorf("Expected an ALB response, got %T", result)
+				}
+			}
+			if tt.wantScheduled {
+				if h.event == nil || len(h.event.Resources) != 1 || string(h.event.Detail) != `{"reconcile": true}` {
+					t.Errorf("Expected the scheduled event to reach HandleScheduledEvent, got %+v", h.event)
+				}
+				if _, ok := result.(handler.DriftSummary); !ok {
+					t.Errorf("Expected a drift summary, got %T", result)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchError(t *testing.T) {
+	h := &recordingHandler{err: errors.New("drift check failed")}
+
+	_, err := dispatch(h)(context.Background(), json.RawMessage(`{"detail-type": "Scheduled Event", "source": "aws.events"}`))
+	if err == nil || err.Error() != "drift check failed" {
+		t.Errorf("Expected the handler's error, got %v", err)
+	}
+}
+
+func TestDispatchSources(t *testing.T) {
+	tests := []struct {
+		name         string
+		payload      string
+		wantMethod   string
+		wantPath     string
+		wantDryRun   string
+		wantBody     string
+		wantResponse any
+	}{
+		{
+			name:         "ALB request",
+			payload:      `{"requestContext": {"elb": {"targetGroupArn": "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/rbac/abc"}}, "httpMethod": "PUT", "path": "/rbacpolicy/roles/editor", "queryStringParameters": {"dry_run": "true"}, "body": "{}"}`,
+			wantMethod:   http.MethodPut,
+			wantPath:     "/rbacpolicy/roles/editor",
+			wantDryRun:   "true",
+			wantBody:     "{}",
+			wantResponse: events.ALBTargetGroupResponse{},
+		},
+		{
+			name:         "ALB request with base64 body",
+			payload:      `{"requestContext": {"elb": {}}, "httpMethod": "PUT", "path": "/rbacpolicy/roles/editor", "body": "e30=", "isBase64Encoded": true}`,
+			wantMethod:   http.MethodPut,
+			wantPath:     "/rbacpolicy/roles/editor",
+			wantBody:     "{}",
+			wantResponse: events.ALBTargetGroupResponse{},
+		},
+		{
+			name:         "Hand-built ALB request",
+			payload:      `{"httpMethod": "GET", "path": "/rbacpolicy"}`,
+			wantMethod:   http.MethodGet,
+			wantPath:     "/rbacpolicy",
+			wantResponse: events.ALBTargetGroupResponse{},
+		},
+		{
+			name:         "API Gateway REST request",
+			payload:      `{"resource": "/{proxy+}", "path": "/rbacpolicy/roles/editor", "httpMethod": "PUT", "multiValueQueryStringParameters": {"dry_run": ["true"]}, "requestContext": {"apiId": "abc123", "stage": "prod"}, "body": "e30=", "isBase64Encoded": true}`,
+			wantMethod:   http.MethodPut,
+			wantPath:     "/rbacpolicy/roles/editor",
+			wantDryRun:   "true",
+			wantBody:     "{}",
+			wantResponse: events.APIGatewayProxyResponse{},
+		},
+		{
+			name:         "HTTP API request",
+			payload:      `{"version": "2.0", "rawPath": "/prod/rbacpolicy/roles/editor", "rawQueryString": "dry_run=true", "requestContext": {"apiId": "abc123", "domainName": "abc123.execute-api.us-east-1.amazonaws.com", "stage": "prod", "http": {"method": "PUT", "path": "/prod/rbacpolicy/roles/editor"}}, "body": "{}"}`,
+			wantMethod:   http.MethodPut,
+			wantPath:     "/rbacpolicy/roles/editor",
+			wantDryRun:   "true",
+			wantBody:     "{}",
+			wantResponse: events.APIGatewayV2HTTPResponse{},
+		},
+		{
+			name:         "Function URL request",
+			payload:      `{"version": "2.0", "rawPath": "/rbacpolicy", "requestContext": {"apiId": "urlid", "domainName": "urlid.lambda-url.us-east-1.on.aws", "stage": "$default", "http": {"method": "GET", "path": "/rbacpolicy"}}}`,
+			wantMethod:   http.MethodGet,
+			wantPath:     "/rbacpolicy",
+			wantResponse: events.LambdaFunctionURLResponse{},
+		},
+		{
+			name:         "Direct invocation",
+			payload:      `{"method": "PUT", "path": "/rbacpolicy/roles/editor", "query": {"dry_run": "true"}, "body": {}}`,
+			wantMethod:   http.MethodPut,
+			wantPath:     "/rbacpolicy/roles/editor",
+			wantDryRun:   "true",
+			wantBody:     "{}",
+			wantResponse: directResponse{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &recordingHandler{}
+			result, err := dispatch(h)(context.Background(), json.RawMessage(tt.payload))
+			if err != nil {
+				t.Fatalf("dispatch() unexpected error: %v", err)
+			}
+			if h.request == nil {
+				t.Fatal("Expected the request to reach HandleRequest")
+			}
+
+			if h.request.HTTPMethod != tt.wantMethod || h.request.Path != tt.wantPath {
+				t.Errorf("Expected %s %s, got %s %s", tt.wantMethod, tt.wantPath, h.request.HTTPMethod, h.request.Path)
+			}
+			if got := rawQueryParam(*h.request, "dry_run"); got != tt.wantDryRun {
+				t.Errorf("Expected dry_run %q, got %q", tt.wantDryRun, got)
+			}
+			if h.request.Body != tt.wantBody || h.request.IsBase64Encoded {
+				t.Errorf("Expected decoded body %q, got %q (base64 %v)", tt.wantBody, h.request.Body, h.request.IsBase64Encoded)
+			}
+			if got, want := fmt.Sprintf("%T", result), fmt.Sprintf("%T", tt.wantResponse); got != want {
+				t.Errorf("Expected a %s response, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestDispatchInvalidBody(t *testing.T) {
+	h := &recordingHandler{}
+	_, err := dispatch(h)(context.Background(), json.RawMessage(`{"requestContext": {"elb": {}}, "httpMethod": "PUT", "path": "/rbacpolicy", "body": "not base64!", "isBase64Encoded": true}`))
+	if err == nil {
+		t.Error("dispatch() expected error for an undecodable body")
+	}
+	if h.request != nil {
+		t.Error("dispatch() reached the handler with an undecodable body")
+	}
+}
+
+// rawQueryParam returns a query parameter as the handler receives it from
+// either query map.
+func rawQueryParam(request events.ALBTargetGroupRequest, name string) string {
+	if value, ok := request.QueryStringParameters[name]; ok {
+		return value
+	}
+	if values := request.MultiValueQueryStringParameters[name]; len(values) > 0 {
+		return values[len(values)-1]
+	}
+	return ""
+}
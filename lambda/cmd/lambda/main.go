@@ -2,13 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/app"
 	"github.com/srnext/stytch-rbacpolicy-lambda/internal/config"
 	"github.com/srnext/stytch-rbacpolicy-lambda/internal/handler"
-	"github.com/stytchauth/stytch-management-go/v2/pkg/api"
 	"go.uber.org/zap"
 )
 
@@ -29,21 +30,36 @@ func main() {
 		logger.Fatal("Failed to load configuration", zap.Error(err))
 	}
 
-	logger.Info("Initializing Stytch client", 
-		zap.String("project_id", cfg.ProjectID),
-		zap.String("workspace_key_id", cfg.WorkspaceKeyID))
-	
-	client := api.NewClient(cfg.WorkspaceKeyID, cfg.WorkspaceKeySecret)
+	ctx := context.Background()
+	h, closeHandler, err := initHandler(ctx, cfg, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize handler", zap.Error(err))
+	}
+	defer closeHandler()
+
+	lambda.StartWithContext(ctx, dispatch(h))
+}
 
-	h := handler.NewHandler(client.RBACPolicy, cfg.ProjectID, logger)
+// initHandler builds the handler the Lambda dispatches to.
+func initHandler(ctx context.Context, cfg *config.Config, logger *zap.Logger) (*handler.Handler, func(), error) {
+	if cfg.FakeStytch {
+		return nil, nil, errors.New("STYTCH_FAKE is only supported by the local server")
+	}
 
-	lambda.StartWithContext(context.Background(), h.HandleRequest)
+	var opts []handler.Option
+	if isProduction() {
+		opts = append(opts, handler.WithHideInternalErrors())
+	}
+	return app.NewHandler(ctx, cfg, app.NewStytchClient(cfg, logger), logger, opts...)
 }
 
 func initLogger() (*zap.Logger, error) {
-	env := os.Getenv("ENVIRONMENT")
-	if env == "production" {
+	if isProduction() {
 		return zap.NewProduction()
 	}
 	return zap.NewDevelopment()
 }
+
+func isProduction() bool {
+	return os.Getenv("ENVIRONMENT") == "production"
+}
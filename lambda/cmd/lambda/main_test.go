@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/config"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient/stytchtest"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
 	"go.uber.org/zap"
 )
 
@@ -56,3 +64,98 @@ func TestInitLogger(t *testing.T) {
 		})
 	}
 }
+
+// albEvent returns an ALB request payload as Lambda delivers it.
+func albEvent(t *testing.T, method, path, body string) json.RawMessage {
+	t.Helper()
+	payload, err := json.Marshal(events.ALBTargetGroupRequest{
+		HTTPMethod:     method,
+		Path:           path,
+		Headers:        map[string]string{"x-forwarded-for": "203.0.113.7"},
+		Body:           body,
+		RequestContext: events.ALBTargetGroupRequestContext{ELB: events.ELBContext{TargetGroupArn: "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/rbac/abc"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal event: %v", err)
+	}
+	return payload
+}
+
+func TestInitHandlerRejectsFakeStytch(t *testing.T) {
+	if _, _, err := initHandler(context.Background(), &config.Config{ProjectID: "project-test", FakeStytch: true}, zap.NewNop()); err == nil {
+		t.Error("initHandler() expected error with STYTCH_FAKE set")
+	}
+}
+
+// TestLambdaAgainstStytchServer runs events through the Lambda's wiring and
+// the real Stytch management client, against a local stand-in for the
+// management API.
+func TestLambdaAgainstStytchServer(t *testing.T) {
+	server := stytchtest.NewServer(stytchtest.NewClient("project-test"), "workspace-key", "workspace-secret")
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		secret         string
+		inject         error
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Get policy", secret: "workspace-secret", method: http.MethodGet, path: "/rbacpolicy", expectedStatus: http.StatusOK, expectedBody: `"stytch_admin"`},
+		{name: "Create resource", secret: "workspace-secret", method: http.MethodPut, path: "/rbacpolicy/resources/documents", body: `{"available_actions": ["read"]}`, expectedStatus: http.StatusCreated, expectedBody: `"documents"`},
+		{name: "Unknown project", secret: "workspace-secret", method: http.MethodGet, path: "/rbacpolicy/projects/live", expectedStatus: http.StatusNotFound},
+		{name: "Wrong workspace secret", secret: "wrong", method: http.MethodGet, path: "/rbacpolicy", expectedStatus: http.StatusUnauthorized, expectedBody: string(stytchtest.ErrorTypeUnauthorized)},
+		{
+			name:           "Stytch error details",
+			secret:         "workspace-secret",
+			inject:         stytcherror.Error{StatusCode: http.StatusConflict, RequestID: "request-id-conflict", ErrorType: "policy_conflict", ErrorMessage: "Policy was modified."},
+			method:         http.MethodGet,
+			path:           "/rbacpolicy",
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "request-id-conflict",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				WorkspaceKeyID:     "workspace-key",
+				WorkspaceKeySecret: tt.secret,
+				ProjectID:          "project-test",
+				StytchBaseURI:      server.URL,
+				Projects:           map[string]string{"live": "project-live", "project-live": "project-live"},
+			}
+			h, closeHandler, err := initHandler(context.Background(), cfg, zap.NewNop())
+			if err != nil {
+				t.Fatalf("initHandler() unexpected error: %v", err)
+			}
+			defer closeHandler()
+			if tt.inject != nil {
+				server.Client.FailNext(tt.inject)
+			}
+
+			result, err := dispatch(h)(context.Background(), albEvent(t, tt.method, tt.path, tt.body))
+			if err != nil {
+				t.Fatalf("dispatch() unexpected error: %v", err)
+			}
+			response, ok := result.(events.ALBTargetGroupResponse)
+			if !ok {
+				t.Fatalf("Expected an ALB response, got %T", result)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			if !strings.Contains(response.Body, tt.expectedBody) {
+				t.Errorf("Expected body to contain %s, got %s", tt.expectedBody, response.Body)
+			}
+		})
+	}
+
+	stored, _ := server.Client.Policy("project-test")
+	if len(stored.CustomResources) != 1 || stored.CustomResources[0].ResourceID != "documents" {
+		t.Errorf("Expected the resource to be written through to the server, got %+v", stored.CustomResources)
+	}
+}
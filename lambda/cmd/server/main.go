@@ -0,0 +1,114 @@
+// Command server serves the RBAC policy API over plain HTTP for local
+// development. It reads the same environment variables as the Lambda.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/app"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/config"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient/stytchtest"
+	"go.uber.org/zap"
+)
+
+// shutdownTimeout bounds how long in-flight requests may run after an
+// interrupt.
+const shutdownTimeout = 5 * time.Second
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	flag.Parse()
+
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	h, closeHandler, err := app.NewHandler(ctx, cfg, initStytchClient(cfg, logger), logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize handler", zap.Error(err))
+	}
+	defer closeHandler()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		logger.Fatal("Failed to listen", zap.String("addr", *addr), zap.Error(err))
+	}
+	logger.Info("Serving RBAC policy API", zap.String("url", "http://"+listener.Addr().String()+"/rbacpolicy"))
+
+	if err := serve(ctx, listener, h, logger); err != nil {
+		logger.Fatal("Server failed", zap.Error(err))
+	}
+}
+
+// initStytchClient returns the Stytch management API client, or an in-memory
+// fake serving every configured project when cfg.FakeStytch is set.
+func initStytchClient(cfg *config.Config, logger *zap.Logger) stytchclient.Client {
+	if !cfg.FakeStytch {
+		return app.NewStytchClient(cfg, logger)
+	}
+
+	projectIDs := []string{cfg.ProjectID}
+	for _, projectID := range cfg.Projects {
+		if !slices.Contains(projectIDs, projectID) {
+			projectIDs = append(projectIDs, projectID)
+		}
+	}
+	logger.Warn("STYTCH_FAKE set; serving policies from memory instead of Stytch",
+		zap.Strings("project_ids", projectIDs))
+	return stytchtest.NewClient(projectIDs...)
+}
+
+// serve handles requests on listener until ctx is done, then waits up to
+// shutdownTimeout for in-flight requests to finish.
+func serve(ctx context.Context, listener net.Listener, h http.Handler, logger *zap.Logger) error {
+	server := &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/app"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/config"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient/stytchtest"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func TestServe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	})
+	go func() {
+		done <- serve(ctx, listener, h, zap.NewNop())
+	}()
+
+	resp, err := http.Get("http://" + listener.Addr().String() + "/rbacpolicy")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "/rbacpolicy" {
+		t.Errorf("Expected the handler's response, got %q", body)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("serve() unexpected error: %v", err)
+		}
+	case <-time.After(shutdownTimeout):
+		t.Fatal("serve() did not return after the context was cancelled")
+	}
+}
+
+func TestServeListenerClosed(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	listener.Close()
+
+	if err := serve(context.Background(), listener, http.NotFoundHandler(), zap.NewNop()); err == nil {
+		t.Error("serve() expected error for a closed listener")
+	}
+}
+
+func TestInitStytchClient(t *testing.T) {
+	cfg := &config.Config{
+		ProjectID:  "project-test",
+		Projects:   map[string]string{"live": "project-live", "project-live": "project-live"},
+		FakeStytch: true,
+	}
+	fake, ok := initStytchClient(cfg, zap.NewNop()).(*stytchtest.Client)
+	if !ok {
+		t.Fatalf("Expected *stytchtest.Client with STYTCH_FAKE, got %T", initStytchClient(cfg, zap.NewNop()))
+	}
+	for _, projectID := range []string{"project-test", "project-live"} {
+		if _, err := fake.Get(context.Background(), rbacpolicy.GetRequest{ProjectID: projectID}); err != nil {
+			t.Errorf("Expected the fake to serve %s, got %v", projectID, err)
+		}
+	}
+
+	live := initStytchClient(&config.Config{WorkspaceKeyID: "key", WorkspaceKeySecret: "secret", ProjectID: "project-test"}, zap.NewNop())
+	if _, ok := live.(*stytchtest.Client); ok {
+		t.Error("Expected the Stytch client without STYTCH_FAKE")
+	}
+}
+
+func TestHandlerWithFakeStytch(t *testing.T) {
+	cfg := &config.Config{ProjectID: "project-test", FakeStytch: true}
+	h, closeHandler, err := app.NewHandler(context.Background(), cfg, initStytchClient(cfg, zap.NewNop()), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewHandler() unexpected error: %v", err)
+	}
+	defer closeHandler()
+
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/rbacpolicy/roles/stytch_admin/effective", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected 200 from the fake, got %d: %s", recorder.Code, recorder.Body)
+	}
+}
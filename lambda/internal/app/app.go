@@ -0,0 +1,175 @@
+// Package app builds a Handler from configuration. It is shared by the Lambda
+// entry point and the local development server.
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/audit"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/authz"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/config"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/desired"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/handler"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/api"
+	"go.uber.org/zap"
+)
+
+// NewHandler builds a Handler from cfg that manages policies through client,
+// usually the one NewStytchClient returns. The returned close function
+// releases the audit log file, if any, and must be called once the handler is
+// no longer in use. opts are applied after the ones derived from cfg.
+func NewHandler(ctx context.Context, cfg *config.Config, client stytchclient.Client, logger *zap.Logger, opts ...handler.Option) (*handler.Handler, func(), error) {
+	// The breaker sits outside the retrier so that one request exhausting its
+	// retries counts as a single failure.
+	breaker := stytchclient.NewBreaker(stytchclient.NewRetrier(client, logger), logger)
+
+	historyStore, err := initHistoryStore(ctx, cfg, logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize history store: %w", err)
+	}
+
+	base := []handler.Option{
+		handler.WithHistoryStore(historyStore),
+		handler.WithCircuitBreaker(breaker),
+		handler.WithCacheTTL(cfg.PolicyCacheTTL),
+	}
+	if len(cfg.Projects) > 0 {
+		base = append(base, handler.WithProjects(cfg.Projects))
+	}
+	if authenticator := initAuthenticator(cfg, logger); authenticator != nil {
+		base = append(base, handler.WithAuthenticator(authenticator))
+	}
+
+	authorizer, err := initAuthorizer(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load authorization config: %w", err)
+	}
+	if authorizer != nil {
+		base = append(base, handler.WithAuthorizer(authorizer))
+	}
+
+	desiredSource, err := initDesiredSource(ctx, cfg, logger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize desired policy source: %w", err)
+	}
+	if desiredSource != nil {
+		base = append(base, handler.WithDesiredPolicySource(desiredSource))
+	}
+	if cfg.DriftAutoReconcile {
+		base = append(base, handler.WithDriftAutoReconcile())
+	}
+
+	closeFn := func() {}
+	if cfg.AuditLogFile != "" {
+		sink, err := audit.NewFileSink(cfg.AuditLogFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		closeFn = func() {
+			_ = sink.Close()
+		}
+		logger.Info("Writing audit records to file", zap.String("path", cfg.AuditLogFile))
+		base = append(base, handler.WithAuditSink(sink))
+	}
+
+	h := handler.NewHandler(breaker, cfg.ProjectID, logger, append(base, opts...)...)
+	return h, closeFn, nil
+}
+
+// NewStytchClient returns the Stytch management API client for cfg. It
+// ignores cfg.FakeStytch, which only the local server supports.
+func NewStytchClient(cfg *config.Config, logger *zap.Logger) stytchclient.Client {
+	logger.Info("Initializing Stytch client",
+		zap.String("project_id", cfg.ProjectID),
+		zap.String("workspace_key_id", cfg.WorkspaceKeyID))
+
+	opts := []api.APIOption{api.WithHTTPClient(&http.Client{Transport: stytchclient.NewTransport(nil)})}
+	if cfg.StytchBaseURI != "" {
+		logger.Info("Using Stytch management API endpoint", zap.String("base_uri", cfg.StytchBaseURI))
+		opts = append(opts, api.WithBaseURI(cfg.StytchBaseURI))
+	}
+	return api.NewClient(cfg.WorkspaceKeyID, cfg.WorkspaceKeySecret, opts...).RBACPolicy
+}
+
+func initHistoryStore(ctx context.Context, cfg *config.Config, logger *zap.Logger) (history.Store, error) {
+	switch {
+	case cfg.HistoryBucket != "":
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+		}
+		logger.Info("Storing policy snapshots in S3",
+			zap.String("bucket", cfg.HistoryBucket),
+			zap.String("prefix", cfg.HistoryPrefix))
+		return history.NewS3Store(s3.NewFromConfig(awsCfg), cfg.HistoryBucket, cfg.HistoryPrefix), nil
+	case cfg.HistoryDir != "":
+		logger.Info("Storing policy snapshots on disk", zap.String("dir", cfg.HistoryDir))
+		return history.NewFileStore(cfg.HistoryDir), nil
+	default:
+		logger.Warn("No history store configured; policy snapshots will not outlive this container")
+		return history.NewMemoryStore(), nil
+	}
+}
+
+// initDesiredSource returns nil when no desired policy source is configured,
+// which leaves drift detection off.
+func initDesiredSource(ctx context.Context, cfg *config.Config, logger *zap.Logger) (desired.Source, error) {
+	switch {
+	case cfg.DesiredPolicyBucket != "":
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+		}
+		logger.Info("Reading desired policies from S3",
+			zap.String("bucket", cfg.DesiredPolicyBucket),
+			zap.String("prefix", cfg.DesiredPolicyPrefix))
+		return desired.NewS3Source(s3.NewFromConfig(awsCfg), cfg.DesiredPolicyBucket, cfg.DesiredPolicyPrefix), nil
+	case cfg.DesiredPolicyDir != "":
+		logger.Info("Reading desired policies from disk", zap.String("dir", cfg.DesiredPolicyDir))
+		return desired.NewFileSource(cfg.DesiredPolicyDir), nil
+	default:
+		return nil, nil
+	}
+}
+
+// initAuthenticator returns nil when OIDC is not configured, leaving the API
+// open to anything that can reach the ALB rule.
+func initAuthenticator(cfg *config.Config, logger *zap.Logger) *auth.Verifier {
+	if cfg.OIDCIssuer == "" {
+		logger.Warn("OIDC_ISSUER not set; requests will not be authenticated")
+		return nil
+	}
+
+	logger.Info("Authenticating callers with ALB OIDC tokens",
+		zap.String("issuer", cfg.OIDCIssuer),
+		zap.String("key_endpoint", cfg.OIDCKeyEndpoint),
+		zap.String("signer", cfg.OIDCSignerARN))
+
+	return auth.NewVerifier(cfg.OIDCIssuer, auth.NewHTTPKeySource(cfg.OIDCKeyEndpoint, nil),
+		auth.WithSigner(cfg.OIDCSignerARN))
+}
+
+// initAuthorizer returns nil when no authorization config is set, in which
+// case any authenticated caller has full access.
+func initAuthorizer(cfg *config.Config) (*authz.Authorizer, error) {
+	data := []byte(cfg.AuthzConfig)
+	if cfg.AuthzConfigFile != "" {
+		var err error
+		data, err = os.ReadFile(cfg.AuthzConfigFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", cfg.AuthzConfigFile, err)
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return authz.Parse(data)
+}
@@ -0,0 +1,214 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/config"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/desired"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient/stytchtest"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func TestInitHistoryStore(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	tests := []struct {
+		name   string
+		config config.Config
+		check  func(t *testing.T, store history.Store)
+	}{
+		{
+			name:   "S3 bucket",
+			config: config.Config{HistoryBucket: "snapshots"},
+			check: func(t *testing.T, store history.Store) {
+				if _, ok := store.(*history.S3Store); !ok {
+					t.Errorf("Expected *history.S3Store, got %T", store)
+				}
+			},
+		},
+		{
+			name:   "Directory",
+			config: config.Config{HistoryDir: t.TempDir()},
+			check: func(t *testing.T, store history.Store) {
+				if _, ok := store.(*history.FileStore); !ok {
+					t.Errorf("Expected *history.FileStore, got %T", store)
+				}
+			},
+		},
+		{
+			name:   "Default",
+			config: config.Config{},
+			check: func(t *testing.T, store history.Store) {
+				if _, ok := store.(*history.MemoryStore); !ok {
+					t.Errorf("Expected *history.MemoryStore, got %T", store)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := initHistoryStore(context.Background(), &tt.config, zap.NewNop())
+			if err != nil {
+				t.Fatalf("initHistoryStore() unexpected error: %v", err)
+			}
+			tt.check(t, store)
+		})
+	}
+}
+
+func TestInitDesiredSource(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-east-1")
+
+	tests := []struct {
+		name   string
+		config config.Config
+		check  func(t *testing.T, source desired.Source)
+	}{
+		{
+			name:   "S3 bucket",
+			config: config.Config{DesiredPolicyBucket: "policies"},
+			check: func(t *testing.T, source desired.Source) {
+				if _, ok := source.(*desired.S3Source); !ok {
+					t.Errorf("Expected *desired.S3Source, got %T", source)
+				}
+			},
+		},
+		{
+			name:   "Directory",
+			config: config.Config{DesiredPolicyDir: t.TempDir()},
+			check: func(t *testing.T, source desired.Source) {
+				if _, ok := source.(*desired.FileSource); !ok {
+					t.Errorf("Expected *desired.FileSource, got %T", source)
+				}
+			},
+		},
+		{
+			name:   "Not configured",
+			config: config.Config{},
+			check: func(t *testing.T, source desired.Source) {
+				if source != nil {
+					t.Errorf("Expected no source, got %T", source)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := initDesiredSource(context.Background(), &tt.config, zap.NewNop())
+			if err != nil {
+				t.Fatalf("initDesiredSource() unexpected error: %v", err)
+			}
+			tt.check(t, source)
+		})
+	}
+}
+
+func TestInitAuthenticator(t *testing.T) {
+	if got := initAuthenticator(&config.Config{}, zap.NewNop()); got != nil {
+		t.Errorf("Expected no authenticator without OIDC_ISSUER, got %v", got)
+	}
+
+	cfg := &config.Config{
+		OIDCIssuer:      "https://idp.example.com",
+		OIDCKeyEndpoint: "https://public-keys.auth.elb.us-east-1.amazonaws.com",
+		OIDCSignerARN:   "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/rbac/abc",
+	}
+	if got := initAuthenticator(cfg, zap.NewNop()); got == nil {
+		t.Error("Expected authenticator when OIDC_ISSUER is set")
+	}
+}
+
+func TestInitAuthorizer(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "authz.json")
+	if err := os.WriteFile(file, []byte(`{"rules":[{"groups":["admin"],"capabilities":["read_policy"]}]}`), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		config  config.Config
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "Not configured", config: config.Config{}, wantNil: true},
+		{name: "Inline", config: config.Config{AuthzConfig: `{"rules":[]}`}},
+		{name: "From file", config: config.Config{AuthzConfigFile: file}},
+		{name: "Missing file", config: config.Config{AuthzConfigFile: filepath.Join(t.TempDir(), "missing.json")}, wantErr: true},
+		{name: "Invalid config", config: config.Config{AuthzConfig: `{"rules":[{"groups":["a"],"capabilities":["root"]}]}`}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authorizer, err := initAuthorizer(&tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("initAuthorizer() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("initAuthorizer() unexpected error: %v", err)
+			}
+			if (authorizer == nil) != tt.wantNil {
+				t.Errorf("initAuthorizer() = %v, wantNil %v", authorizer, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestNewHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  config.Config
+		wantErr bool
+	}{
+		{name: "Minimal", config: config.Config{WorkspaceKeyID: "key", WorkspaceKeySecret: "secret", ProjectID: "project-test"}},
+		{name: "Audit log file", config: config.Config{WorkspaceKeyID: "key", WorkspaceKeySecret: "secret", ProjectID: "project-test", AuditLogFile: filepath.Join(t.TempDir(), "audit.jsonl")}},
+		{name: "Unwritable audit log", config: config.Config{ProjectID: "project-test", AuditLogFile: filepath.Join(t.TempDir(), "missing", "audit.jsonl")}, wantErr: true},
+		{name: "Invalid authorization config", config: config.Config{ProjectID: "project-test", AuthzConfig: `{"rules":[{"groups":["a"],"capabilities":["root"]}]}`}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, closeHandler, err := NewHandler(context.Background(), &tt.config, stytchtest.NewClient("project-test"), zap.NewNop())
+			if tt.wantErr {
+				if err == nil {
+					t.Error("NewHandler() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewHandler() unexpected error: %v", err)
+			}
+			defer closeHandler()
+			if h == nil {
+				t.Error("NewHandler() returned nil handler")
+			}
+		})
+	}
+}
+
+func TestNewStytchClient(t *testing.T) {
+	server := stytchtest.NewServer(stytchtest.NewClient("project-test"), "workspace-key", "workspace-secret")
+	defer server.Close()
+
+	client := NewStytchClient(&config.Config{
+		WorkspaceKeyID:     "workspace-key",
+		WorkspaceKeySecret: "workspace-secret",
+		ProjectID:          "project-test",
+		StytchBaseURI:      server.URL,
+	}, zap.NewNop())
+	if _, ok := client.(*stytchtest.Client); ok {
+		t.Fatal("Expected the Stytch management API client, got the fake")
+	}
+	if _, err := client.Get(context.Background(), rbacpolicy.GetRequest{ProjectID: "project-test"}); err != nil {
+		t.Errorf("Expected the client to use STYTCH_BASE_URI, got %v", err)
+	}
+}
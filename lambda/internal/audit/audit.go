@@ -0,0 +1,66 @@
+// Package audit records who changed, or tried to change, an RBAC policy, when,
+// and how.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+)
+
+// Outcome is how a mutation attempt ended.
+type Outcome string
+
+const (
+	// OutcomeSuccess is a write Stytch stored.
+	OutcomeSuccess Outcome = "success"
+	// OutcomeDenied is a write the caller was not authorized to make.
+	OutcomeDenied Outcome = "denied"
+	// OutcomeInvalid is a write whose result failed validation.
+	OutcomeInvalid Outcome = "invalid"
+	// OutcomeStale is a write refused because its If-Match did not match.
+	OutcomeStale Outcome = "stale"
+	// OutcomeRejected is a write refused for any other problem with the
+	// request, such as a role that does not exist.
+	OutcomeRejected Outcome = "rejected"
+	// OutcomeFailed is a write that could not be completed, because Stytch or
+	// the snapshot store failed.
+	OutcomeFailed Outcome = "failed"
+)
+
+// Record describes one policy mutation attempt, whether or not it succeeded.
+type Record struct {
+	Time time.Time `json:"time"`
+	// RequestID is the Lambda request ID and TraceID the ALB trace header,
+	// for correlating with other logs.
+	RequestID string  `json:"request_id,omitempty"`
+	TraceID   string  `json:"trace_id,omitempty"`
+	ProjectID string  `json:"project_id"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Caller    Caller  `json:"caller"`
+	SourceIP  string  `json:"source_ip,omitempty"`
+	Outcome   Outcome `json:"outcome"`
+	// Error is the message the caller was given when the attempt did not
+	// succeed.
+	Error string `json:"error,omitempty"`
+	// BeforeHash is set once the live policy has been read, and AfterHash
+	// and Diff once the proposed policy is known. For a stored write they
+	// describe what Stytch stored; otherwise, what was attempted.
+	BeforeHash string     `json:"before_hash,omitempty"`
+	AfterHash  string     `json:"after_hash,omitempty"`
+	Diff       *rbac.Diff `json:"diff,omitempty"`
+}
+
+// Caller is the authenticated identity behind a mutation. It is empty when
+// authentication is disabled.
+type Caller struct {
+	Subject string `json:"sub,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+// Sink persists audit records.
+type Sink interface {
+	Write(ctx context.Context, record Record) error
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+)
+
+func TestRecordJSON(t *testing.T) {
+	record := Record{
+		Time:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		RequestID:  "req-1",
+		ProjectID:  "project-1",
+		Method:     "DELETE",
+		Path:       "/rbacpolicy/roles/viewer",
+		Caller:     Caller{Subject: "user-1", Email: "user@example.com"},
+		SourceIP:   "203.0.113.7",
+		Outcome:    OutcomeSuccess,
+		BeforeHash: "abc",
+		AfterHash:  "def",
+		Diff:       &rbac.Diff{RolesRemoved: []string{"viewer"}},
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, key := range []string{"time", "request_id", "project_id", "method", "path", "caller", "source_ip", "outcome", "before_hash", "after_hash", "diff"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("Expected %q in %s", key, data)
+		}
+	}
+	for _, key := range []string{"trace_id", "error"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("Expected empty %s to be omitted, got %s", key, data)
+		}
+	}
+	caller := fields["caller"].(map[string]any)
+	if caller["sub"] != "user-1" || caller["email"] != "user@example.com" {
+		t.Errorf("Unexpected caller %v", caller)
+	}
+}
+
+func TestFailedRecordJSON(t *testing.T) {
+	data, err := json.Marshal(Record{
+		ProjectID: "project-1",
+		Method:    "PUT",
+		Path:      "/rbacpolicy",
+		Outcome:   OutcomeFailed,
+		Error:     "Failed to get current RBAC policy",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if fields["outcome"] != "failed" || fields["error"] != "Failed to get current RBAC policy" {
+		t.Errorf("Unexpected outcome fields in %s", data)
+	}
+	for _, key := range []string{"before_hash", "after_hash", "diff"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("Expected %s to be omitted before the policy is read, got %s", key, data)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	// Records from separate sinks (e.g. container restarts) accumulate.
+	for _, projectID := range []string{"project-1", "project-2"} {
+		sink, err := NewFileSink(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := sink.Write(context.Background(), Record{ProjectID: projectID}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer file.Close()
+
+	var projects []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		projects = append(projects, record.ProjectID)
+	}
+	if len(projects) != 2 || projects[0] != "project-1" || projects[1] != "project-2" {
+		t.Errorf("Expected one line per record in order, got %v", projects)
+	}
+}
+
+func TestFileSinkErrors(t *testing.T) {
+	if _, err := NewFileSink(filepath.Join(t.TempDir(), "missing", "audit.jsonl")); err == nil {
+		t.Error("Expected error for missing directory")
+	}
+
+	sink, err := NewFileSink(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_ = sink.Close()
+	if err := sink.Write(context.Background(), Record{}); err == nil {
+		t.Error("Expected error writing to closed sink")
+	}
+}
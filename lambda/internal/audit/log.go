@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// LogSink writes each record as a structured log line, so records land
+// wherever the function's logs do (CloudWatch Logs on Lambda).
+type LogSink struct {
+	logger *zap.Logger
+}
+
+func NewLogSink(logger *zap.Logger) *LogSink {
+	return &LogSink{logger: logger}
+}
+
+func (s *LogSink) Write(ctx context.Context, record Record) error {
+	s.logger.Info("Audit record",
+		zap.Time("time", record.Time),
+		zap.String("request_id", record.RequestID),
+		zap.String("trace_id", record.TraceID),
+		zap.String("project_id", record.ProjectID),
+		zap.String("method", record.Method),
+		zap.String("path", record.Path),
+		zap.String("caller_sub", record.Caller.Subject),
+		zap.String("caller_email", record.Caller.Email),
+		zap.String("source_ip", record.SourceIP),
+		zap.String("outcome", string(record.Outcome)),
+		zap.String("error", record.Error),
+		zap.String("before_hash", record.BeforeHash),
+		zap.String("after_hash", record.AfterHash),
+		zap.Any("diff", record.Diff),
+	)
+	return nil
+}
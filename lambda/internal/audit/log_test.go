@@ -0,0 +1,37 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLogSink(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	sink := NewLogSink(zap.New(core))
+
+	err := sink.Write(context.Background(), Record{
+		ProjectID: "project-1",
+		Caller:    Caller{Subject: "user-1"},
+		Outcome:   OutcomeDenied,
+		Diff:      &rbac.Diff{RolesRemoved: []string{"viewer"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	entries := logs.FilterMessage("Audit record").All()
+	if len(entries) != 1 {
+		t.Fatalf("Expected one audit log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["caller_sub"] != "user-1" || fields["project_id"] != "project-1" || fields["outcome"] != "denied" {
+		t.Errorf("Unexpected fields %v", fields)
+	}
+	if _, ok := fields["diff"]; !ok {
+		t.Errorf("Expected diff field, got %v", fields)
+	}
+}
@@ -0,0 +1,25 @@
+package auth
+
+import "context"
+
+// Identity is the authenticated caller, taken from the claims of the ALB OIDC
+// token.
+type Identity struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+	// Claims holds every claim in the token, including Subject and Email.
+	Claims map[string]any `json:"-"`
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying identity.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity stored by WithIdentity, if any.
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
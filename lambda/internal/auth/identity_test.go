@@ -0,0 +1,22 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIdentityContext(t *testing.T) {
+	if _, ok := IdentityFromContext(context.Background()); ok {
+		t.Error("Expected no identity in empty context")
+	}
+
+	identity := &Identity{Subject: "user-1", Email: "user@example.com"}
+	got, ok := IdentityFromContext(WithIdentity(context.Background(), identity))
+	if !ok || got != identity {
+		t.Errorf("Expected stored identity, got %+v", got)
+	}
+
+	if _, ok := IdentityFromContext(WithIdentity(context.Background(), nil)); ok {
+		t.Error("Expected nil identity to be reported as missing")
+	}
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyFetchTimeout bounds a key fetch with the default client, so a slow key
+// endpoint cannot hold requests open for the rest of the Lambda timeout.
+const keyFetchTimeout = 5 * time.Second
+
+// KeySource resolves the public key an ALB used to sign a token.
+type KeySource interface {
+	PublicKey(ctx context.Context, kid string) (*ecdsa.PublicKey, error)
+}
+
+// HTTPKeySource fetches PEM-encoded keys from <endpoint>/<kid>, which is how
+// ALB publishes them (https://public-keys.auth.elb.<region>.amazonaws.com).
+// Keys never change for a given kid, so they are cached for the life of the
+// container. The kid comes from a token header that has not been verified
+// yet, so only ALB-style UUID kids are fetched; anything else is rejected
+// without a request, so junk tokens cannot each cost an outbound call.
+type HTTPKeySource struct {
+	endpoint string
+	client   *http.Client
+
+	mu   sync.RWMutex
+	keys map[string]*ecdsa.PublicKey
+}
+
+// NewHTTPKeySource returns a key source for endpoint. A nil client uses one
+// that gives up after keyFetchTimeout.
+func NewHTTPKeySource(endpoint string, client *http.Client) *HTTPKeySource {
+	if client == nil {
+		client = &http.Client{Timeout: keyFetchTimeout}
+	}
+	return &HTTPKeySource{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   client,
+		keys:     make(map[string]*ecdsa.PublicKey),
+	}
+}
+
+func (s *HTTPKeySource) PublicKey(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+	if !validKeyID(kid) {
+		return nil, fmt.Errorf("invalid kid %q: expected a UUID", kid)
+	}
+
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	s.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint+"/"+url.PathEscape(kid), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key %q: %w", kid, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch key %q: unexpected status %d", kid, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %q: %w", kid, err)
+	}
+
+	key, err = parsePublicKey(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key %q: %w", kid, err)
+	}
+
+	s.mu.Lock()
+	s.keys[kid] = key
+	s.mu.Unlock()
+	return key, nil
+}
+
+func parsePublicKey(body []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unexpected key type %T", pub)
+	}
+	return key, nil
+}
+
+// validKeyID reports whether kid is a UUID, the form ALB key IDs take.
+func validKeyID(kid string) bool {
+	if len(kid) != 36 {
+		return false
+	}
+	for i, c := range kid {
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+				return false
+			}
+		}
+	}
+	return true
+}
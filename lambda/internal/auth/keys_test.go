@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth/authtest"
+)
+
+func TestHTTPKeySource(t *testing.T) {
+	signer, err := authtest.NewSigner()
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	defer signer.Close()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		signer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	keys := NewHTTPKeySource(server.URL+"/", nil)
+	for i := 0; i < 2; i++ {
+		key, err := keys.PublicKey(context.Background(), authtest.KeyID)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !key.Equal(&signer.Key.PublicKey) {
+			t.Error("Expected signer's public key")
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected key to be fetched once, got %d requests", n)
+	}
+
+	if _, err := keys.PublicKey(context.Background(), "6f9c1e2d-3b4a-4c5d-8e7f-0a1b2c3d4e5f"); err == nil {
+		t.Error("Expected error for unknown kid")
+	}
+}
+
+func TestHTTPKeySourceInvalidKey(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "Not PEM", body: "not a key"},
+		{name: "Invalid DER", body: "-----BEGIN PUBLIC KEY-----\nAAAA\n-----END PUBLIC KEY-----\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			if _, err := NewHTTPKeySource(server.URL, nil).PublicKey(context.Background(), authtest.KeyID); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestHTTPKeySourceRejectsInvalidKeyID(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	keys := NewHTTPKeySource(server.URL, nil)
+	for _, kid := range []string{
+		"",
+		"test-key",
+		"../../latest/meta-data",
+		"0b1a4a6e-5c2d-4f3e-9a8b-7c6d5e4f3a2",
+		"0b1a4a6e-5c2d-4f3e-9a8b-7c6d5e4f3a2bc",
+		"0b1a4a6e_5c2d_4f3e_9a8b_7c6d5e4f3a2b",
+		"0b1a4a6e-5c2d-4f3e-9a8b-7c6d5e4f3a2g",
+	} {
+		if _, err := keys.PublicKey(context.Background(), kid); err == nil {
+			t.Errorf("PublicKey(%q) expected error but got none", kid)
+		}
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("Expected no key requests for invalid kids, got %d", n)
+	}
+}
+
+func TestNewHTTPKeySourceDefaultTimeout(t *testing.T) {
+	keys := NewHTTPKeySource("https://public-keys.auth.elb.us-east-1.amazonaws.com", nil)
+	if keys.client.Timeout != keyFetchTimeout {
+		t.Errorf("Default client timeout = %v, want %v", keys.client.Timeout, keyFetchTimeout)
+	}
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth/authtest"
+)
+
+func TestVerify(t *testing.T) {
+	signer, err := authtest.NewSigner()
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	defer signer.Close()
+
+	other, err := authtest.NewSigner()
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	defer other.Close()
+
+	expired := time.Now().Add(-time.Hour).Unix()
+	header := map[string]any{"alg": "ES256", "kid": authtest.KeyID, "signer": "arn:aws:elasticloadbalancing:alb"}
+	valid := signer.Token("user-1", map[string]any{"email": "user@example.com"})
+
+	tests := []struct {
+		name    string
+		token   string
+		opts    []VerifierOption
+		wantErr string
+	}{
+		{name: "Valid token", token: valid},
+		{name: "Expected signer", token: signer.Sign(header, map[string]any{"sub": "user-1", "iss": authtest.Issuer, "exp": time.Now().Add(time.Hour).Unix()}), opts: []VerifierOption{WithSigner("arn:aws:elasticloadbalancing:alb")}},
+		{name: "Unexpected signer", token: valid, opts: []VerifierOption{WithSigner("arn:aws:elasticloadbalancing:alb")}, wantErr: "unexpected signer"},
+		{name: "Malformed", token: "abc.def", wantErr: "malformed token"},
+		{name: "Invalid header", token: "!!!.e30.e30", wantErr: "header"},
+		{name: "Wrong algorithm", token: signer.Sign(map[string]any{"alg": "HS256", "kid": authtest.KeyID}, map[string]any{}), wantErr: "unsupported algorithm"},
+		{name: "Missing kid", token: signer.Sign(map[string]any{"alg": "ES256"}, map[string]any{}), wantErr: "missing kid"},
+		{name: "Unknown kid", token: signer.Sign(map[string]any{"alg": "ES256", "kid": "6f9c1e2d-3b4a-4c5d-8e7f-0a1b2c3d4e5f"}, map[string]any{}), wantErr: "failed to fetch key"},
+		{name: "Non-UUID kid", token: signer.Sign(map[string]any{"alg": "ES256", "kid": "../keys"}, map[string]any{}), wantErr: "invalid kid"},
+		{name: "Signed by another key", token: other.Token("user-1", nil), wantErr: "signature verification failed"},
+		{name: "Tampered claims", token: tamper(valid, other.Token("admin", nil)), wantErr: "signature verification failed"},
+		{name: "Wrong issuer", token: signer.Token("user-1", map[string]any{"iss": "https://evil.example.com"}), wantErr: "unexpected issuer"},
+		{name: "Expired", token: signer.Token("user-1", map[string]any{"exp": expired}), wantErr: "token expired"},
+		{name: "Expired within leeway", token: signer.Token("user-1", map[string]any{"exp": time.Now().Add(-30 * time.Second).Unix()})},
+		{name: "Missing exp", token: signer.Token("user-1", map[string]any{"exp": nil}), wantErr: "missing exp"},
+		{name: "Missing sub", token: signer.Token("", nil), wantErr: "missing sub"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewVerifier(authtest.Issuer, NewHTTPKeySource(signer.URL, nil), tt.opts...)
+			identity, err := v.Verify(context.Background(), tt.token)
+
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got identity %+v", tt.wantErr, identity)
+				}
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("Expected ErrInvalidToken, got %v", err)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if identity.Subject != "user-1" {
+				t.Errorf("Expected subject user-1, got %q", identity.Subject)
+			}
+		})
+	}
+}
+
+func TestVerifyIdentity(t *testing.T) {
+	signer, err := authtest.NewSigner()
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	defer signer.Close()
+
+	v := NewVerifier(authtest.Issuer, NewHTTPKeySource(signer.URL, nil))
+	identity, err := v.Verify(context.Background(), signer.Token("user-1", map[string]any{
+		"email":  "user@example.com",
+		"groups": []string{"platform"},
+	}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if identity.Email != "user@example.com" {
+		t.Errorf("Expected email, got %q", identity.Email)
+	}
+	if _, ok := identity.Claims["groups"]; !ok {
+		t.Errorf("Expected all claims to be kept, got %v", identity.Claims)
+	}
+}
+
+func TestVerifyClock(t *testing.T) {
+	signer, err := authtest.NewSigner()
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	defer signer.Close()
+
+	token := signer.Token("user-1", nil)
+	later := func() time.Time { return time.Now().Add(2 * time.Hour) }
+	v := NewVerifier(authtest.Issuer, NewHTTPKeySource(signer.URL, nil), WithClock(later))
+	if _, err := v.Verify(context.Background(), token); err == nil {
+		t.Error("Expected token to be expired")
+	}
+}
+
+// tamper swaps the claims of token for those of donor, keeping the original
+// header and signature.
+func tamper(token, donor string) string {
+	parts := strings.Split(token, ".")
+	parts[1] = strings.Split(donor, ".")[1]
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,220 @@
+// Package authz decides what an authenticated caller may do with the
+// management API.
+package authz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+)
+
+// Capability is a class of operation on the management API.
+type Capability string
+
+const (
+	ReadPolicy     Capability = "read_policy"
+	WriteRoles     Capability = "write_roles"
+	WriteResources Capability = "write_resources"
+	DeletePolicy   Capability = "delete_policy"
+	RestoreHistory Capability = "restore_history"
+)
+
+var capabilities = []Capability{ReadPolicy, WriteRoles, WriteResources, DeletePolicy, RestoreHistory}
+
+// DefaultGroupsClaim is the claim holding the caller's groups when the
+// configuration does not name one.
+const DefaultGroupsClaim = "groups"
+
+// Config maps callers to capabilities. A caller gets the union of every rule
+// that matches it.
+type Config struct {
+	// GroupsClaim names the token claim listing the caller's groups, e.g.
+	// "cognito:groups". The claim may be a string or an array of strings.
+	GroupsClaim string `json:"groups_claim,omitempty"`
+	Rules       []Rule `json:"rules"`
+}
+
+// Rule grants capabilities to callers matching any of its subjects, emails or
+// groups.
+type Rule struct {
+	Subjects     []string     `json:"subjects,omitempty"`
+	Emails       []string     `json:"emails,omitempty"`
+	Groups       []string     `json:"groups,omitempty"`
+	Capabilities []Capability `json:"capabilities"`
+	// RolePrefixes limits this rule's write_roles grant to roles whose IDs
+	// start with one of the prefixes. Empty means every role.
+	RolePrefixes []string `json:"role_prefixes,omitempty"`
+}
+
+// Authorizer evaluates a Config.
+type Authorizer struct {
+	config Config
+}
+
+// Parse reads a JSON Config.
+func Parse(data []byte) (*Authorizer, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse authorization config: %w", err)
+	}
+	return New(config)
+}
+
+func New(config Config) (*Authorizer, error) {
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = DefaultGroupsClaim
+	}
+	for i, rule := range config.Rules {
+		if len(rule.Subjects) == 0 && len(rule.Emails) == 0 && len(rule.Groups) == 0 {
+			return nil, fmt.Errorf("rules[%d]: must match at least one subject, email or group", i)
+		}
+		for _, c := range rule.Capabilities {
+			if !slices.Contains(capabilities, c) {
+				return nil, fmt.Errorf("rules[%d]: unknown capability %q", i, c)
+			}
+		}
+		if len(rule.RolePrefixes) > 0 && !slices.Contains(rule.Capabilities, WriteRoles) {
+			return nil, fmt.Errorf("rules[%d]: role_prefixes requires the %s capability", i, WriteRoles)
+		}
+		if slices.Contains(rule.RolePrefixes, "") {
+			return nil, fmt.Errorf("rules[%d]: role_prefixes must not be empty strings", i)
+		}
+	}
+	return &Authorizer{config: config}, nil
+}
+
+// Permissions returns what identity may do. A nil identity has no permissions.
+func (a *Authorizer) Permissions(identity *auth.Identity) Permissions {
+	p := Permissions{capabilities: make(map[Capability]bool)}
+	if identity == nil {
+		return p
+	}
+
+	groups := claimStrings(identity.Claims[a.config.GroupsClaim])
+	for _, rule := range a.config.Rules {
+		if !rule.matches(identity, groups) {
+			continue
+		}
+		for _, c := range rule.Capabilities {
+			p.capabilities[c] = true
+		}
+		if slices.Contains(rule.Capabilities, WriteRoles) {
+			if len(rule.RolePrefixes) == 0 {
+				p.allRoles = true
+			}
+			p.rolePrefixes = append(p.rolePrefixes, rule.RolePrefixes...)
+		}
+	}
+	return p
+}
+
+func (r Rule) matches(identity *auth.Identity, groups []string) bool {
+	if slices.Contains(r.Subjects, identity.Subject) {
+		return true
+	}
+	if identity.Email != "" && slices.ContainsFunc(r.Emails, func(e string) bool { return strings.EqualFold(e, identity.Email) }) {
+		return true
+	}
+	return slices.ContainsFunc(r.Groups, func(g string) bool { return slices.Contains(groups, g) })
+}
+
+// claimStrings reads a claim that may be a single string or a list.
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+// Permissions is the set of capabilities held by one caller.
+type Permissions struct {
+	capabilities map[Capability]bool
+	allRoles     bool
+	rolePrefixes []string
+}
+
+// Has reports whether the caller holds c. For WriteRoles this means on at
+// least some roles; use CanWriteRole for a specific one.
+func (p Permissions) Has(c Capability) bool {
+	return p.capabilities[c]
+}
+
+// CanWriteRole reports whether the caller may create, change or delete roleID.
+func (p Permissions) CanWriteRole(roleID string) bool {
+	if !p.capabilities[WriteRoles] {
+		return false
+	}
+	if p.allRoles {
+		return true
+	}
+	return slices.ContainsFunc(p.rolePrefixes, func(prefix string) bool { return strings.HasPrefix(roleID, prefix) })
+}
+
+// Check returns a *DeniedError unless the caller holds c.
+func (p Permissions) Check(c Capability) error {
+	if !p.Has(c) {
+		return &DeniedError{Capability: c}
+	}
+	return nil
+}
+
+// CheckChange returns a *DeniedError unless the caller may write every role
+// and resource the diff touches.
+func (p Permissions) CheckChange(d rbac.Diff) error {
+	roleIDs := append(slices.Clone(d.RolesAdded), d.RolesRemoved...)
+	for _, change := range d.RoleChanges {
+		roleIDs = append(roleIDs, change.RoleID)
+	}
+	for _, roleID := range roleIDs {
+		if p.CanWriteRole(roleID) {
+			continue
+		}
+		if !p.Has(WriteRoles) {
+			return &DeniedError{Capability: WriteRoles}
+		}
+		return &DeniedError{Capability: WriteRoles, RoleID: roleID}
+	}
+
+	if len(d.ResourcesAdded) > 0 || len(d.ResourcesRemoved) > 0 || len(d.ResourceChanges) > 0 {
+		return p.Check(WriteResources)
+	}
+	return nil
+}
+
+// ErrDenied is wrapped by DeniedError.
+var ErrDenied = errors.New("permission denied")
+
+// DeniedError reports the capability a caller is missing.
+type DeniedError struct {
+	Capability Capability
+	// RoleID is set when the caller holds WriteRoles but not for this role.
+	RoleID string
+}
+
+func (e *DeniedError) Error() string {
+	if e.RoleID != "" {
+		return fmt.Sprintf("missing capability %s for role %q", e.Capability, e.RoleID)
+	}
+	return fmt.Sprintf("missing capability %s", e.Capability)
+}
+
+func (e *DeniedError) Unwrap() error {
+	return ErrDenied
+}
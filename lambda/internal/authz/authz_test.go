@@ -0,0 +1,225 @@
+package authz
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+)
+
+const testConfig = `{
+	"groups_claim": "cognito:groups",
+	"rules": [
+		{"groups": ["platform"], "capabilities": ["read_policy", "write_roles", "write_resources", "delete_policy", "restore_history"]},
+		{"groups": ["billing"], "capabilities": ["read_policy", "write_roles"], "role_prefixes": ["billing_"]},
+		{"emails": ["Auditor@Example.com"], "capabilities": ["read_policy"]},
+		{"subjects": ["support-bot"], "capabilities": ["write_roles"], "role_prefixes": ["support_", "billing_viewer"]}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{name: "Valid config", config: testConfig},
+		{name: "Empty config", config: `{}`},
+		{name: "Invalid JSON", config: `{`, wantErr: "failed to parse authorization config"},
+		{name: "Unknown capability", config: `{"rules":[{"groups":["a"],"capabilities":["admin"]}]}`, wantErr: `unknown capability "admin"`},
+		{name: "Rule without matchers", config: `{"rules":[{"capabilities":["read_policy"]}]}`, wantErr: "must match at least one"},
+		{name: "Prefixes without write_roles", config: `{"rules":[{"groups":["a"],"capabilities":["read_policy"],"role_prefixes":["a_"]}]}`, wantErr: "role_prefixes requires"},
+		{name: "Empty prefix", config: `{"rules":[{"groups":["a"],"capabilities":["write_roles"],"role_prefixes":[""]}]}`, wantErr: "must not be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.config))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	authorizer, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		identity    *auth.Identity
+		has         []Capability
+		hasNot      []Capability
+		canWrite    []string
+		cannotWrite []string
+	}{
+		{
+			name:     "Platform group as list",
+			identity: &auth.Identity{Subject: "u1", Claims: map[string]any{"cognito:groups": []any{"other", "platform"}}},
+			has:      []Capability{ReadPolicy, WriteRoles, WriteResources, DeletePolicy, RestoreHistory},
+			canWrite: []string{"anything", "stytch_member"},
+		},
+		{
+			name:        "Billing group as string",
+			identity:    &auth.Identity{Subject: "u2", Claims: map[string]any{"cognito:groups": "billing"}},
+			has:         []Capability{ReadPolicy, WriteRoles},
+			hasNot:      []Capability{WriteResources, DeletePolicy, RestoreHistory},
+			canWrite:    []string{"billing_admin"},
+			cannotWrite: []string{"support_agent", "stytch_admin"},
+		},
+		{
+			name:        "Email match is case-insensitive",
+			identity:    &auth.Identity{Subject: "u3", Email: "auditor@example.com"},
+			has:         []Capability{ReadPolicy},
+			hasNot:      []Capability{WriteRoles},
+			cannotWrite: []string{"billing_admin"},
+		},
+		{
+			name:        "Subject with several prefixes",
+			identity:    &auth.Identity{Subject: "support-bot"},
+			has:         []Capability{WriteRoles},
+			hasNot:      []Capability{ReadPolicy},
+			canWrite:    []string{"support_agent", "billing_viewer"},
+			cannotWrite: []string{"billing_admin"},
+		},
+		{
+			name:        "Union of rules",
+			identity:    &auth.Identity{Subject: "support-bot", Claims: map[string]any{"cognito:groups": []string{"billing"}}},
+			has:         []Capability{ReadPolicy, WriteRoles},
+			canWrite:    []string{"support_agent", "billing_admin"},
+			cannotWrite: []string{"editor"},
+		},
+		{
+			name:     "Unknown caller",
+			identity: &auth.Identity{Subject: "stranger", Claims: map[string]any{"groups": []any{"platform"}}},
+			hasNot:   []Capability{ReadPolicy, WriteRoles},
+		},
+		{
+			name:   "No identity",
+			hasNot: []Capability{ReadPolicy},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := authorizer.Permissions(tt.identity)
+			for _, c := range tt.has {
+				if !p.Has(c) {
+					t.Errorf("Expected %s", c)
+				}
+			}
+			for _, c := range tt.hasNot {
+				if p.Has(c) {
+					t.Errorf("Expected no %s", c)
+				}
+				if err := p.Check(c); !errors.Is(err, ErrDenied) {
+					t.Errorf("Check(%s): expected ErrDenied, got %v", c, err)
+				}
+			}
+			for _, roleID := range tt.canWrite {
+				if !p.CanWriteRole(roleID) {
+					t.Errorf("Expected to be able to write %q", roleID)
+				}
+			}
+			for _, roleID := range tt.cannotWrite {
+				if p.CanWriteRole(roleID) {
+					t.Errorf("Expected not to be able to write %q", roleID)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckChange(t *testing.T) {
+	authorizer, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	billing := authorizer.Permissions(&auth.Identity{Subject: "u", Claims: map[string]any{"cognito:groups": "billing"}})
+	auditor := authorizer.Permissions(&auth.Identity{Subject: "u", Email: "auditor@example.com"})
+
+	tests := []struct {
+		name        string
+		permissions Permissions
+		diff        rbac.Diff
+		want        *DeniedError
+	}{
+		{
+			name:        "Empty diff",
+			permissions: auditor,
+			diff:        rbac.Diff{},
+		},
+		{
+			name:        "Owned roles",
+			permissions: billing,
+			diff: rbac.Diff{
+				RolesAdded:   []string{"billing_admin"},
+				RolesRemoved: []string{"billing_old"},
+				RoleChanges:  []rbac.RoleChange{{RoleID: "billing_viewer"}},
+			},
+		},
+		{
+			name:        "Role owned by another team",
+			permissions: billing,
+			diff:        rbac.Diff{RoleChanges: []rbac.RoleChange{{RoleID: "support_agent"}}},
+			want:        &DeniedError{Capability: WriteRoles, RoleID: "support_agent"},
+		},
+		{
+			name:        "Removed role owned by another team",
+			permissions: billing,
+			diff:        rbac.Diff{RolesRemoved: []string{"editor"}},
+			want:        &DeniedError{Capability: WriteRoles, RoleID: "editor"},
+		},
+		{
+			name:        "No write_roles",
+			permissions: auditor,
+			diff:        rbac.Diff{RolesAdded: []string{"billing_admin"}},
+			want:        &DeniedError{Capability: WriteRoles},
+		},
+		{
+			name:        "Resource change",
+			permissions: billing,
+			diff:        rbac.Diff{ResourcesAdded: []string{"invoices"}},
+			want:        &DeniedError{Capability: WriteResources},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.permissions.CheckChange(tt.diff)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			var denied *DeniedError
+			if !errors.As(err, &denied) {
+				t.Fatalf("Expected *DeniedError, got %v", err)
+			}
+			if *denied != *tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, denied)
+			}
+		})
+	}
+}
+
+func TestDeniedErrorMessage(t *testing.T) {
+	if got := (&DeniedError{Capability: DeletePolicy}).Error(); got != "missing capability delete_policy" {
+		t.Errorf("Unexpected message %q", got)
+	}
+	if got := (&DeniedError{Capability: WriteRoles, RoleID: "editor"}).Error(); got != `missing capability write_roles for role "editor"` {
+		t.Errorf("Unexpected message %q", got)
+	}
+}
@@ -2,20 +2,123 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// DefaultPolicyCacheTTL is how long a warm container serves policy reads
+// from memory when POLICY_CACHE_TTL is unset.
+const DefaultPolicyCacheTTL = 30 * time.Second
+
 type Config struct {
 	WorkspaceKeyID     string
 	WorkspaceKeySecret string
 	ProjectID          string
+
+	// StytchBaseURI overrides the Stytch management API endpoint, e.g. to
+	// point at a local stand-in. Empty means the public API.
+	StytchBaseURI string
+
+	// FakeStytch serves policies from memory instead of calling Stytch, for
+	// local development. The workspace key is not needed when it is set.
+	FakeStytch bool
+
+	// Projects maps the names accepted in /rbacpolicy/projects/{project_id}
+	// paths, project IDs and their aliases, to project IDs. It is parsed from
+	// STYTCH_PROJECTS, a comma-separated list of "project-id" or
+	// "alias=project-id" entries. ProjectID is always reachable.
+	Projects map[string]string
+
+	// Policy snapshots are written to HistoryBucket when set, otherwise to
+	// HistoryDir, otherwise kept in memory.
+	HistoryBucket string
+	HistoryPrefix string
+	HistoryDir    string
+
+	// Callers must present an ALB OIDC token from OIDCIssuer, signed by the
+	// ALB OIDCSignerARN, when it is set. OIDCKeyEndpoint defaults to the ALB
+	// public key endpoint for AWS_REGION.
+	OIDCIssuer      string
+	OIDCKeyEndpoint string
+	OIDCSignerARN   string
+
+	// AuthzConfig is the JSON authorization config, given inline or read from
+	// AuthzConfigFile. Without either, authenticated callers may do anything.
+	AuthzConfig     string
+	AuthzConfigFile string
+
+	// AuditLogFile receives audit records as JSON lines. Without it they
+	// are written to the function's log.
+	AuditLogFile string
+
+	// Desired policies for drift detection are read from
+	// DesiredPolicyBucket when set, otherwise from DesiredPolicyDir. Without
+	// either, drift detection is off. DriftAutoReconcile makes scheduled
+	// checks write the desired policy.
+	DesiredPolicyBucket string
+	DesiredPolicyPrefix string
+	DesiredPolicyDir    string
+	DriftAutoReconcile  bool
+
+	// PolicyCacheTTL is how long policy reads are cached between
+	// invocations. Zero disables the cache.
+	PolicyCacheTTL time.Duration
 }
 
 func LoadConfig() (*Config, error) {
 	cfg := &Config{
-		WorkspaceKeyID:     os.Getenv("STYTCH_WORKSPACE_KEY_ID"),
-		WorkspaceKeySecret: os.Getenv("STYTCH_WORKSPACE_KEY_SECRET"),
-		ProjectID:          os.Getenv("STYTCH_PROJECT_ID"),
+		WorkspaceKeyID:      os.Getenv("STYTCH_WORKSPACE_KEY_ID"),
+		WorkspaceKeySecret:  os.Getenv("STYTCH_WORKSPACE_KEY_SECRET"),
+		ProjectID:           os.Getenv("STYTCH_PROJECT_ID"),
+		StytchBaseURI:       os.Getenv("STYTCH_BASE_URI"),
+		HistoryBucket:       os.Getenv("HISTORY_S3_BUCKET"),
+		HistoryPrefix:       os.Getenv("HISTORY_S3_PREFIX"),
+		HistoryDir:          os.Getenv("HISTORY_DIR"),
+		OIDCIssuer:          os.Getenv("OIDC_ISSUER"),
+		OIDCKeyEndpoint:     os.Getenv("OIDC_KEY_ENDPOINT"),
+		OIDCSignerARN:       os.Getenv("OIDC_SIGNER_ARN"),
+		AuthzConfig:         os.Getenv("AUTHZ_CONFIG"),
+		AuthzConfigFile:     os.Getenv("AUTHZ_CONFIG_FILE"),
+		AuditLogFile:        os.Getenv("AUDIT_LOG_FILE"),
+		DesiredPolicyBucket: os.Getenv("DESIRED_POLICY_S3_BUCKET"),
+		DesiredPolicyPrefix: os.Getenv("DESIRED_POLICY_S3_PREFIX"),
+		DesiredPolicyDir:    os.Getenv("DESIRED_POLICY_DIR"),
+		PolicyCacheTTL:      DefaultPolicyCacheTTL,
+	}
+	if v := os.Getenv("DRIFT_AUTO_RECONCILE"); v != "" {
+		reconcile, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("DRIFT_AUTO_RECONCILE must be true or false, got %q", v)
+		}
+		cfg.DriftAutoReconcile = reconcile
+	}
+	if v := os.Getenv("STYTCH_FAKE"); v != "" {
+		fake, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("STYTCH_FAKE must be true or false, got %q", v)
+		}
+		cfg.FakeStytch = fake
+	}
+	if v := os.Getenv("STYTCH_PROJECTS"); v != "" {
+		projects, err := parseProjects(v)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Projects = projects
+	}
+	if v := os.Getenv("POLICY_CACHE_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl < 0 {
+			return nil, fmt.Errorf("POLICY_CACHE_TTL must be a non-negative duration, got %q", v)
+		}
+		cfg.PolicyCacheTTL = ttl
+	}
+	if cfg.OIDCKeyEndpoint == "" && os.Getenv("AWS_REGION") != "" {
+		cfg.OIDCKeyEndpoint = "https://public-keys.auth.elb." + os.Getenv("AWS_REGION") + ".amazonaws.com"
 	}
 
 	if err := cfg.validate(); err != nil {
@@ -26,14 +129,84 @@ func LoadConfig() (*Config, error) {
 }
 
 func (c *Config) validate() error {
-	if c.WorkspaceKeyID == "" {
+	if c.WorkspaceKeyID == "" && !c.FakeStytch {
 		return errors.New("STYTCH_WORKSPACE_KEY_ID environment variable is required")
 	}
-	if c.WorkspaceKeySecret == "" {
+	if c.WorkspaceKeySecret == "" && !c.FakeStytch {
 		return errors.New("STYTCH_WORKSPACE_KEY_SECRET environment variable is required")
 	}
 	if c.ProjectID == "" {
 		return errors.New("STYTCH_PROJECT_ID environment variable is required")
 	}
+	if c.StytchBaseURI != "" {
+		u, err := url.Parse(c.StytchBaseURI)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("STYTCH_BASE_URI must be an http or https URL, got %q", c.StytchBaseURI)
+		}
+	}
+	if c.StytchBaseURI != "" && c.FakeStytch {
+		return errors.New("STYTCH_BASE_URI and STYTCH_FAKE cannot both be set")
+	}
+	if projectID, ok := c.Projects[c.ProjectID]; ok && projectID != c.ProjectID {
+		return fmt.Errorf("STYTCH_PROJECTS cannot use the default project ID %q as an alias", c.ProjectID)
+	}
+	if c.HistoryBucket != "" && c.HistoryDir != "" {
+		return errors.New("HISTORY_S3_BUCKET and HISTORY_DIR cannot both be set")
+	}
+	if c.DesiredPolicyBucket != "" && c.DesiredPolicyDir != "" {
+		return errors.New("DESIRED_POLICY_S3_BUCKET and DESIRED_POLICY_DIR cannot both be set")
+	}
+	if c.DriftAutoReconcile && c.DesiredPolicyBucket == "" && c.DesiredPolicyDir == "" {
+		return errors.New("DESIRED_POLICY_S3_BUCKET or DESIRED_POLICY_DIR environment variable is required when DRIFT_AUTO_RECONCILE is set")
+	}
+	if c.OIDCIssuer != "" && c.OIDCKeyEndpoint == "" {
+		return errors.New("OIDC_KEY_ENDPOINT or AWS_REGION environment variable is required when OIDC_ISSUER is set")
+	}
+	// Every ALB in the region signs with the same public keys, so without
+	// the signer check a token minted for any other ALB would be accepted.
+	if c.OIDCIssuer != "" && c.OIDCSignerARN == "" {
+		return errors.New("OIDC_SIGNER_ARN environment variable is required when OIDC_ISSUER is set")
+	}
+	if c.AuthzConfig != "" && c.AuthzConfigFile != "" {
+		return errors.New("AUTHZ_CONFIG and AUTHZ_CONFIG_FILE cannot both be set")
+	}
+	if (c.AuthzConfig != "" || c.AuthzConfigFile != "") && c.OIDCIssuer == "" {
+		return errors.New("OIDC_ISSUER environment variable is required when authorization is configured")
+	}
 	return nil
 }
+
+// parseProjects parses STYTCH_PROJECTS into a map from every accepted name to
+// its project ID. Each project is reachable by its ID as well as its alias.
+func parseProjects(v string) (map[string]string, error) {
+	projects := make(map[string]string)
+	add := func(name, projectID string) error {
+		if existing, ok := projects[name]; ok && existing != projectID {
+			return fmt.Errorf("STYTCH_PROJECTS maps %q to both %q and %q", name, existing, projectID)
+		}
+		projects[name] = projectID
+		return nil
+	}
+
+	for _, entry := range strings.Split(v, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		alias, projectID, hasAlias := strings.Cut(entry, "=")
+		alias, projectID = strings.TrimSpace(alias), strings.TrimSpace(projectID)
+		if !hasAlias {
+			projectID = alias
+		}
+		if alias == "" || projectID == "" || strings.Contains(entry, "/") {
+			return nil, fmt.Errorf("STYTCH_PROJECTS entry %q must be \"project-id\" or \"alias=project-id\"", entry)
+		}
+		if err := add(projectID, projectID); err != nil {
+			return nil, err
+		}
+		if err := add(alias, projectID); err != nil {
+			return nil, err
+		}
+	}
+	return projects, nil
+}
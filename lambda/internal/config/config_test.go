@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -11,6 +13,11 @@ func TestLoadConfig(t *testing.T) {
 		envVars map[string]string
 		wantErr bool
 		errMsg  string
+		// wantKeyEndpoint is checked only when set.
+		wantKeyEndpoint string
+		// wantCacheTTL is checked only when POLICY_CACHE_TTL is set.
+		wantCacheTTL time.Duration
+		wantProjects map[string]string
 	}{
 		{
 			name: "Valid configuration",
@@ -21,6 +28,180 @@ func TestLoadConfig(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Policy cache TTL",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"POLICY_CACHE_TTL":            "2m",
+			},
+			wantErr:      false,
+			wantCacheTTL: 2 * time.Minute,
+		},
+		{
+			name: "Policy cache disabled",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"POLICY_CACHE_TTL":            "0s",
+			},
+			wantErr:      false,
+			wantCacheTTL: 0,
+		},
+		{
+			name: "Invalid policy cache TTL",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"POLICY_CACHE_TTL":            "30",
+			},
+			wantErr: true,
+			errMsg:  `POLICY_CACHE_TTL must be a non-negative duration, got "30"`,
+		},
+		{
+			name: "Projects",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "project-test",
+				"STYTCH_PROJECTS":             "live=project-live, test=project-test",
+			},
+			wantErr: false,
+			wantProjects: map[string]string{
+				"live":         "project-live",
+				"project-live": "project-live",
+				"test":         "project-test",
+				"project-test": "project-test",
+			},
+		},
+		{
+			name: "Invalid projects",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "project-test",
+				"STYTCH_PROJECTS":             "live=",
+			},
+			wantErr: true,
+			errMsg:  `STYTCH_PROJECTS entry "live=" must be "project-id" or "alias=project-id"`,
+		},
+		{
+			name: "Default project ID used as alias",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "project-test",
+				"STYTCH_PROJECTS":             "project-test=project-live",
+			},
+			wantErr: true,
+			errMsg:  `STYTCH_PROJECTS cannot use the default project ID "project-test" as an alias`,
+		},
+		{
+			name: "Desired policy bucket with auto reconcile",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"DESIRED_POLICY_S3_BUCKET":    "policies",
+				"DESIRED_POLICY_S3_PREFIX":    "rbac/",
+				"DRIFT_AUTO_RECONCILE":        "true",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Desired policy bucket and directory",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"DESIRED_POLICY_S3_BUCKET":    "policies",
+				"DESIRED_POLICY_DIR":          "policies",
+			},
+			wantErr: true,
+			errMsg:  "DESIRED_POLICY_S3_BUCKET and DESIRED_POLICY_DIR cannot both be set",
+		},
+		{
+			name: "Auto reconcile without desired policy",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"DRIFT_AUTO_RECONCILE":        "1",
+			},
+			wantErr: true,
+			errMsg:  "DESIRED_POLICY_S3_BUCKET or DESIRED_POLICY_DIR environment variable is required when DRIFT_AUTO_RECONCILE is set",
+		},
+		{
+			name: "Invalid auto reconcile",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"DESIRED_POLICY_DIR":          "policies",
+				"DRIFT_AUTO_RECONCILE":        "sometimes",
+			},
+			wantErr: true,
+			errMsg:  `DRIFT_AUTO_RECONCILE must be true or false, got "sometimes"`,
+		},
+		{
+			name: "Fake Stytch without workspace key",
+			envVars: map[string]string{
+				"STYTCH_PROJECT_ID": "test-project-id",
+				"STYTCH_FAKE":       "true",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid fake Stytch",
+			envVars: map[string]string{
+				"STYTCH_PROJECT_ID": "test-project-id",
+				"STYTCH_FAKE":       "yes",
+			},
+			wantErr: true,
+			errMsg:  `STYTCH_FAKE must be true or false, got "yes"`,
+		},
+		{
+			name: "Fake Stytch without project ID",
+			envVars: map[string]string{
+				"STYTCH_FAKE": "true",
+			},
+			wantErr: true,
+			errMsg:  "STYTCH_PROJECT_ID environment variable is required",
+		},
+		{
+			name: "Stytch base URI",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"STYTCH_BASE_URI":             "http://127.0.0.1:8081",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid Stytch base URI",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"STYTCH_BASE_URI":             "management.stytch.com",
+			},
+			wantErr: true,
+			errMsg:  `STYTCH_BASE_URI must be an http or https URL, got "management.stytch.com"`,
+		},
+		{
+			name: "Stytch base URI with fake Stytch",
+			envVars: map[string]string{
+				"STYTCH_PROJECT_ID": "test-project-id",
+				"STYTCH_BASE_URI":   "http://127.0.0.1:8081",
+				"STYTCH_FAKE":       "true",
+			},
+			wantErr: true,
+			errMsg:  "STYTCH_BASE_URI and STYTCH_FAKE cannot both be set",
+		},
 		{
 			name: "Missing workspace key ID",
 			envVars: map[string]string{
@@ -48,6 +229,119 @@ func TestLoadConfig(t *testing.T) {
 			wantErr: true,
 			errMsg:  "STYTCH_PROJECT_ID environment variable is required",
 		},
+		{
+			name: "History bucket",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"HISTORY_S3_BUCKET":           "snapshots",
+				"HISTORY_S3_PREFIX":           "rbacpolicy/",
+				"AUDIT_LOG_FILE":              "/tmp/audit.jsonl",
+			},
+			wantErr: false,
+		},
+		{
+			name: "History bucket and directory",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"HISTORY_S3_BUCKET":           "snapshots",
+				"HISTORY_DIR":                 "/tmp/history",
+			},
+			wantErr: true,
+			errMsg:  "HISTORY_S3_BUCKET and HISTORY_DIR cannot both be set",
+		},
+		{
+			name: "OIDC key endpoint from region",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"OIDC_ISSUER":                 "https://idp.example.com",
+				"OIDC_SIGNER_ARN":             "arn:aws:elasticloadbalancing:us-west-2:123456789012:loadbalancer/app/rbac/abc",
+				"AWS_REGION":                  "us-west-2",
+			},
+			wantErr:         false,
+			wantKeyEndpoint: "https://public-keys.auth.elb.us-west-2.amazonaws.com",
+		},
+		{
+			name: "Explicit OIDC key endpoint",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"OIDC_ISSUER":                 "https://idp.example.com",
+				"OIDC_KEY_ENDPOINT":           "http://localhost:8080/keys",
+				"OIDC_SIGNER_ARN":             "arn:aws:elasticloadbalancing:us-west-2:123456789012:loadbalancer/app/rbac/abc",
+				"AWS_REGION":                  "us-west-2",
+			},
+			wantErr:         false,
+			wantKeyEndpoint: "http://localhost:8080/keys",
+		},
+		{
+			name: "OIDC issuer without key endpoint",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"OIDC_ISSUER":                 "https://idp.example.com",
+			},
+			wantErr: true,
+			errMsg:  "OIDC_KEY_ENDPOINT or AWS_REGION environment variable is required when OIDC_ISSUER is set",
+		},
+		{
+			name: "OIDC issuer without signer",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"OIDC_ISSUER":                 "https://idp.example.com",
+				"AWS_REGION":                  "us-west-2",
+			},
+			wantErr: true,
+			errMsg:  "OIDC_SIGNER_ARN environment variable is required when OIDC_ISSUER is set",
+		},
+		{
+			name: "Authorization config",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"OIDC_ISSUER":                 "https://idp.example.com",
+				"OIDC_SIGNER_ARN":             "arn:aws:elasticloadbalancing:us-west-2:123456789012:loadbalancer/app/rbac/abc",
+				"AWS_REGION":                  "us-west-2",
+				"AUTHZ_CONFIG":                `{"rules":[]}`,
+			},
+			wantErr: false,
+		},
+		{
+			name: "Authorization config inline and from file",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"OIDC_ISSUER":                 "https://idp.example.com",
+				"OIDC_SIGNER_ARN":             "arn:aws:elasticloadbalancing:us-west-2:123456789012:loadbalancer/app/rbac/abc",
+				"AWS_REGION":                  "us-west-2",
+				"AUTHZ_CONFIG":                `{"rules":[]}`,
+				"AUTHZ_CONFIG_FILE":           "/etc/authz.json",
+			},
+			wantErr: true,
+			errMsg:  "AUTHZ_CONFIG and AUTHZ_CONFIG_FILE cannot both be set",
+		},
+		{
+			name: "Authorization config without OIDC",
+			envVars: map[string]string{
+				"STYTCH_WORKSPACE_KEY_ID":     "test-key-id",
+				"STYTCH_WORKSPACE_KEY_SECRET": "test-key-secret",
+				"STYTCH_PROJECT_ID":           "test-project-id",
+				"AUTHZ_CONFIG_FILE":           "/etc/authz.json",
+			},
+			wantErr: true,
+			errMsg:  "OIDC_ISSUER environment variable is required when authorization is configured",
+		},
 		{
 			name:    "All environment variables missing",
 			envVars: map[string]string{},
@@ -90,6 +384,49 @@ func TestLoadConfig(t *testing.T) {
 					if cfg.ProjectID != tt.envVars["STYTCH_PROJECT_ID"] {
 						t.Errorf("ProjectID = %v, want %v", cfg.ProjectID, tt.envVars["STYTCH_PROJECT_ID"])
 					}
+					if cfg.StytchBaseURI != tt.envVars["STYTCH_BASE_URI"] {
+						t.Errorf("StytchBaseURI = %v, want %v", cfg.StytchBaseURI, tt.envVars["STYTCH_BASE_URI"])
+					}
+					if cfg.HistoryBucket != tt.envVars["HISTORY_S3_BUCKET"] {
+						t.Errorf("HistoryBucket = %v, want %v", cfg.HistoryBucket, tt.envVars["HISTORY_S3_BUCKET"])
+					}
+					if cfg.HistoryPrefix != tt.envVars["HISTORY_S3_PREFIX"] {
+						t.Errorf("HistoryPrefix = %v, want %v", cfg.HistoryPrefix, tt.envVars["HISTORY_S3_PREFIX"])
+					}
+					if cfg.AuditLogFile != tt.envVars["AUDIT_LOG_FILE"] {
+						t.Errorf("AuditLogFile = %v, want %v", cfg.AuditLogFile, tt.envVars["AUDIT_LOG_FILE"])
+					}
+					if cfg.OIDCIssuer != tt.envVars["OIDC_ISSUER"] {
+						t.Errorf("OIDCIssuer = %v, want %v", cfg.OIDCIssuer, tt.envVars["OIDC_ISSUER"])
+					}
+					if cfg.AuthzConfig != tt.envVars["AUTHZ_CONFIG"] {
+						t.Errorf("AuthzConfig = %v, want %v", cfg.AuthzConfig, tt.envVars["AUTHZ_CONFIG"])
+					}
+					if tt.wantKeyEndpoint != "" && cfg.OIDCKeyEndpoint != tt.wantKeyEndpoint {
+						t.Errorf("OIDCKeyEndpoint = %v, want %v", cfg.OIDCKeyEndpoint, tt.wantKeyEndpoint)
+					}
+					wantCacheTTL := DefaultPolicyCacheTTL
+					if _, set := tt.envVars["POLICY_CACHE_TTL"]; set {
+						wantCacheTTL = tt.wantCacheTTL
+					}
+					if cfg.PolicyCacheTTL != wantCacheTTL {
+						t.Errorf("PolicyCacheTTL = %v, want %v", cfg.PolicyCacheTTL, wantCacheTTL)
+					}
+					if cfg.DesiredPolicyBucket != tt.envVars["DESIRED_POLICY_S3_BUCKET"] {
+						t.Errorf("DesiredPolicyBucket = %v, want %v", cfg.DesiredPolicyBucket, tt.envVars["DESIRED_POLICY_S3_BUCKET"])
+					}
+					if cfg.DesiredPolicyPrefix != tt.envVars["DESIRED_POLICY_S3_PREFIX"] {
+						t.Errorf("DesiredPolicyPrefix = %v, want %v", cfg.DesiredPolicyPrefix, tt.envVars["DESIRED_POLICY_S3_PREFIX"])
+					}
+					if want := tt.envVars["DRIFT_AUTO_RECONCILE"] == "true"; cfg.DriftAutoReconcile != want {
+						t.Errorf("DriftAutoReconcile = %v, want %v", cfg.DriftAutoReconcile, want)
+					}
+					if want := tt.envVars["STYTCH_FAKE"] == "true"; cfg.FakeStytch != want {
+						t.Errorf("FakeStytch = %v, want %v", cfg.FakeStytch, want)
+					}
+					if !reflect.DeepEqual(cfg.Projects, tt.wantProjects) {
+						t.Errorf("Projects = %v, want %v", cfg.Projects, tt.wantProjects)
+					}
 				}
 			}
 		})
@@ -162,3 +499,79 @@ func TestConfigValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestParseProjects(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:  "IDs and aliases",
+			value: "live=project-live,test=project-test,project-tenant",
+			want: map[string]string{
+				"live":           "project-live",
+				"project-live":   "project-live",
+				"test":           "project-test",
+				"project-test":   "project-test",
+				"project-tenant": "project-tenant",
+			},
+		},
+		{
+			name:  "Whitespace and empty entries",
+			value: " live = project-live ,, ",
+			want: map[string]string{
+				"live":         "project-live",
+				"project-live": "project-live",
+			},
+		},
+		{
+			name:  "Two aliases for one project",
+			value: "live=project-live,prod=project-live",
+			want: map[string]string{
+				"live":         "project-live",
+				"prod":         "project-live",
+				"project-live": "project-live",
+			},
+		},
+		{
+			name:    "Alias for two projects",
+			value:   "live=project-live,live=project-test",
+			wantErr: `STYTCH_PROJECTS maps "live" to both "project-live" and "project-test"`,
+		},
+		{
+			name:    "Alias shadowing a project ID",
+			value:   "project-live,project-live=project-test",
+			wantErr: `STYTCH_PROJECTS maps "project-live" to both "project-live" and "project-test"`,
+		},
+		{
+			name:    "Missing alias",
+			value:   "=project-live",
+			wantErr: `STYTCH_PROJECTS entry "=project-live" must be "project-id" or "alias=project-id"`,
+		},
+		{
+			name:    "Slash in name",
+			value:   "live/eu=project-live",
+			wantErr: `STYTCH_PROJECTS entry "live/eu=project-live" must be "project-id" or "alias=project-id"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProjects(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("parseProjects() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProjects() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseProjects() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
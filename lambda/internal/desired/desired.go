@@ -0,0 +1,52 @@
+// Package desired loads the declared RBAC policy each project should have,
+// against which the live policy is checked for drift.
+package desired
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// ErrNotFound is returned when no desired policy is declared for a project.
+var ErrNotFound = errors.New("desired policy not found")
+
+// Source loads desired policies. Each is a policy document in the same form
+// GET /rbacpolicy returns, so a known-good policy can be captured as-is.
+type Source interface {
+	Load(ctx context.Context, projectID string) (rbacpolicy.Policy, error)
+}
+
+// requiredFields are the keys every desired policy document must declare.
+// Custom roles and resources missing from the desired policy are drift, so a
+// document that leaves either out would reconcile them all away; [] declares
+// none on purpose.
+var requiredFields = []string{"custom_roles", "custom_resources"}
+
+// decode parses a desired policy document. Unknown fields are rejected so a
+// misspelt key fails loudly instead of declaring an empty policy, as are
+// documents that omit or null a required field.
+func decode(body []byte) (rbacpolicy.Policy, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	var policy rbacpolicy.Policy
+	if err := decoder.Decode(&policy); err != nil {
+		return rbacpolicy.Policy{}, fmt.Errorf("failed to parse desired policy: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return rbacpolicy.Policy{}, fmt.Errorf("failed to parse desired policy: %w", err)
+	}
+	for _, name := range requiredFields {
+		if value, ok := fields[name]; !ok || string(value) == "null" {
+			return rbacpolicy.Policy{}, fmt.Errorf("desired policy must declare %q; use [] to declare none", name)
+		}
+	}
+	return policy, nil
+}
@@ -0,0 +1,100 @@
+package desired
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+const testPolicyJSON = `{
+  "custom_roles": [{"role_id": "editor", "description": "Editor", "permissions": [{"resource_id": "documents", "actions": ["read", "write"]}]}],
+  "custom_resources": [{"resource_id": "documents", "description": "Documents", "available_actions": ["read", "write"]}]
+}`
+
+func testPolicy() rbacpolicy.Policy {
+	return rbacpolicy.Policy{
+		CustomRoles: []rbacpolicy.Role{{
+			RoleID:      "editor",
+			Description: "Editor",
+			Permissions: []rbacpolicy.Permission{{ResourceID: "documents", Actions: []string{"read", "write"}}},
+		}},
+		CustomResources: []rbacpolicy.Resource{{
+			ResourceID:       "documents",
+			Description:      "Documents",
+			AvailableActions: []string{"read", "write"},
+		}},
+	}
+}
+
+// testSource runs the behaviour every Source must share. write stores a raw
+// document for a project.
+func testSource(t *testing.T, source Source, write func(projectID, body string)) {
+	ctx := context.Background()
+	write("project-live", testPolicyJSON)
+	write("project-typo", `{"custom_role": []}`)
+	write("project-broken", `{"custom_roles": [`)
+
+	policy, err := source.Load(ctx, "project-live")
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(policy, testPolicy()) {
+		t.Errorf("Load() = %+v, want %+v", policy, testPolicy())
+	}
+
+	for _, projectID := range []string{"project-missing", "", "..", "../project-live"} {
+		if _, err := source.Load(ctx, projectID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Load(%q) error = %v, want ErrNotFound", projectID, err)
+		}
+	}
+
+	for _, projectID := range []string{"project-typo", "project-broken"} {
+		_, err := source.Load(ctx, projectID)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Load(%q) error = %v, want a parse error", projectID, err)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    rbacpolicy.Policy
+		wantErr bool
+	}{
+		{name: "Policy", body: testPolicyJSON, want: testPolicy()},
+		{
+			name: "Explicitly empty policy",
+			body: `{"custom_roles": [], "custom_resources": []}`,
+			want: rbacpolicy.Policy{CustomRoles: []rbacpolicy.Role{}, CustomResources: []rbacpolicy.Resource{}},
+		},
+		{name: "Empty document", body: `{}`, wantErr: true},
+		{name: "Missing custom resources", body: `{"custom_roles": []}`, wantErr: true},
+		{name: "Missing custom roles", body: `{"custom_resources": []}`, wantErr: true},
+		{name: "Null custom roles", body: `{"custom_roles": null, "custom_resources": []}`, wantErr: true},
+		{name: "Unknown field", body: `{"custom_roles": [], "custom_resources": [], "extra": true}`, wantErr: true},
+		{name: "Not JSON", body: `custom_roles: []`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decode([]byte(tt.body))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("decode() expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode() unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package desired
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/storekey"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// FileSource reads dir/<project_id>.json, typically from a directory shipped
+// in the deployment bundle.
+type FileSource struct {
+	dir string
+}
+
+func NewFileSource(dir string) *FileSource {
+	return &FileSource{dir: dir}
+}
+
+func (s *FileSource) Load(ctx context.Context, projectID string) (rbacpolicy.Policy, error) {
+	if !storekey.Valid(projectID) {
+		return rbacpolicy.Policy{}, ErrNotFound
+	}
+
+	body, err := os.ReadFile(filepath.Join(s.dir, projectID+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return rbacpolicy.Policy{}, ErrNotFound
+	}
+	if err != nil {
+		return rbacpolicy.Policy{}, fmt.Errorf("failed to read desired policy: %w", err)
+	}
+	return decode(body)
+}
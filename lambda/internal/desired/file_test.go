@@ -0,0 +1,29 @@
+package desired
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	testSource(t, NewFileSource(dir), func(projectID, body string) {
+		if err := os.WriteFile(filepath.Join(dir, projectID+".json"), []byte(body), 0o600); err != nil {
+			t.Fatalf("Failed to write desired policy: %v", err)
+		}
+	})
+}
+
+func TestFileSourceReadError(t *testing.T) {
+	dir := t.TempDir()
+	// A directory where the file should be cannot be read.
+	if err := os.Mkdir(filepath.Join(dir, "project-live.json"), 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	if _, err := NewFileSource(dir).Load(context.Background(), "project-live"); err == nil {
+		t.Error("Load() expected error but got none")
+	}
+}
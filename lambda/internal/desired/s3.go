@@ -0,0 +1,68 @@
+package desired
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/storekey"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// S3API is the subset of the S3 client used by S3Source.
+type S3API interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// S3Source reads <prefix>/<project_id>.json from a bucket.
+type S3Source struct {
+	client S3API
+	bucket string
+	prefix string
+}
+
+func NewS3Source(client S3API, bucket, prefix string) *S3Source {
+	return &S3Source{
+		client: client,
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+func (s *S3Source) Load(ctx context.Context, projectID string) (rbacpolicy.Policy, error) {
+	if !storekey.Valid(projectID) {
+		return rbacpolicy.Policy{}, ErrNotFound
+	}
+
+	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(projectID)),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return rbacpolicy.Policy{}, ErrNotFound
+	}
+	if err != nil {
+		return rbacpolicy.Policy{}, fmt.Errorf("failed to get desired policy: %w", err)
+	}
+	defer obj.Body.Close()
+
+	body, err := io.ReadAll(obj.Body)
+	if err != nil {
+		return rbacpolicy.Policy{}, fmt.Errorf("failed to read desired policy: %w", err)
+	}
+	return decode(body)
+}
+
+func (s *S3Source) key(projectID string) string {
+	key := projectID + ".json"
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	return key
+}
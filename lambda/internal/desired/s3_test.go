@@ -0,0 +1,59 @@
+package desired
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeS3 is an in-memory S3API keyed by bucket/key.
+type fakeS3 struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	body, ok := f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestS3Source(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefix    string
+		keyPrefix string
+	}{
+		{name: "Without prefix", prefix: "", keyPrefix: ""},
+		{name: "With prefix", prefix: "/policies/", keyPrefix: "policies/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeS3{objects: make(map[string][]byte)}
+			testSource(t, NewS3Source(fake, "desired", tt.prefix), func(projectID, body string) {
+				fake.objects["desired/"+tt.keyPrefix+projectID+".json"] = []byte(body)
+			})
+		})
+	}
+}
+
+func TestS3SourceError(t *testing.T) {
+	fake := &fakeS3{err: errors.New("access denied")}
+
+	_, err := NewS3Source(fake, "desired", "").Load(context.Background(), "project-live")
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Load() error = %v, want a wrapped S3 error", err)
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/audit"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/authz"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+// auditAttempt is how a mutation attempt ended. before is nil until the live
+// policy has been read, and after until the proposed policy is known.
+type auditAttempt struct {
+	outcome audit.Outcome
+	err     error
+	before  *rbacpolicy.Policy
+	after   *rbacpolicy.Policy
+}
+
+// recordAudit emits the audit record for a mutation attempt. A stored write
+// has already changed the policy, and a refused one has already failed, so a
+// sink failure is logged rather than returned.
+func (h *Handler) recordAudit(ctx context.Context, request events.ALBTargetGroupRequest, attempt auditAttempt) {
+	record := audit.Record{
+		Time:      time.Now().UTC(),
+		TraceID:   header(request, "X-Amzn-Trace-Id"),
+		ProjectID: h.project(ctx),
+		Method:    request.HTTPMethod,
+		Path:      requestPath(ctx, request),
+		SourceIP:  sourceIP(request),
+		Outcome:   attempt.outcome,
+	}
+	if attempt.err != nil {
+		record.Error = attempt.err.Error()
+	}
+	if attempt.before != nil {
+		record.BeforeHash = rbac.Hash(*attempt.before)
+		if attempt.after != nil {
+			record.AfterHash = rbac.Hash(*attempt.after)
+			diff := rbac.Compare(*attempt.before, *attempt.after)
+			record.Diff = &diff
+		}
+	}
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		record.RequestID = lc.AwsRequestID
+	}
+	if identity, ok := auth.IdentityFromContext(ctx); ok {
+		record.Caller = audit.Caller{Subject: identity.Subject, Email: identity.Email}
+	}
+
+	if audited, ok := ctx.Value(auditMarkKey{}).(*atomic.Bool); ok {
+		audited.Store(true)
+	}
+	if err := h.audit.Write(ctx, record); err != nil {
+		h.logger.Error("Failed to write audit record",
+			zap.Error(err),
+			zap.String("outcome", string(record.Outcome)),
+			zap.String("caller_sub", record.Caller.Subject),
+			zap.String("before_hash", record.BeforeHash),
+			zap.String("after_hash", record.AfterHash),
+		)
+	}
+}
+
+type auditMarkKey struct{}
+
+// withAuditMark returns a context whose flag recordAudit sets, so that
+// HandleRequest can tell whether the handler already audited the write.
+func withAuditMark(ctx context.Context) (context.Context, *atomic.Bool) {
+	audited := &atomic.Bool{}
+	return context.WithValue(ctx, auditMarkKey{}, audited), audited
+}
+
+// refusedWrite is the audit attempt for a write its handler refused without
+// auditing: rejected for a 4xx and failed for a 5xx, with the message from
+// the error response.
+func refusedWrite(response events.ALBTargetGroupResponse) auditAttempt {
+	var body struct {
+		Error string `json:"error"`
+	}
+	_ = json.Unmarshal([]byte(response.Body), &body)
+	if body.Error == "" {
+		body.Error = http.StatusText(response.StatusCode)
+	}
+
+	outcome := audit.OutcomeRejected
+	if response.StatusCode >= http.StatusInternalServerError {
+		outcome = audit.OutcomeFailed
+	}
+	return auditAttempt{outcome: outcome, err: errors.New(body.Error)}
+}
+
+// isAuditedWrite reports whether request is a write that is audited even when
+// it is refused before reaching updatePolicy: anything but a read or a dry
+// run.
+func isAuditedWrite(request events.ALBTargetGroupRequest) bool {
+	if capability, ok := routeCapability(request); ok && capability == authz.ReadPolicy {
+		return false
+	}
+	dryRun, err := parseBoolParam(queryParam(request, "dry_run"))
+	return err != nil || !dryRun
+}
+
+// sourceIP returns the address the ALB received the request from. ALB
+// appends it to X-Forwarded-For, so earlier entries are client-supplied and
+// cannot be trusted.
+func sourceIP(request events.ALBTargetGroupRequest) string {
+	forwarded := header(request, "X-Forwarded-For")
+	if i := strings.LastIndex(forwarded, ","); i >= 0 {
+		forwarded = forwarded[i+1:]
+	}
+	return strings.TrimSpace(forwarded)
+}
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/audit"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/authz"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"go.uber.org/zap"
+)
+
+// recordingSink is an audit.Sink that keeps records in memory.
+type recordingSink struct {
+	records []audit.Record
+	err     error
+}
+
+func (s *recordingSink) Write(ctx context.Context, record audit.Record) error {
+	s.records = append(s.records, record)
+	return s.err
+}
+
+func TestAuditRecord(t *testing.T) {
+	policy := testRolePolicy()
+	before := rbac.Clone(policy)
+	sink := &recordingSink{}
+	h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop(),
+		WithAuthenticator(groupAuthenticator{}),
+		WithAuditSink(sink),
+	)
+
+	ctx := lambdacontext.NewContext(context.Background(), &lambdacontext.LambdaContext{AwsRequestID: "lambda-request-1"})
+	response, err := h.HandleRequest(ctx, events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodDelete,
+		Path:       "/rbacpolicy/roles/viewer",
+		Headers: map[string]string{
+			"x-amzn-oidc-data": "user-1",
+			"x-amzn-trace-id":  "Root=1-abc",
+			"x-forwarded-for":  "10.0.0.1, 203.0.113.7",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, response.StatusCode)
+	}
+
+	if len(sink.records) != 1 {
+		t.Fatalf("Expected one audit record, got %d", len(sink.records))
+	}
+	record := sink.records[0]
+	if record.Caller.Subject != "user-1" {
+		t.Errorf("Expected caller user-1, got %+v", record.Caller)
+	}
+	if record.SourceIP != "203.0.113.7" {
+		t.Errorf("Expected source IP added by ALB, got %q", record.SourceIP)
+	}
+	if record.RequestID != "lambda-request-1" || record.TraceID != "Root=1-abc" {
+		t.Errorf("Unexpected request IDs %q, %q", record.RequestID, record.TraceID)
+	}
+	if record.ProjectID != "test-project-id" || record.Method != http.MethodDelete || record.Path != "/rbacpolicy/roles/viewer" {
+		t.Errorf("Unexpected request fields %+v", record)
+	}
+	if record.BeforeHash != rbac.Hash(before) || record.AfterHash != rbac.Hash(policy) {
+		t.Errorf("Unexpected hashes %q, %q", record.BeforeHash, record.AfterHash)
+	}
+	if len(record.Diff.RolesRemoved) != 1 || record.Diff.RolesRemoved[0] != "viewer" {
+		t.Errorf("Expected viewer removal in diff, got %+v", record.Diff)
+	}
+	if record.Outcome != audit.OutcomeSuccess || record.Error != "" {
+		t.Errorf("Expected a successful outcome, got %q %q", record.Outcome, record.Error)
+	}
+	if record.Time.IsZero() {
+		t.Error("Expected record time")
+	}
+}
+
+func TestAuditOutcomes(t *testing.T) {
+	authorizer, err := authz.New(authz.Config{Rules: []authz.Rule{
+		{Groups: []string{"admin"}, Capabilities: []authz.Capability{authz.ReadPolicy, authz.WriteRoles, authz.WriteResources, authz.DeletePolicy, authz.RestoreHistory}},
+		{Groups: []string{"reader"}, Capabilities: []authz.Capability{authz.ReadPolicy}},
+		{Groups: []string{"editors"}, Capabilities: []authz.Capability{authz.WriteRoles}, RolePrefixes: []string{"edit"}},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		method     string
+		path       string
+		query      map[string]string
+		headers    map[string]string
+		body       string
+		failGet    bool
+		failSet    bool
+		store      history.Store
+		records    int
+		outcome    audit.Outcome
+		wantBefore bool
+		wantDiff   bool
+	}{
+		{name: "Read", method: http.MethodGet, path: "/rbacpolicy", records: 0},
+		{name: "Dry run", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", query: map[string]string{"dry_run": "true"}, records: 0},
+		{name: "Stored write", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", records: 1, outcome: audit.OutcomeSuccess, wantBefore: true, wantDiff: true},
+		{name: "Whole policy delete", method: http.MethodDelete, path: "/rbacpolicy", records: 1, outcome: audit.OutcomeSuccess, wantBefore: true, wantDiff: true},
+		{name: "Missing role", method: http.MethodDelete, path: "/rbacpolicy/roles/missing", records: 1, outcome: audit.OutcomeRejected, wantBefore: true},
+		{
+			name:       "Invalid policy",
+			method:     http.MethodPut,
+			path:       "/rbacpolicy/roles/editor",
+			body:       `{"description": "Editor", "permissions": [{"resource_id": "reports", "actions": ["read"]}]}`,
+			records:    1,
+			outcome:    audit.OutcomeInvalid,
+			wantBefore: true,
+			wantDiff:   true,
+		},
+		{name: "Stale ETag", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", headers: map[string]string{"If-Match": `"stale"`}, records: 1, outcome: audit.OutcomeStale, wantBefore: true},
+		{name: "Denied route", token: "reader", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", records: 1, outcome: audit.OutcomeDenied},
+		{name: "Denied route dry run", token: "reader", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", query: map[string]string{"dry_run": "true"}, records: 0},
+		{name: "Denied change", token: "editors", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", records: 1, outcome: audit.OutcomeDenied, wantBefore: true, wantDiff: true},
+		{name: "Denied change dry run", token: "editors", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", query: map[string]string{"dry_run": "true"}, records: 0},
+		{name: "Stytch read failure", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", failGet: true, records: 1, outcome: audit.OutcomeFailed},
+		{name: "Stytch write failure", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", failSet: true, records: 1, outcome: audit.OutcomeFailed, wantBefore: true, wantDiff: true},
+		{name: "Snapshot failure", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", store: failingStore{}, records: 1, outcome: audit.OutcomeFailed, wantBefore: true, wantDiff: true},
+		{name: "Invalid body", method: http.MethodPut, path: "/rbacpolicy", body: `{"custom_roles":`, records: 1, outcome: audit.OutcomeRejected},
+		{name: "Role ID mismatch", method: http.MethodPut, path: "/rbacpolicy/roles/editor", body: `{"role_id": "viewer", "description": "Viewer"}`, records: 1, outcome: audit.OutcomeRejected},
+		{name: "Unsupported patch type", method: http.MethodPatch, path: "/rbacpolicy", headers: map[string]string{"Content-Type": "text/plain"}, body: `{}`, records: 1, outcome: audit.OutcomeRejected},
+		{name: "Promote to unknown project", method: http.MethodPost, path: "/rbacpolicy/promote", body: `{"from": "test-project-id", "to": "unknown"}`, records: 1, outcome: audit.OutcomeRejected},
+		{name: "Promote to itself", method: http.MethodPost, path: "/rbacpolicy/promote", body: `{"from": "test-project-id", "to": "test-project-id"}`, records: 1, outcome: audit.OutcomeRejected},
+		{name: "Promote source read failure", method: http.MethodPost, path: "/rbacpolicy/promote", body: `{"from": "staging", "to": "test-project-id"}`, failGet: true, records: 1, outcome: audit.OutcomeFailed},
+		{name: "Restore missing snapshot", method: http.MethodPost, path: "/rbacpolicy/history/missing/restore", records: 1, outcome: audit.OutcomeRejected},
+		{name: "Invalid cascade", method: http.MethodDelete, path: "/rbacpolicy/resources/documents", query: map[string]string{"cascade": "maybe"}, records: 1, outcome: audit.OutcomeRejected},
+		{name: "Invalid dry run", method: http.MethodDelete, path: "/rbacpolicy/roles/viewer", query: map[string]string{"dry_run": "maybe"}, records: 1, outcome: audit.OutcomeRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			client := newStatefulMock(&policy)
+			if tt.failGet {
+				client.getFunc = nil
+			}
+			if tt.failSet {
+				client.setFunc = nil
+			}
+			sink := &recordingSink{}
+			opts := []Option{
+				WithAuthenticator(groupAuthenticator{}),
+				WithAuthorizer(authorizer),
+				WithAuditSink(sink),
+				WithProjects(map[string]string{"staging": "project-staging"}),
+			}
+			if tt.store != nil {
+				opts = append(opts, WithHistoryStore(tt.store))
+			}
+			h := NewHandler(client, "test-project-id", zap.NewNop(), opts...)
+
+			token := tt.token
+			if token == "" {
+				token = "admin"
+			}
+			headers := map[string]string{"x-amzn-oidc-data": token}
+			for name, value := range tt.headers {
+				headers[name] = value
+			}
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod:            tt.method,
+				Path:                  tt.path,
+				QueryStringParameters: tt.query,
+				Headers:               headers,
+				Body:                  tt.body,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(sink.records) != tt.records {
+				t.Fatalf("Expected %d audit records, got %d: %+v", tt.records, len(sink.records), sink.records)
+			}
+			if tt.records == 0 {
+				return
+			}
+
+			record := sink.records[0]
+			if record.Outcome != tt.outcome {
+				t.Errorf("Expected outcome %q, got %q", tt.outcome, record.Outcome)
+			}
+			if record.Caller.Subject != token {
+				t.Errorf("Expected caller %q, got %+v", token, record.Caller)
+			}
+			if succeeded := tt.outcome == audit.OutcomeSuccess; succeeded != (record.Error == "") {
+				t.Errorf("Expected error only for unsuccessful attempts, got %q for status %d", record.Error, response.StatusCode)
+			}
+			if (record.BeforeHash != "") != tt.wantBefore {
+				t.Errorf("Expected before hash %v, got %q", tt.wantBefore, record.BeforeHash)
+			}
+			if (record.Diff != nil) != tt.wantDiff || (record.AfterHash != "") != tt.wantDiff {
+				t.Errorf("Expected diff and after hash %v, got %+v and %q", tt.wantDiff, record.Diff, record.AfterHash)
+			}
+		})
+	}
+}
+
+func TestAuditSinkFailureDoesNotFailWrite(t *testing.T) {
+	policy := testRolePolicy()
+	sink := &recordingSink{err: errors.New("disk full")}
+	h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop(), WithAuditSink(sink))
+
+	response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodDelete,
+		Path:       "/rbacpolicy/roles/viewer",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status code %d, got %d", http.StatusNoContent, response.StatusCode)
+	}
+}
+
+func TestSourceIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected string
+	}{
+		{name: "Single address", headers: map[string]string{"X-Forwarded-For": "203.0.113.7"}, expected: "203.0.113.7"},
+		{name: "Spoofed prefix", headers: map[string]string{"X-Forwarded-For": "1.2.3.4,203.0.113.7"}, expected: "203.0.113.7"},
+		{name: "Missing header", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sourceIP(events.ALBTargetGroupRequest{Headers: tt.headers}); got != tt.expected {
+				t.Errorf("sourceIP() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth"
+	"go.uber.org/zap"
+)
+
+// Authenticator verifies the token ALB attaches to authenticated requests.
+type Authenticator interface {
+	Verify(ctx context.Context, token string) (*auth.Identity, error)
+}
+
+// authenticate verifies the caller's ALB OIDC token and returns ctx with the
+// caller's identity attached.
+func (h *Handler) authenticate(ctx context.Context, request events.ALBTargetGroupRequest) (context.Context, error) {
+	token := header(request, auth.TokenHeader)
+	if token == "" {
+		h.logger.Info("Rejecting unauthenticated request")
+		return nil, &statusError{
+			statusCode: http.StatusUnauthorized,
+			message:    "Authentication required",
+		}
+	}
+
+	identity, err := h.authenticator.Verify(ctx, token)
+	if err != nil {
+		h.logger.Info("Rejecting request with invalid token", zap.Error(err))
+		return nil, &statusError{
+			statusCode: http.StatusUnauthorized,
+			message:    "Invalid authentication token",
+		}
+	}
+
+	h.logger.Info("Authenticated caller",
+		zap.String("sub", identity.Subject),
+		zap.String("email", identity.Email),
+	)
+	return auth.WithIdentity(ctx, identity), nil
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth/authtest"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func TestAuthentication(t *testing.T) {
+	signer, err := authtest.NewSigner()
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	defer signer.Close()
+
+	tests := []struct {
+		name           string
+		path           string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{
+			name:           "Valid token",
+			path:           "/rbacpolicy",
+			headers:        map[string]string{"X-Amzn-Oidc-Data": signer.Token("user-1", nil)},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing token",
+			path:           "/rbacpolicy",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Invalid token",
+			path:           "/rbacpolicy",
+			headers:        map[string]string{"X-Amzn-Oidc-Data": signer.Token("user-1", map[string]any{"iss": "https://other.example.com"})},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Health check is not authenticated",
+			path:           "/rbacpolicy/health",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			verifier := auth.NewVerifier(authtest.Issuer, auth.NewHTTPKeySource(signer.URL, nil))
+			h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop(), WithAuthenticator(verifier))
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       tt.path,
+				Headers:    tt.headers,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+		})
+	}
+}
+
+func TestAuthenticatedIdentityInContext(t *testing.T) {
+	signer, err := authtest.NewSigner()
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	defer signer.Close()
+
+	var caller *auth.Identity
+	client := &mockRBACPolicyClient{
+		getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+			caller, _ = auth.IdentityFromContext(ctx)
+			return &rbacpolicy.GetResponse{StatusCode: 200}, nil
+		},
+	}
+	verifier := auth.NewVerifier(authtest.Issuer, auth.NewHTTPKeySource(signer.URL, nil))
+	h := NewHandler(client, "test-project-id", zap.NewNop(), WithAuthenticator(verifier))
+
+	_, err = h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodGet,
+		Path:       "/rbacpolicy",
+		MultiValueHeaders: map[string][]string{
+			"x-amzn-oidc-data": {signer.Token("user-1", map[string]any{"email": "user@example.com"})},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if caller == nil || caller.Subject != "user-1" || caller.Email != "user@example.com" {
+		t.Errorf("Expected caller identity in context, got %+v", caller)
+	}
+}
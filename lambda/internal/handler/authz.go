@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/authz"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"go.uber.org/zap"
+)
+
+// Authorizer decides what an authenticated caller may do.
+type Authorizer interface {
+	Permissions(identity *auth.Identity) authz.Permissions
+}
+
+// routeCapability returns the capability a request needs before any work is
+// done, decided from the endpoint that serves it. Writes other than deleting
+// the whole policy or restoring a snapshot need write_roles or
+// write_resources, and are then checked role by role in authorizeChange once
+// the resulting diff is known. Paths no endpoint serves only ever get a 404,
+// so read_policy is enough to be told so.
+func routeCapability(request events.ALBTargetGroupRequest) (authz.Capability, bool) {
+	ep := resolveEndpoint(request.Path)
+	switch {
+	case request.HTTPMethod == http.MethodGet || request.HTTPMethod == http.MethodHead:
+		return authz.ReadPolicy, true
+	case ep.kind == endpointNotFound, ep.kind == endpointDiff, ep.kind == endpointCheck,
+		ep.kind == endpointHealth, ep.kind == endpointReady:
+		return authz.ReadPolicy, true
+	case ep.kind == endpointPolicy && request.HTTPMethod == http.MethodDelete:
+		return authz.DeletePolicy, true
+	case ep.kind == endpointSnapshot && request.HTTPMethod == http.MethodPost && ep.sub == "restore":
+		return authz.RestoreHistory, true
+	default:
+		return "", false
+	}
+}
+
+// authorize checks the caller may use the requested route at all.
+func (h *Handler) authorize(ctx context.Context, request events.ALBTargetGroupRequest) error {
+	if h.authorizer == nil {
+		return nil
+	}
+	permissions := h.permissions(ctx)
+
+	if capability, ok := routeCapability(request); ok {
+		return h.denied(ctx, permissions.Check(capability))
+	}
+	if !permissions.Has(authz.WriteRoles) && !permissions.Has(authz.WriteResources) {
+		return h.denied(ctx, &authz.DeniedError{Capability: authz.WriteRoles})
+	}
+	return nil
+}
+
+// authorizeChange checks the caller may make every change in diff. Deleting
+// the policy and restoring a snapshot are authorized by their own
+// capabilities instead, and scheduled invocations are not authorized at all.
+func (h *Handler) authorizeChange(ctx context.Context, request events.ALBTargetGroupRequest, diff rbac.Diff) error {
+	if h.authorizer == nil || isScheduled(ctx) {
+		return nil
+	}
+	if _, ok := routeCapability(request); ok {
+		return nil
+	}
+	return h.denied(ctx, h.permissions(ctx).CheckChange(diff))
+}
+
+func (h *Handler) permissions(ctx context.Context) authz.Permissions {
+	identity, _ := auth.IdentityFromContext(ctx)
+	return h.authorizer.Permissions(identity)
+}
+
+// denied converts an authz.DeniedError into a 403.
+func (h *Handler) denied(ctx context.Context, err error) error {
+	var denied *authz.DeniedError
+	if !errors.As(err, &denied) {
+		return err
+	}
+
+	identity, _ := auth.IdentityFromContext(ctx)
+	subject := ""
+	if identity != nil {
+		subject = identity.Subject
+	}
+	h.logger.Info("Denying request",
+		zap.String("sub", subject),
+		zap.String("capability", string(denied.Capability)),
+		zap.String("role_id", denied.RoleID),
+	)
+
+	details := map[string]any{"capability": denied.Capability}
+	if denied.RoleID != "" {
+		details["role_id"] = denied.RoleID
+	}
+	return &statusError{
+		statusCode: http.StatusForbidden,
+		message:    "Forbidden: " + denied.Error(),
+		details:    details,
+	}
+}
@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/authz"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"go.uber.org/zap"
+)
+
+// groupAuthenticator treats the token as the caller's only group.
+type groupAuthenticator struct{}
+
+func (groupAuthenticator) Verify(ctx context.Context, token string) (*auth.Identity, error) {
+	if token == "" {
+		return nil, errors.New("empty token")
+	}
+	return &auth.Identity{Subject: token, Claims: map[string]any{"groups": token}}, nil
+}
+
+func TestAuthorization(t *testing.T) {
+	authorizer, err := authz.New(authz.Config{Rules: []authz.Rule{
+		{Groups: []string{"admin"}, Capabilities: []authz.Capability{authz.ReadPolicy, authz.WriteRoles, authz.WriteResources, authz.DeletePolicy, authz.RestoreHistory}},
+		{Groups: []string{"reader"}, Capabilities: []authz.Capability{authz.ReadPolicy}},
+		{Groups: []string{"writer"}, Capabilities: []authz.Capability{authz.ReadPolicy, authz.WriteRoles, authz.WriteResources}},
+		{Groups: []string{"editors"}, Capabilities: []authz.Capability{authz.WriteRoles}, RolePrefixes: []string{"edit"}},
+		{Groups: []string{"restorer"}, Capabilities: []authz.Capability{authz.RestoreHistory}},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name               string
+		group              string
+		method             string
+		path               string
+		body               string
+		query              map[string]string
+		expectedStatus     int
+		expectedCapability authz.Capability
+		expectedRoleID     string
+	}{
+		{
+			name:           "Reader can read",
+			group:          "reader",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/roles/viewer",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Reader can check",
+			group:          "reader",
+			method:         http.MethodPost,
+			path:           "/rbacpolicy/check",
+			body:           `{"roles":["viewer"],"resource_id":"documents","action":"read"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:               "Editor cannot read",
+			group:              "editors",
+			method:             http.MethodGet,
+			path:               "/rbacpolicy",
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.ReadPolicy,
+		},
+		{
+			name:               "Reader cannot write",
+			group:              "reader",
+			method:             http.MethodPut,
+			path:               "/rbacpolicy/roles/editor",
+			body:               `{"description":"Editors","permissions":[]}`,
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.WriteRoles,
+		},
+		{
+			name:           "Editor writes owned role",
+			group:          "editors",
+			method:         http.MethodPut,
+			path:           "/rbacpolicy/roles/editor",
+			body:           `{"description":"Editors","permissions":[]}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:               "Editor cannot write other role",
+			group:              "editors",
+			method:             http.MethodDelete,
+			path:               "/rbacpolicy/roles/viewer",
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.WriteRoles,
+			expectedRoleID:     "viewer",
+		},
+		{
+			name:               "Editor dry run is also denied",
+			group:              "editors",
+			method:             http.MethodDelete,
+			path:               "/rbacpolicy/roles/viewer",
+			query:              map[string]string{"dry_run": "true"},
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.WriteRoles,
+			expectedRoleID:     "viewer",
+		},
+		{
+			name:               "Editor cannot replace whole policy",
+			group:              "editors",
+			method:             http.MethodPut,
+			path:               "/rbacpolicy",
+			body:               `{"custom_roles":[],"custom_resources":[]}`,
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.WriteRoles,
+			expectedRoleID:     "viewer",
+		},
+		{
+			name:               "Editor cannot cascade into other roles",
+			group:              "editors",
+			method:             http.MethodDelete,
+			path:               "/rbacpolicy/resources/documents",
+			query:              map[string]string{"cascade": "true"},
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.WriteRoles,
+			expectedRoleID:     "viewer",
+		},
+		{
+			name:               "Editor cannot write resources",
+			group:              "editors",
+			method:             http.MethodPut,
+			path:               "/rbacpolicy/resources/reports",
+			body:               `{"available_actions":["read"]}`,
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.WriteResources,
+		},
+		{
+			name:               "Editor cannot delete policy",
+			group:              "editors",
+			method:             http.MethodDelete,
+			path:               "/rbacpolicy",
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.DeletePolicy,
+		},
+		{
+			name:               "Writer cannot delete policy",
+			group:              "writer",
+			method:             http.MethodDelete,
+			path:               "/rbacpolicy",
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.DeletePolicy,
+		},
+		{
+			name:           "Trailing slash does not reach the whole policy",
+			group:          "writer",
+			method:         http.MethodDelete,
+			path:           "/rbacpolicy/",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Mistyped collection does not reach the whole policy",
+			group:          "writer",
+			method:         http.MethodDelete,
+			path:           "/rbacpolicy/rolez",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Mistyped role path does not reach the whole policy",
+			group:          "writer",
+			method:         http.MethodDelete,
+			path:           "/rbacpolicy/role/editor",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Unknown path",
+			group:          "writer",
+			method:         http.MethodDelete,
+			path:           "/anything",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Reader is told an unknown path is not found",
+			group:          "reader",
+			method:         http.MethodPut,
+			path:           "/rbacpolicy/",
+			body:           `{"custom_roles":[],"custom_resources":[]}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:               "Unknown caller cannot probe unknown paths",
+			group:              "nobody",
+			method:             http.MethodDelete,
+			path:               "/anything",
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.ReadPolicy,
+		},
+		{
+			name:           "Restore with trailing slash",
+			group:          "writer",
+			method:         http.MethodPost,
+			path:           "/rbacpolicy/history/v1/restore/",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Admin deletes policy",
+			group:          "admin",
+			method:         http.MethodDelete,
+			path:           "/rbacpolicy",
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:               "Reader cannot restore",
+			group:              "reader",
+			method:             http.MethodPost,
+			path:               "/rbacpolicy/history/v1/restore",
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.RestoreHistory,
+		},
+		{
+			name:           "Restorer restores without role capabilities",
+			group:          "restorer",
+			method:         http.MethodPost,
+			path:           "/rbacpolicy/history/v1/restore",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:               "Unknown caller",
+			group:              "nobody",
+			method:             http.MethodGet,
+			path:               "/rbacpolicy",
+			expectedStatus:     http.StatusForbidden,
+			expectedCapability: authz.ReadPolicy,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			original := rbac.Clone(policy)
+			store := history.NewMemoryStore()
+			if _, err := store.Save(context.Background(), history.Snapshot{Version: "v1", ProjectID: "test-project-id", Policy: rbac.Clone(policy)}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop(),
+				WithHistoryStore(store),
+				WithAuthenticator(groupAuthenticator{}),
+				WithAuthorizer(authorizer),
+			)
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod:            tt.method,
+				Path:                  tt.path,
+				Body:                  tt.body,
+				QueryStringParameters: tt.query,
+				Headers:               map[string]string{"x-amzn-oidc-data": tt.group},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			if tt.expectedStatus != http.StatusForbidden && tt.expectedStatus != http.StatusNotFound {
+				return
+			}
+
+			if !reflect.DeepEqual(policy, original) {
+				t.Errorf("Expected stored policy to be unchanged, got %+v", policy)
+			}
+			if tt.expectedStatus == http.StatusNotFound {
+				return
+			}
+			var body map[string]string
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if body["capability"] != string(tt.expectedCapability) {
+				t.Errorf("Expected capability %q, got %q", tt.expectedCapability, body["capability"])
+			}
+			if body["role_id"] != tt.expectedRoleID {
+				t.Errorf("Expected role_id %q, got %q", tt.expectedRoleID, body["role_id"])
+			}
+		})
+	}
+}
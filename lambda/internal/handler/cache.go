@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// policyCache holds recently fetched policies per project. It lives as long
+// as the Handler, which on Lambda means across invocations on a warm
+// container. Writes made by other containers or directly in Stytch are seen
+// once the entry expires.
+type policyCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	policy  rbacpolicy.Policy
+	expires time.Time
+}
+
+func newPolicyCache(ttl time.Duration) *policyCache {
+	return &policyCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]cacheEntry),
+	}
+}
+
+func (c *policyCache) get(projectID string) (rbacpolicy.Policy, bool) {
+	if c.ttl <= 0 {
+		return rbacpolicy.Policy{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[projectID]
+	if !ok || !c.now().Before(entry.expires) {
+		return rbacpolicy.Policy{}, false
+	}
+	return rbac.Clone(entry.policy), true
+}
+
+func (c *policyCache) put(projectID string, policy rbacpolicy.Policy) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[projectID] = cacheEntry{
+		policy:  rbac.Clone(policy),
+		expires: c.now().Add(c.ttl),
+	}
+}
+
+func (c *policyCache) invalidate(projectID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, projectID)
+}
+
+// noCache reports whether the caller asked for a fresh read with
+// Cache-Control: no-cache (or the HTTP/1.0 Pragma equivalent).
+func noCache(request events.ALBTargetGroupRequest) bool {
+	for _, name := range []string{"Cache-Control", "Pragma"} {
+		for _, directive := range strings.Split(header(request, name), ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+// countingMock wraps newStatefulMock and counts Get calls.
+func countingMock(policy *rbacpolicy.Policy, gets *int) *mockRBACPolicyClient {
+	client := newStatefulMock(policy)
+	get := client.getFunc
+	client.getFunc = func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+		*gets++
+		return get(ctx, body)
+	}
+	return client
+}
+
+func TestPolicyCache(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	cache := newPolicyCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	if _, ok := cache.get("project-a"); ok {
+		t.Fatal("Expected empty cache")
+	}
+
+	policy := testRolePolicy()
+	cache.put("project-a", policy)
+	policy.CustomRoles[0].RoleID = "changed"
+
+	got, ok := cache.get("project-a")
+	if !ok {
+		t.Fatal("Expected cached policy")
+	}
+	if got.CustomRoles[0].RoleID != "editor" {
+		t.Errorf("Expected cache to hold a copy, got %q", got.CustomRoles[0].RoleID)
+	}
+	got.CustomRoles[0].RoleID = "changed"
+	if again, _ := cache.get("project-a"); again.CustomRoles[0].RoleID != "editor" {
+		t.Errorf("Expected cache to return copies, got %q", again.CustomRoles[0].RoleID)
+	}
+	if _, ok := cache.get("project-b"); ok {
+		t.Error("Expected entries to be per project")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.get("project-a"); ok {
+		t.Error("Expected entry to expire after the TTL")
+	}
+
+	cache.put("project-a", testRolePolicy())
+	cache.invalidate("project-a")
+	if _, ok := cache.get("project-a"); ok {
+		t.Error("Expected invalidated entry to be gone")
+	}
+}
+
+func TestPolicyCacheDisabled(t *testing.T) {
+	cache := newPolicyCache(0)
+	cache.put("project-a", testRolePolicy())
+	if _, ok := cache.get("project-a"); ok {
+		t.Error("Expected zero TTL to disable the cache")
+	}
+}
+
+func TestCachedReads(t *testing.T) {
+	tests := []struct {
+		name         string
+		ttl          time.Duration
+		requests     []events.ALBTargetGroupRequest
+		expectedGets int
+	}{
+		{
+			name: "Repeated reads hit the cache",
+			ttl:  time.Minute,
+			requests: []events.ALBTargetGroupRequest{
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/roles/editor"},
+			},
+			expectedGets: 1,
+		},
+		{
+			name: "Cache-Control no-cache bypasses the cache",
+			ttl:  time.Minute,
+			requests: []events.ALBTargetGroupRequest{
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy", Headers: map[string]string{"Cache-Control": "max-age=0, no-cache"}},
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy", MultiValueHeaders: map[string][]string{"pragma": {"no-cache"}}},
+			},
+			expectedGets: 3,
+		},
+		{
+			name: "Writes read Stytch and invalidate the cache",
+			ttl:  time.Minute,
+			requests: []events.ALBTargetGroupRequest{
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+				{HTTPMethod: http.MethodDelete, Path: "/rbacpolicy/roles/viewer"},
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+			},
+			expectedGets: 3,
+		},
+		{
+			name: "Check and diff read Stytch",
+			ttl:  time.Minute,
+			requests: []events.ALBTargetGroupRequest{
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+				{HTTPMethod: http.MethodPost, Path: "/rbacpolicy/check", Body: `{"roles": ["editor"], "resource_id": "documents", "action": "read"}`},
+				{HTTPMethod: http.MethodPost, Path: "/rbacpolicy/diff", Body: `{}`},
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+			},
+			expectedGets: 3,
+		},
+		{
+			name: "Dry runs leave the cache alone",
+			ttl:  time.Minute,
+			requests: []events.ALBTargetGroupRequest{
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+				{HTTPMethod: http.MethodDelete, Path: "/rbacpolicy/roles/viewer", QueryStringParameters: map[string]string{"dry_run": "true"}},
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+			},
+			expectedGets: 2,
+		},
+		{
+			name: "Disabled by default",
+			requests: []events.ALBTargetGroupRequest{
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+				{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+			},
+			expectedGets: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			gets := 0
+			var opts []Option
+			if tt.ttl > 0 {
+				opts = append(opts, WithCacheTTL(tt.ttl))
+			}
+			h := NewHandler(countingMock(&policy, &gets), "test-project-id", zap.NewNop(), opts...)
+
+			for _, request := range tt.requests {
+				response, err := h.HandleRequest(context.Background(), request)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if response.StatusCode >= 400 {
+					t.Fatalf("%s %s: unexpected status %d: %s", request.HTTPMethod, request.Path, response.StatusCode, response.Body)
+				}
+			}
+			if gets != tt.expectedGets {
+				t.Errorf("Expected %d Stytch reads, got %d", tt.expectedGets, gets)
+			}
+		})
+	}
+}
+
+func TestCachedReadAfterWriteIsFresh(t *testing.T) {
+	policy := testRolePolicy()
+	h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop(), WithCacheTTL(time.Minute))
+	ctx := context.Background()
+
+	if _, err := h.HandleRequest(ctx, events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/roles/viewer"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := h.HandleRequest(ctx, events.ALBTargetGroupRequest{HTTPMethod: http.MethodDelete, Path: "/rbacpolicy/roles/viewer"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	response, err := h.HandleRequest(ctx, events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/roles/viewer"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected deleted role to be gone, got status %d", response.StatusCode)
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"go.uber.org/zap"
+)
+
+type checkRequest struct {
+	Roles      []string `json:"roles"`
+	ResourceID string   `json:"resource_id"`
+	Action     string   `json:"action"`
+}
+
+// handleCheck evaluates whether any of the given roles may perform an action
+// on a resource under the live policy, read from Stytch rather than the cache
+// since callers act on the decision. Denials are still a 200; the decision is
+// in the body.
+func (h *Handler) handleCheck(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	if request.HTTPMethod != http.MethodPost {
+		return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+
+	var check checkRequest
+	if err := json.Unmarshal([]byte(request.Body), &check); err != nil {
+		h.logger.Error("Failed to unmarshal request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+	}
+	if len(check.Roles) == 0 || check.ResourceID == "" || check.Action == "" {
+		return h.errorResponse(http.StatusBadRequest, "roles, resource_id and action are required")
+	}
+
+	policy, err := h.getLivePolicy(ctx)
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+
+	decision := rbac.Check(policy, check.Roles, check.ResourceID, check.Action)
+	h.logger.Info("Evaluated authorization check",
+		zap.Strings("roles", check.Roles),
+		zap.String("resource_id", check.ResourceID),
+		zap.String("action", check.Action),
+		zap.Bool("allowed", decision.Allowed),
+	)
+
+	return h.policyResponse(http.StatusOK, decision, policy)
+}
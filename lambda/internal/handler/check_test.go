@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func TestHandleCheck(t *testing.T) {
+	policy := testRolePolicy()
+	policy.StytchAdmin = rbacpolicy.Role{
+		RoleID: "stytch_admin",
+		Permissions: []rbacpolicy.Permission{
+			{ResourceID: "documents", Actions: []string{"*"}},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		expectedStatus int
+		expectedAllow  bool
+		expectedRole   string
+	}{
+		{
+			name:           "Allowed by custom role",
+			method:         http.MethodPost,
+			body:           `{"roles":["viewer","editor"],"resource_id":"documents","action":"write"}`,
+			expectedStatus: http.StatusOK,
+			expectedAllow:  true,
+			expectedRole:   "editor",
+		},
+		{
+			name:           "Allowed by wildcard on Stytch admin",
+			method:         http.MethodPost,
+			body:           `{"roles":["stytch_admin"],"resource_id":"documents","action":"delete"}`,
+			expectedStatus: http.StatusOK,
+			expectedAllow:  true,
+			expectedRole:   "stytch_admin",
+		},
+		{
+			name:           "Denied",
+			method:         http.MethodPost,
+			body:           `{"roles":["viewer"],"resource_id":"documents","action":"delete"}`,
+			expectedStatus: http.StatusOK,
+			expectedAllow:  false,
+		},
+		{
+			name:           "Missing fields",
+			method:         http.MethodPost,
+			body:           `{"roles":[],"resource_id":"documents"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid JSON body",
+			method:         http.MethodPost,
+			body:           "invalid json",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unsupported method",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: tt.method,
+				Path:       "/rbacpolicy/check",
+				Body:       tt.body,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var decision rbac.Decision
+			if err := json.Unmarshal([]byte(response.Body), &decision); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if decision.Allowed != tt.expectedAllow {
+				t.Errorf("Expected allowed %v, got %v", tt.expectedAllow, decision.Allowed)
+			}
+			if decision.RoleID != tt.expectedRole {
+				t.Errorf("Expected granting role %q, got %q", tt.expectedRole, decision.RoleID)
+			}
+			if tt.expectedAllow && decision.Permission == nil {
+				t.Errorf("Expected granting permission in response")
+			}
+		})
+	}
+}
+
+func TestHandleCheckClientError(t *testing.T) {
+	handler := NewHandler(&mockRBACPolicyClient{
+		getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+			return nil, errors.New("client error")
+		},
+	}, "test-project-id", zap.NewNop())
+
+	response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodPost,
+		Path:       "/rbacpolicy/check",
+		Body:       `{"roles":["viewer"],"resource_id":"documents","action":"read"}`,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, response.StatusCode)
+	}
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+// handleDiff compares a proposed policy against the live one, read from
+// Stytch rather than the cache, without writing anything. The diff is what a
+// PUT of the same body would do if the policy does not change first; the
+// response's ETag can be sent as If-Match on that PUT to make sure.
+func (h *Handler) handleDiff(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	if request.HTTPMethod != http.MethodPost {
+		return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+
+	var proposed rbacpolicy.Policy
+	if err := json.Unmarshal([]byte(request.Body), &proposed); err != nil {
+		h.logger.Error("Failed to unmarshal request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+	}
+
+	policy, err := h.getLivePolicy(ctx)
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+
+	return h.policyResponse(http.StatusOK, rbac.Compare(policy, proposed), policy)
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func TestHandleDiff(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		mockClient     func(policy *rbacpolicy.Policy) *mockRBACPolicyClient
+		expectedStatus int
+		check          func(t *testing.T, diff rbac.Diff)
+	}{
+		{
+			name:           "Reordered policy has no changes",
+			method:         http.MethodPost,
+			body:           `{"custom_roles":[{"role_id":"viewer","description":"Viewer role","permissions":[{"resource_id":"documents","actions":["read"]}]},{"role_id":"editor","description":"Editor role","permissions":[{"resource_id":"documents","actions":["write","read"]}]}],"custom_resources":[{"resource_id":"documents","description":"Document resources","available_actions":["delete","write","read"]}]}`,
+			mockClient:     newStatefulMock,
+			expectedStatus: http.StatusOK,
+			check: func(t *testing.T, diff rbac.Diff) {
+				if !diff.Empty() {
+					t.Errorf("Expected empty diff, got %+v", diff)
+				}
+			},
+		},
+		{
+			name:           "Changed policy",
+			method:         http.MethodPost,
+			body:           `{"custom_roles":[{"role_id":"editor","description":"Writers","permissions":[{"resource_id":"documents","actions":["read"]}]}],"custom_resources":[{"resource_id":"documents","description":"Document resources","available_actions":["read","write","delete"]}]}`,
+			mockClient:     newStatefulMock,
+			expectedStatus: http.StatusOK,
+			check: func(t *testing.T, diff rbac.Diff) {
+				if len(diff.RolesRemoved) != 1 || diff.RolesRemoved[0] != "viewer" {
+					t.Errorf("Expected viewer to be removed, got %v", diff.RolesRemoved)
+				}
+				if len(diff.RoleChanges) != 1 {
+					t.Fatalf("Expected one role change, got %+v", diff.RoleChanges)
+				}
+				change := diff.RoleChanges[0]
+				if change.Description == nil || change.Description.To != "Writers" {
+					t.Errorf("Expected description change, got %+v", change.Description)
+				}
+				if len(change.PermissionsRemoved) != 1 || change.PermissionsRemoved[0].Action != "write" {
+					t.Errorf("Expected write permission removed, got %+v", change.PermissionsRemoved)
+				}
+			},
+		},
+		{
+			name:           "Invalid JSON body",
+			method:         http.MethodPost,
+			body:           "invalid json",
+			mockClient:     newStatefulMock,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "Client error",
+			method: http.MethodPost,
+			body:   `{}`,
+			mockClient: func(*rbacpolicy.Policy) *mockRBACPolicyClient {
+				return &mockRBACPolicyClient{
+					getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+						return nil, errors.New("client error")
+					},
+				}
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Unsupported method",
+			method:         http.MethodPut,
+			body:           `{}`,
+			mockClient:     newStatefulMock,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			before := policyETag(policy)
+			mockClient := tt.mockClient(&policy)
+			mockClient.setFunc = func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+				t.Errorf("Set must not be called for a diff")
+				return nil, errors.New("unexpected set")
+			}
+			handler := NewHandler(mockClient, "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: tt.method,
+				Path:       "/rbacpolicy/diff",
+				Body:       tt.body,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			if tt.check == nil {
+				return
+			}
+
+			if response.Headers["ETag"] != before {
+				t.Errorf("Expected ETag of live policy %s, got %s", before, response.Headers["ETag"])
+			}
+			var diff rbac.Diff
+			if err := json.Unmarshal([]byte(response.Body), &diff); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			tt.check(t, diff)
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/auth"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/desired"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+// DriftReport compares a project's live policy with its desired policy.
+type DriftReport struct {
+	ProjectID   string    `json:"project_id"`
+	CheckedAt   time.Time `json:"checked_at"`
+	Drifted     bool      `json:"drifted"`
+	LiveHash    string    `json:"live_hash,omitempty"`
+	DesiredHash string    `json:"desired_hash,omitempty"`
+	// Diff is what reconciling changes, from the live policy to the desired
+	// one.
+	Diff       rbac.Diff `json:"diff"`
+	Reconciled bool      `json:"reconciled"`
+	DryRun     bool      `json:"dry_run,omitempty"`
+	// Error is set only in scheduled summaries, when the check failed.
+	Error string `json:"error,omitempty"`
+}
+
+// DriftSummary is the result of a scheduled drift check.
+type DriftSummary struct {
+	Reports []DriftReport `json:"reports"`
+}
+
+// driftEventDetail is the optional detail of a scheduled drift check event.
+// Projects are IDs or aliases; without any, every configured project with a
+// desired policy is checked. Reconcile overrides WithDriftAutoReconcile.
+type driftEventDetail struct {
+	Projects  []string `json:"projects"`
+	Reconcile *bool    `json:"reconcile"`
+}
+
+// errNoDrift aborts a reconciling updatePolicy when there is nothing to write.
+var errNoDrift = errors.New("no drift")
+
+type scheduledKey struct{}
+
+// withScheduledCaller marks ctx as a scheduled invocation by caller.
+// Scheduled events reach the function through its IAM policy rather than the
+// ALB, so they carry no token and the authorization rules do not apply;
+// caller is recorded as the subject in audit records instead.
+func withScheduledCaller(ctx context.Context, caller string) context.Context {
+	ctx = auth.WithIdentity(ctx, &auth.Identity{Subject: caller})
+	return context.WithValue(ctx, scheduledKey{}, true)
+}
+
+func isScheduled(ctx context.Context) bool {
+	scheduled, _ := ctx.Value(scheduledKey{}).(bool)
+	return scheduled
+}
+
+// handleDrift reports drift on GET and reconciles it on POST.
+func (h *Handler) handleDrift(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	var reconcile bool
+	switch request.HTTPMethod {
+	case http.MethodGet:
+	case http.MethodPost:
+		reconcile = true
+	default:
+		return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+
+	if h.desired == nil {
+		return h.errorResponse(http.StatusNotFound, "Desired policy source is not configured")
+	}
+
+	projectID := h.project(ctx)
+	want, err := h.desired.Load(ctx, projectID)
+	if errors.Is(err, desired.ErrNotFound) {
+		return h.errorResponse(http.StatusNotFound, fmt.Sprintf("No desired policy for project %q", projectID))
+	}
+	if err != nil {
+		h.logger.Error("Failed to load desired policy", zap.Error(err), zap.String("project_id", projectID))
+		return h.statusErrorResponse(h.internalError(err, "Failed to load desired policy"))
+	}
+
+	report, err := h.checkDrift(ctx, request, want, reconcile)
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+	return h.jsonResponse(http.StatusOK, report)
+}
+
+// HandleScheduledEvent checks the configured projects for drift from an
+// EventBridge schedule, reconciling it when enabled. It returns an error if
+// any project could not be checked, after checking the rest, so the
+// invocation is reported as failed.
+func (h *Handler) HandleScheduledEvent(ctx context.Context, event events.EventBridgeEvent) (DriftSummary, error) {
+	summary := DriftSummary{Reports: []DriftReport{}}
+	if h.desired == nil {
+		return summary, errors.New("desired policy source is not configured")
+	}
+
+	var detail driftEventDetail
+	if len(event.Detail) > 0 {
+		if err := json.Unmarshal(event.Detail, &detail); err != nil {
+			return summary, fmt.Errorf("invalid scheduled event detail: %w", err)
+		}
+	}
+	reconcile := h.driftAutoReconcile
+	if detail.Reconcile != nil {
+		reconcile = *detail.Reconcile
+	}
+
+	projectIDs := h.projectIDs()
+	if len(detail.Projects) > 0 {
+		projectIDs = nil
+		for _, name := range detail.Projects {
+			projectID, ok := h.lookupProject(name)
+			if !ok {
+				return summary, fmt.Errorf("project %q not found", name)
+			}
+			projectIDs = append(projectIDs, projectID)
+		}
+	}
+
+	caller := event.Source
+	if len(event.Resources) > 0 {
+		caller = event.Resources[0]
+	}
+	ctx = withScheduledCaller(ctx, caller)
+	request := events.ALBTargetGroupRequest{HTTPMethod: http.MethodPost, Path: driftPath}
+
+	h.logger.Info("Running scheduled drift check",
+		zap.String("caller", caller),
+		zap.Strings("projects", projectIDs),
+		zap.Bool("reconcile", reconcile),
+	)
+
+	var failed []string
+	for _, projectID := range projectIDs {
+		projectCtx := withProjectRoute(ctx, projectRoute{projectID: projectID, path: driftPath})
+
+		want, err := h.desired.Load(projectCtx, projectID)
+		if errors.Is(err, desired.ErrNotFound) && len(detail.Projects) == 0 {
+			h.logger.Debug("No desired policy for project", zap.String("project_id", projectID))
+			continue
+		}
+		var report *DriftReport
+		if err == nil {
+			report, err = h.checkDrift(projectCtx, request, want, reconcile)
+		}
+		if err != nil {
+			h.logger.Error("Scheduled drift check failed", zap.Error(err), zap.String("project_id", projectID))
+			failed = append(failed, projectID)
+			summary.Reports = append(summary.Reports, DriftReport{
+				ProjectID: projectID,
+				CheckedAt: time.Now().UTC(),
+				Error:     err.Error(),
+			})
+			continue
+		}
+		summary.Reports = append(summary.Reports, *report)
+	}
+
+	if len(failed) > 0 {
+		return summary, fmt.Errorf("drift check failed for projects %v", failed)
+	}
+	return summary, nil
+}
+
+// checkDrift compares the live policy of the project in ctx with want. With
+// reconcile the desired policy is written through updatePolicy, so the write
+// honours dry_run, is authorized, snapshotted and audited, and is skipped when
+// there is no drift. Failures are returned as a *statusError.
+func (h *Handler) checkDrift(ctx context.Context, request events.ALBTargetGroupRequest, want rbacpolicy.Policy, reconcile bool) (*DriftReport, error) {
+	projectID := h.project(ctx)
+	report := &DriftReport{ProjectID: projectID, CheckedAt: time.Now().UTC()}
+	var live, target rbacpolicy.Policy
+	if reconcile {
+		write, err := h.updatePolicy(ctx, request, func(p *rbacpolicy.Policy) error {
+			live = rbac.Clone(*p)
+			target = applyDesired(live, want)
+			if rbac.Compare(live, target).Empty() {
+				return errNoDrift
+			}
+			*p = target
+			return nil
+		})
+		switch {
+		case errors.Is(err, errNoDrift):
+		case err != nil:
+			return nil, err
+		default:
+			report.Reconciled = !write.dryRun
+			report.DryRun = write.dryRun
+		}
+	} else {
+		resp, err := h.client.Get(ctx, rbacpolicy.GetRequest{ProjectID: projectID})
+		if err != nil {
+			h.logger.Error("Failed to get RBAC policy", zap.Error(err))
+			return nil, h.clientError(err, "Failed to get RBAC policy")
+		}
+		live = resp.Policy
+		target = applyDesired(live, want)
+	}
+
+	report.Diff = rbac.Compare(live, target)
+	report.Drifted = !report.Diff.Empty()
+	report.LiveHash = rbac.Hash(live)
+	report.DesiredHash = rbac.Hash(target)
+
+	switch {
+	case report.Reconciled:
+		h.logger.Info("Reconciled RBAC policy drift",
+			zap.String("project_id", projectID),
+			zap.Any("diff", report.Diff),
+		)
+	case report.Drifted:
+		h.logger.Warn("RBAC policy drift detected",
+			zap.String("project_id", projectID),
+			zap.Any("diff", report.Diff),
+		)
+	}
+	return report, nil
+}
+
+// applyDesired returns live with the parts the desired policy declares. Custom
+// roles and resources are always declared, so ones missing from the desired
+// policy are drift. The Stytch default roles are compared only when the
+// desired policy includes them, and Stytch resources never are, since they
+// cannot be changed.
+func applyDesired(live, want rbacpolicy.Policy) rbacpolicy.Policy {
+	target := rbac.Clone(live)
+	want = rbac.Clone(want)
+	target.CustomRoles = want.CustomRoles
+	target.CustomResources = want.CustomResources
+	if want.StytchMember.RoleID != "" {
+		target.StytchMember = want.StytchMember
+	}
+	if want.StytchAdmin.RoleID != "" {
+		target.StytchAdmin = want.StytchAdmin
+	}
+	return target
+}
+
+// projectIDs lists every project the handler can reach, default first.
+func (h *Handler) projectIDs() []string {
+	seen := map[string]bool{h.projectID: true}
+	var others []string
+	for _, projectID := range h.projects {
+		if !seen[projectID] {
+			seen[projectID] = true
+			others = append(others, projectID)
+		}
+	}
+	sort.Strings(others)
+	return append([]string{h.projectID}, others...)
+}
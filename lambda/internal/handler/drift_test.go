@@ -0,0 +1,516 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/authz"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/desired"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+// mapSource is a desired.Source backed by a map, failing every load with err
+// when it is set.
+type mapSource struct {
+	policies map[string]rbacpolicy.Policy
+	err      error
+}
+
+func (s mapSource) Load(ctx context.Context, projectID string) (rbacpolicy.Policy, error) {
+	if s.err != nil {
+		return rbacpolicy.Policy{}, s.err
+	}
+	policy, ok := s.policies[projectID]
+	if !ok {
+		return rbacpolicy.Policy{}, desired.ErrNotFound
+	}
+	return rbac.Clone(policy), nil
+}
+
+// driftedPolicy is testRolePolicy with the viewer role dropped and an auditor
+// role added.
+func driftedPolicy() rbacpolicy.Policy {
+	policy := testRolePolicy()
+	policy.CustomRoles = []rbacpolicy.Role{
+		policy.CustomRoles[0],
+		{
+			RoleID:      "auditor",
+			Description: "Auditor role",
+			Permissions: []rbacpolicy.Permission{{ResourceID: "documents", Actions: []string{"read"}}},
+		},
+	}
+	return policy
+}
+
+func TestHandleDrift(t *testing.T) {
+	liveMember := rbacpolicy.Role{RoleID: "stytch_member", Description: "Live member"}
+
+	tests := []struct {
+		name           string
+		request        events.ALBTargetGroupRequest
+		source         desired.Source
+		expectedStatus int
+		expectedError  string
+		expectedReport *DriftReport
+		// expectedLive is the live policy afterwards; nil means unchanged.
+		expectedLive *rbacpolicy.Policy
+		expectedSets int
+	}{
+		{
+			name:           "No drift",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/drift"},
+			source:         mapSource{policies: map[string]rbacpolicy.Policy{"test-project-id": testRolePolicy()}},
+			expectedStatus: http.StatusOK,
+			expectedReport: &DriftReport{ProjectID: "test-project-id"},
+		},
+		{
+			name:           "Drift reported",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/drift"},
+			source:         mapSource{policies: map[string]rbacpolicy.Policy{"test-project-id": driftedPolicy()}},
+			expectedStatus: http.StatusOK,
+			expectedReport: &DriftReport{
+				ProjectID: "test-project-id",
+				Drifted:   true,
+				Diff:      rbac.Compare(testRolePolicy(), driftedPolicy()),
+			},
+		},
+		{
+			name:    "Declared Stytch default role",
+			request: events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/drift"},
+			source: mapSource{policies: map[string]rbacpolicy.Policy{"test-project-id": func() rbacpolicy.Policy {
+				p := testRolePolicy()
+				p.StytchMember = rbacpolicy.Role{RoleID: "stytch_member", Description: "Desired member"}
+				return p
+			}()}},
+			expectedStatus: http.StatusOK,
+			expectedReport: &DriftReport{
+				ProjectID: "test-project-id",
+				Drifted:   true,
+				Diff: rbac.Diff{RoleChanges: []rbac.RoleChange{{
+					RoleID:      "stytch_member",
+					Description: &rbac.DescriptionChange{From: "Live member", To: "Desired member"},
+				}}},
+			},
+		},
+		{
+			name:           "Reconcile",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodPost, Path: "/rbacpolicy/drift"},
+			source:         mapSource{policies: map[string]rbacpolicy.Policy{"test-project-id": driftedPolicy()}},
+			expectedStatus: http.StatusOK,
+			expectedReport: &DriftReport{
+				ProjectID:  "test-project-id",
+				Drifted:    true,
+				Diff:       rbac.Compare(testRolePolicy(), driftedPolicy()),
+				Reconciled: true,
+			},
+			expectedLive: func() *rbacpolicy.Policy {
+				p := driftedPolicy()
+				p.StytchMember = liveMember
+				return &p
+			}(),
+			expectedSets: 1,
+		},
+		{
+			name: "Reconcile dry run",
+			request: events.ALBTargetGroupRequest{
+				HTTPMethod:            http.MethodPost,
+				Path:                  "/rbacpolicy/drift",
+				QueryStringParameters: map[string]string{"dry_run": "true"},
+			},
+			source:         mapSource{policies: map[string]rbacpolicy.Policy{"test-project-id": driftedPolicy()}},
+			expectedStatus: http.StatusOK,
+			expectedReport: &DriftReport{
+				ProjectID: "test-project-id",
+				Drifted:   true,
+				Diff:      rbac.Compare(testRolePolicy(), driftedPolicy()),
+				DryRun:    true,
+			},
+		},
+		{
+			name:           "Reconcile without drift",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodPost, Path: "/rbacpolicy/drift"},
+			source:         mapSource{policies: map[string]rbacpolicy.Policy{"test-project-id": testRolePolicy()}},
+			expectedStatus: http.StatusOK,
+			expectedReport: &DriftReport{ProjectID: "test-project-id"},
+		},
+		{
+			name:    "Reconcile to an invalid policy",
+			request: events.ALBTargetGroupRequest{HTTPMethod: http.MethodPost, Path: "/rbacpolicy/drift"},
+			source: mapSource{policies: map[string]rbacpolicy.Policy{"test-project-id": {
+				CustomRoles: []rbacpolicy.Role{{RoleID: "editor", Permissions: []rbacpolicy.Permission{{ResourceID: "missing", Actions: []string{"read"}}}}},
+			}}},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  "RBAC policy failed validation",
+		},
+		{
+			name:           "No source",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/drift"},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Desired policy source is not configured",
+		},
+		{
+			name:           "No desired policy",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/drift"},
+			source:         mapSource{},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  `No desired policy for project "test-project-id"`,
+		},
+		{
+			name:           "Source failure",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/drift"},
+			source:         mapSource{err: errors.New("bucket unavailable")},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "Failed to load desired policy: bucket unavailable",
+		},
+		{
+			name:           "Wrong method",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodDelete, Path: "/rbacpolicy/drift"},
+			source:         mapSource{},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := testRolePolicy()
+			live.StytchMember = liveMember
+			before := rbac.Clone(live)
+			client := newStatefulMock(&live)
+			sets := 0
+			set := client.setFunc
+			client.setFunc = func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+				sets++
+				return set(ctx, body)
+			}
+
+			var opts []Option
+			if tt.source != nil {
+				opts = append(opts, WithDesiredPolicySource(tt.source))
+			}
+			h := NewHandler(client, "test-project-id", zap.NewNop(), opts...)
+
+			response, err := h.HandleRequest(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			if tt.expectedError != "" {
+				var body map[string]any
+				if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+					t.Fatalf("Failed to unmarshal response body: %v", err)
+				}
+				if body["error"] != tt.expectedError {
+					t.Errorf("Expected error %q, got %v", tt.expectedError, body["error"])
+				}
+			}
+
+			if tt.expectedReport != nil {
+				var report DriftReport
+				if err := json.Unmarshal([]byte(response.Body), &report); err != nil {
+					t.Fatalf("Failed to unmarshal response body: %v", err)
+				}
+				if report.CheckedAt.IsZero() {
+					t.Error("Expected checked_at to be set")
+				}
+				if report.LiveHash != rbac.Hash(before) {
+					t.Errorf("Expected live_hash of the policy before reconciling, got %q", report.LiveHash)
+				}
+				if report.Drifted == (report.LiveHash == report.DesiredHash) {
+					t.Errorf("Expected hashes to differ only with drift, got %q and %q", report.LiveHash, report.DesiredHash)
+				}
+				report.CheckedAt = tt.expectedReport.CheckedAt
+				report.LiveHash, report.DesiredHash = "", ""
+				if normalizeDiff(report.Diff) != normalizeDiff(tt.expectedReport.Diff) {
+					t.Errorf("Expected diff %+v, got %+v", tt.expectedReport.Diff, report.Diff)
+				}
+				report.Diff = tt.expectedReport.Diff
+				if !reflect.DeepEqual(report, *tt.expectedReport) {
+					t.Errorf("Expected report %+v, got %+v", *tt.expectedReport, report)
+				}
+			}
+
+			expectedLive := before
+			if tt.expectedLive != nil {
+				expectedLive = *tt.expectedLive
+			}
+			if rbac.Hash(live) != rbac.Hash(expectedLive) {
+				t.Errorf("Expected live policy %+v, got %+v", expectedLive, live)
+			}
+			if sets != tt.expectedSets {
+				t.Errorf("Expected %d Stytch writes, got %d", tt.expectedSets, sets)
+			}
+		})
+	}
+}
+
+// normalizeDiff renders a diff as JSON with empty lists for nil ones, so a
+// hand-written expectation compares equal to a decoded response.
+func normalizeDiff(diff rbac.Diff) string {
+	for _, list := range []*[]string{&diff.RolesAdded, &diff.RolesRemoved, &diff.ResourcesAdded, &diff.ResourcesRemoved} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	if diff.RoleChanges == nil {
+		diff.RoleChanges = []rbac.RoleChange{}
+	}
+	if diff.ResourceChanges == nil {
+		diff.ResourceChanges = []rbac.ResourceChange{}
+	}
+	body, _ := json.Marshal(diff)
+	return string(body)
+}
+
+func TestHandleScheduledEvent(t *testing.T) {
+	// The authorizer grants nothing, so reconciling works only because
+	// scheduled invocations are not authorized.
+	authorizer, err := authz.New(authz.Config{})
+	if err != nil {
+		t.Fatalf("Failed to create authorizer: %v", err)
+	}
+
+	tests := []struct {
+		name              string
+		opts              []Option
+		detail            string
+		sources           map[string]rbacpolicy.Policy
+		expectedErr       string
+		expectedProjects  []string
+		expectedDrifted   []bool
+		expectedReconcile bool
+	}{
+		{
+			name: "Checks every project with a desired policy",
+			sources: map[string]rbacpolicy.Policy{
+				"project-default": *projectPolicy("project-default"),
+				"project-live":    *projectPolicy("project-other"),
+			},
+			expectedProjects: []string{"project-default", "project-live"},
+			expectedDrifted:  []bool{false, true},
+		},
+		{
+			name: "Auto reconcile",
+			opts: []Option{WithDriftAutoReconcile()},
+			sources: map[string]rbacpolicy.Policy{
+				"project-live": *projectPolicy("project-other"),
+			},
+			expectedProjects:  []string{"project-live"},
+			expectedDrifted:   []bool{true},
+			expectedReconcile: true,
+		},
+		{
+			name:   "Detail disables reconcile",
+			opts:   []Option{WithDriftAutoReconcile()},
+			detail: `{"reconcile": false}`,
+			sources: map[string]rbacpolicy.Policy{
+				"project-live": *projectPolicy("project-other"),
+			},
+			expectedProjects: []string{"project-live"},
+			expectedDrifted:  []bool{true},
+		},
+		{
+			name:   "Detail selects projects",
+			detail: `{"projects": ["live"], "reconcile": true}`,
+			sources: map[string]rbacpolicy.Policy{
+				"project-default": *projectPolicy("project-other"),
+				"project-live":    *projectPolicy("project-other"),
+			},
+			expectedProjects:  []string{"project-live"},
+			expectedDrifted:   []bool{true},
+			expectedReconcile: true,
+		},
+		{
+			name:             "Selected project without desired policy",
+			detail:           `{"projects": ["test"]}`,
+			sources:          map[string]rbacpolicy.Policy{},
+			expectedErr:      "drift check failed for projects [project-test]",
+			expectedProjects: []string{"project-test"},
+			expectedDrifted:  []bool{false},
+		},
+		{
+			name: "Stytch failure for one project",
+			sources: map[string]rbacpolicy.Policy{
+				"project-live":   *projectPolicy("project-live"),
+				"project-tenant": *projectPolicy("project-tenant"),
+			},
+			expectedErr:      "drift check failed for projects [project-tenant]",
+			expectedProjects: []string{"project-live", "project-tenant"},
+			expectedDrifted:  []bool{false, false},
+		},
+		{
+			name:        "Unknown project",
+			detail:      `{"projects": ["staging"]}`,
+			expectedErr: `project "staging" not found`,
+		},
+		{
+			name:        "Invalid detail",
+			detail:      `{"projects": "live"}`,
+			expectedErr: "invalid scheduled event detail: json: cannot unmarshal string into Go struct field driftEventDetail.projects of type []string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// project-tenant is configured but missing from Stytch.
+			policies := map[string]*rbacpolicy.Policy{
+				"project-default": projectPolicy("project-default"),
+				"project-live":    projectPolicy("project-live"),
+				"project-test":    projectPolicy("project-test"),
+			}
+			sink := &recordingSink{}
+			opts := append([]Option{
+				WithProjects(testProjects),
+				WithDesiredPolicySource(mapSource{policies: tt.sources}),
+				WithAuthenticator(groupAuthenticator{}),
+				WithAuthorizer(authorizer),
+				WithAuditSink(sink),
+				WithHistoryStore(history.NewMemoryStore()),
+			}, tt.opts...)
+			h := NewHandler(newProjectsMock(policies), "project-default", zap.NewNop(), opts...)
+
+			event := events.EventBridgeEvent{
+				Source:     "aws.events",
+				DetailType: "Scheduled Event",
+				Resources:  []string{"arn:aws:events:us-east-1:123456789012:rule/drift"},
+			}
+			if tt.detail != "" {
+				event.Detail = json.RawMessage(tt.detail)
+			}
+
+			summary, err := h.HandleScheduledEvent(context.Background(), event)
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("Expected error %q, got %v", tt.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if len(summary.Reports) != len(tt.expectedProjects) {
+				t.Fatalf("Expected %d reports, got %+v", len(tt.expectedProjects), summary.Reports)
+			}
+			for i, report := range summary.Reports {
+				if report.ProjectID != tt.expectedProjects[i] {
+					t.Errorf("Report %d: expected project %q, got %q", i, tt.expectedProjects[i], report.ProjectID)
+				}
+				if report.Drifted != tt.expectedDrifted[i] {
+					t.Errorf("Report %d: expected drifted %v, got %v", i, tt.expectedDrifted[i], report.Drifted)
+				}
+				if report.Reconciled != (tt.expectedReconcile && report.Drifted) {
+					t.Errorf("Report %d: expected reconciled %v, got %v", i, tt.expectedReconcile && report.Drifted, report.Reconciled)
+				}
+				if report.Reconciled {
+					want := tt.sources[report.ProjectID]
+					if got := *policies[report.ProjectID]; !reflect.DeepEqual(got.CustomRoles, want.CustomRoles) {
+						t.Errorf("Report %d: expected live roles %v, got %v", i, want.CustomRoles, got.CustomRoles)
+					}
+				}
+			}
+
+			if !tt.expectedReconcile {
+				if len(sink.records) != 0 {
+					t.Errorf("Expected no audit records, got %d", len(sink.records))
+				}
+				return
+			}
+			if len(sink.records) != 1 {
+				t.Fatalf("Expected 1 audit record, got %d", len(sink.records))
+			}
+			record := sink.records[0]
+			if record.Caller.Subject != "arn:aws:events:us-east-1:123456789012:rule/drift" {
+				t.Errorf("Expected the rule as caller, got %q", record.Caller.Subject)
+			}
+			if record.ProjectID != "project-live" || record.Path != "/rbacpolicy/drift" {
+				t.Errorf("Expected audit record for POST /rbacpolicy/drift on project-live, got %s %s on %s", record.Method, record.Path, record.ProjectID)
+			}
+		})
+	}
+}
+
+func TestHandleScheduledEventWithoutSource(t *testing.T) {
+	h := NewHandler(&mockRBACPolicyClient{}, "test-project-id", zap.NewNop())
+
+	_, err := h.HandleScheduledEvent(context.Background(), events.EventBridgeEvent{DetailType: "Scheduled Event"})
+	if err == nil || err.Error() != "desired policy source is not configured" {
+		t.Errorf("Expected missing source error, got %v", err)
+	}
+}
+
+func TestDriftAuthorization(t *testing.T) {
+	authorizer, err := authz.New(authz.Config{Rules: []authz.Rule{
+		{Groups: []string{"readers"}, Capabilities: []authz.Capability{authz.ReadPolicy}},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create authorizer: %v", err)
+	}
+
+	tests := []struct {
+		method         string
+		expectedStatus int
+	}{
+		{method: http.MethodGet, expectedStatus: http.StatusOK},
+		{method: http.MethodPost, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			live := testRolePolicy()
+			h := NewHandler(newStatefulMock(&live), "test-project-id", zap.NewNop(),
+				WithDesiredPolicySource(mapSource{policies: map[string]rbacpolicy.Policy{"test-project-id": driftedPolicy()}}),
+				WithAuthenticator(groupAuthenticator{}),
+				WithAuthorizer(authorizer),
+			)
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: tt.method,
+				Path:       "/rbacpolicy/drift",
+				Headers:    map[string]string{"x-amzn-oidc-data": "readers"},
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+		})
+	}
+}
+
+func TestApplyDesired(t *testing.T) {
+	live := testRolePolicy()
+	live.StytchMember = rbacpolicy.Role{RoleID: "stytch_member", Description: "Live member"}
+	live.StytchAdmin = rbacpolicy.Role{RoleID: "stytch_admin", Description: "Live admin"}
+	live.StytchResources = []rbacpolicy.Resource{{ResourceID: "stytch.member", AvailableActions: []string{"*"}}}
+
+	want := rbacpolicy.Policy{
+		StytchAdmin:     rbacpolicy.Role{RoleID: "stytch_admin", Description: "Desired admin"},
+		StytchResources: []rbacpolicy.Resource{{ResourceID: "ignored"}},
+	}
+
+	got := applyDesired(live, want)
+	if got.StytchMember.Description != "Live member" {
+		t.Errorf("Expected undeclared stytch_member to be kept, got %q", got.StytchMember.Description)
+	}
+	if got.StytchAdmin.Description != "Desired admin" {
+		t.Errorf("Expected declared stytch_admin, got %q", got.StytchAdmin.Description)
+	}
+	if !reflect.DeepEqual(got.StytchResources, live.StytchResources) {
+		t.Errorf("Expected Stytch resources to be kept, got %v", got.StytchResources)
+	}
+	if len(got.CustomRoles) != 0 || len(got.CustomResources) != 0 {
+		t.Errorf("Expected undeclared custom roles and resources to be removed, got %v and %v", got.CustomRoles, got.CustomResources)
+	}
+	if len(live.CustomRoles) != 2 {
+		t.Error("Expected live policy to be left unmodified")
+	}
+}
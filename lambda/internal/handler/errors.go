@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+)
+
+// passthroughStatuses are the Stytch error statuses returned to the caller
+// as-is. Anything else from Stytch is reported as a 500.
+var passthroughStatuses = map[int]bool{
+	http.StatusBadRequest:      true,
+	http.StatusUnauthorized:    true,
+	http.StatusForbidden:       true,
+	http.StatusNotFound:        true,
+	http.StatusConflict:        true,
+	http.StatusTooManyRequests: true,
+}
+
+// asStytchError extracts the Stytch API error from err, which the client
+// returns by value.
+func asStytchError(err error) (stytcherror.Error, bool) {
+	var value stytcherror.Error
+	if errors.As(err, &value) {
+		return value, true
+	}
+	var pointer *stytcherror.Error
+	if errors.As(err, &pointer) && pointer != nil {
+		return *pointer, true
+	}
+	return stytcherror.Error{}, false
+}
+
+// clientError maps an error from the Stytch client to a *statusError. Stytch
+// 4xx errors keep their status and message; the Stytch request ID and error
+// type are included whenever Stytch supplied them. A status the transport saw
+// without a Stytch error body, such as a 429 page from a proxy, is mapped the
+// same way, and a 429 passes on Retry-After. Calls refused by an open circuit
+// breaker are a 503 with Retry-After.
+func (h *Handler) clientError(err error, message string) *statusError {
+	var openErr *stytchclient.OpenError
+	if errors.As(err, &openErr) {
+		return &statusError{
+			statusCode: http.StatusServiceUnavailable,
+			message:    message + ": Stytch is unavailable, retry later",
+			headers:    map[string]string{"Retry-After": retryAfterSeconds(openErr.RetryAfter)},
+		}
+	}
+
+	var statusErr *stytchclient.StatusError
+	hasStatus := errors.As(err, &statusErr)
+	stytchErr, ok := asStytchError(err)
+	switch {
+	case ok:
+	case hasStatus:
+		stytchErr = stytcherror.Error{StatusCode: statusErr.StatusCode}
+	default:
+		return h.internalError(err, message)
+	}
+
+	var se *statusError
+	if passthroughStatuses[stytchErr.StatusCode] {
+		se = &statusError{
+			statusCode: stytchErr.StatusCode,
+			message:    message,
+		}
+		if stytchErr.ErrorMessage != "" {
+			se.message += ": " + string(stytchErr.ErrorMessage)
+		}
+	} else {
+		se = h.internalError(err, message)
+	}
+	if se.statusCode == http.StatusTooManyRequests && hasStatus && statusErr.RetryAfter > 0 {
+		se.headers = map[string]string{"Retry-After": retryAfterSeconds(statusErr.RetryAfter)}
+	}
+
+	se.details = map[string]any{}
+	if stytchErr.RequestID != "" {
+		se.details["stytch_request_id"] = stytchErr.RequestID
+	}
+	if stytchErr.ErrorType != "" {
+		se.details["stytch_error_type"] = stytchErr.ErrorType
+	}
+	return se
+}
+
+// internalError is a 500 for err. The error text is left out of the response
+// when internal errors are hidden; it is still logged by the caller.
+func (h *Handler) internalError(err error, message string) *statusError {
+	if !h.hideInternalErrors {
+		message += ": " + err.Error()
+	}
+	return &statusError{
+		statusCode: http.StatusInternalServerError,
+		message:    message,
+	}
+}
+
+// CircuitBreaker reports the state of the circuit breaker in front of Stytch.
+type CircuitBreaker interface {
+	Status() stytchclient.BreakerStatus
+}
+
+// retryAfterSeconds formats d as a Retry-After value, rounding up so clients
+// never retry early.
+func retryAfterSeconds(d time.Duration) string {
+	seconds := int64((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.FormatInt(seconds, 10)
+}
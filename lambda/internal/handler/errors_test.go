@@ -0,0 +1,346 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+	"go.uber.org/zap"
+)
+
+func TestStytchErrorMapping(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedError  string
+		expectedType   string
+	}{
+		{
+			name:           "Bad request",
+			err:            stytcherror.Error{StatusCode: 400, RequestID: "req-1", ErrorType: "invalid_rbac_policy", ErrorMessage: "Role is invalid."},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Failed to set RBAC policy: Role is invalid.",
+			expectedType:   "invalid_rbac_policy",
+		},
+		{
+			name:           "Unauthorized",
+			err:            stytcherror.Error{StatusCode: 401, RequestID: "req-1", ErrorType: "unauthorized_credentials"},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "Failed to set RBAC policy",
+			expectedType:   "unauthorized_credentials",
+		},
+		{
+			name:           "Forbidden",
+			err:            stytcherror.Error{StatusCode: 403, RequestID: "req-1", ErrorType: "forbidden"},
+			expectedStatus: http.StatusForbidden,
+			expectedType:   "forbidden",
+		},
+		{
+			name:           "Not found",
+			err:            stytcherror.Error{StatusCode: 404, ErrorMessage: "Not found."},
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Failed to set RBAC policy: Not found.",
+		},
+		{
+			name:           "Conflict",
+			err:            stytcherror.Error{StatusCode: 409, RequestID: "req-1", ErrorType: "conflict"},
+			expectedStatus: http.StatusConflict,
+			expectedType:   "conflict",
+		},
+		{
+			name:           "Rate limited",
+			err:            stytcherror.Error{StatusCode: 429, RequestID: "req-1", ErrorType: "too_many_requests"},
+			expectedStatus: http.StatusTooManyRequests,
+			expectedType:   "too_many_requests",
+		},
+		{
+			name:           "Pointer error",
+			err:            &stytcherror.Error{StatusCode: 409, RequestID: "req-1", ErrorType: "conflict"},
+			expectedStatus: http.StatusConflict,
+			expectedType:   "conflict",
+		},
+		{
+			name:           "Wrapped error",
+			err:            fmt.Errorf("set: %w", stytcherror.Error{StatusCode: 429, RequestID: "req-1", ErrorType: "too_many_requests"}),
+			expectedStatus: http.StatusTooManyRequests,
+			expectedType:   "too_many_requests",
+		},
+		{
+			name:           "Server error",
+			err:            stytcherror.Error{StatusCode: 503, RequestID: "req-1", ErrorType: "internal_server_error"},
+			expectedStatus: http.StatusInternalServerError,
+			expectedType:   "internal_server_error",
+		},
+		{
+			name:           "Network error",
+			err:            errors.New("dial tcp: connection refused"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "Failed to set RBAC policy: dial tcp: connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockRBACPolicyClient{
+				getFunc: getEmptyPolicy,
+				setFunc: func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+					return nil, tt.err
+				},
+			}
+			h := NewHandler(client, "test-project-id", zap.NewNop())
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodPut,
+				Path:       "/rbacpolicy",
+				Body:       `{"custom_roles":[],"custom_resources":[]}`,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+
+			var body map[string]string
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if tt.expectedError != "" && body["error"] != tt.expectedError {
+				t.Errorf("Expected error %q, got %q", tt.expectedError, body["error"])
+			}
+			if body["stytch_error_type"] != tt.expectedType {
+				t.Errorf("Expected stytch_error_type %q, got %q", tt.expectedType, body["stytch_error_type"])
+			}
+			if tt.expectedType != "" && body["stytch_request_id"] != "req-1" {
+				t.Errorf("Expected stytch_request_id req-1, got %q", body["stytch_request_id"])
+			}
+		})
+	}
+}
+
+func TestStytchStatusWithoutErrorBody(t *testing.T) {
+	errDecode := errors.New("error decoding http request: invalid character '<'")
+
+	tests := []struct {
+		name               string
+		err                error
+		expectedStatus     int
+		expectedRetryAfter string
+		expectedType       string
+	}{
+		{
+			name:               "Rate limited by a proxy",
+			err:                &stytchclient.StatusError{StatusCode: 429, RetryAfter: 2 * time.Second, Err: errDecode},
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedRetryAfter: "2",
+		},
+		{
+			name:           "Rate limited without Retry-After",
+			err:            &stytchclient.StatusError{StatusCode: 429, Err: errDecode},
+			expectedStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:               "Rate limited by Stytch",
+			err:                &stytchclient.StatusError{StatusCode: 429, RetryAfter: time.Second, Err: stytcherror.Error{StatusCode: 429, RequestID: "req-1", ErrorType: "too_many_requests"}},
+			expectedStatus:     http.StatusTooManyRequests,
+			expectedRetryAfter: "1",
+			expectedType:       "too_many_requests",
+		},
+		{
+			name:           "Not found page",
+			err:            &stytchclient.StatusError{StatusCode: 404, Err: errDecode},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Bad gateway page",
+			err:            &stytchclient.StatusError{StatusCode: 502, RetryAfter: time.Second, Err: errDecode},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockRBACPolicyClient{
+				getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+					return nil, tt.err
+				},
+			}
+			h := NewHandler(client, "test-project-id", zap.NewNop(), WithHideInternalErrors())
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/rbacpolicy",
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+			if response.Headers["Retry-After"] != tt.expectedRetryAfter {
+				t.Errorf("Expected Retry-After %q, got %q", tt.expectedRetryAfter, response.Headers["Retry-After"])
+			}
+
+			var body map[string]string
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if body["stytch_error_type"] != tt.expectedType {
+				t.Errorf("Expected stytch_error_type %q, got %q", tt.expectedType, body["stytch_error_type"])
+			}
+			if strings.Contains(response.Body, "invalid character") {
+				t.Errorf("Expected decode error to be hidden, got %s", response.Body)
+			}
+		})
+	}
+}
+
+func TestStytchErrorOnGet(t *testing.T) {
+	client := &mockRBACPolicyClient{
+		getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+			return nil, stytcherror.Error{StatusCode: 404, RequestID: "req-2", ErrorType: "project_not_found", ErrorMessage: "Project not found."}
+		},
+	}
+	h := NewHandler(client, "test-project-id", zap.NewNop())
+
+	for _, path := range []string{"/rbacpolicy", "/rbacpolicy/roles/editor", "/rbacpolicy/resources/documents/grants"} {
+		response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+			HTTPMethod: http.MethodGet,
+			Path:       path,
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected status code %d, got %d", path, http.StatusNotFound, response.StatusCode)
+		}
+		if !strings.Contains(response.Body, `"stytch_error_type":"project_not_found"`) {
+			t.Errorf("%s: expected Stytch error type in body, got %s", path, response.Body)
+		}
+	}
+}
+
+func TestHideInternalErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Network error",
+			err:            errors.New("dial tcp 10.0.0.1:443: connection refused"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "Failed to get RBAC policy",
+		},
+		{
+			name:           "Stytch server error",
+			err:            stytcherror.Error{StatusCode: 500, RequestID: "req-3", ErrorMessage: "stack trace"},
+			expectedStatus: http.StatusInternalServerError,
+			expectedError:  "Failed to get RBAC policy",
+		},
+		{
+			name:           "Stytch client error is still shown",
+			err:            stytcherror.Error{StatusCode: 400, ErrorMessage: "Bad project."},
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Failed to get RBAC policy: Bad project.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockRBACPolicyClient{
+				getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+					return nil, tt.err
+				},
+			}
+			h := NewHandler(client, "test-project-id", zap.NewNop(), WithHideInternalErrors())
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/rbacpolicy",
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+			var body map[string]string
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if body["error"] != tt.expectedError {
+				t.Errorf("Expected error %q, got %q", tt.expectedError, body["error"])
+			}
+		})
+	}
+}
+
+func TestStatusErrorResponseHidesUnexpectedErrors(t *testing.T) {
+	h := NewHandler(&mockRBACPolicyClient{}, "test-project-id", zap.NewNop(), WithHideInternalErrors())
+
+	response, err := h.statusErrorResponse(errors.New("secret detail"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, response.StatusCode)
+	}
+	if strings.Contains(response.Body, "secret detail") {
+		t.Errorf("Expected error text to be hidden, got %s", response.Body)
+	}
+}
+
+func TestCircuitBreakerOpen(t *testing.T) {
+	client := &mockRBACPolicyClient{
+		getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+			return nil, &stytchclient.OpenError{RetryAfter: 2500 * time.Millisecond}
+		},
+	}
+	h := NewHandler(client, "test-project-id", zap.NewNop(), WithHideInternalErrors())
+
+	for _, request := range []events.ALBTargetGroupRequest{
+		{HTTPMethod: http.MethodGet, Path: "/rbacpolicy"},
+		{HTTPMethod: http.MethodDelete, Path: "/rbacpolicy/roles/editor"},
+	} {
+		response, err := h.HandleRequest(context.Background(), request)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("%s %s: expected status code %d, got %d", request.HTTPMethod, request.Path, http.StatusServiceUnavailable, response.StatusCode)
+		}
+		if response.Headers["Retry-After"] != "3" {
+			t.Errorf("%s %s: expected Retry-After 3, got %q", request.HTTPMethod, request.Path, response.Headers["Retry-After"])
+		}
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{0, "1"},
+		{time.Millisecond, "1"},
+		{time.Second, "1"},
+		{1001 * time.Millisecond, "2"},
+		{30 * time.Second, "30"},
+	}
+	for _, tt := range tests {
+		if got := retryAfterSeconds(tt.d); got != tt.expected {
+			t.Errorf("retryAfterSeconds(%v) = %q, expected %q", tt.d, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// policyETag returns the strong entity tag for a policy. It is derived from
+// the canonical form, so reordering roles or actions does not change it.
+func policyETag(p rbacpolicy.Policy) string {
+	return `"` + rbac.Hash(p) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value
+// matches etag. The header may be "*" or a comma-separated list of tags.
+// If-Match requires strong comparison, so weak tags never match when strong
+// is set; If-None-Match uses weak comparison.
+func etagMatches(headerValue, etag string, strong bool) bool {
+	if headerValue == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(headerValue, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak, ok := strings.CutPrefix(candidate, "W/"); ok {
+			if strong {
+				continue
+			}
+			candidate = weak
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func notModifiedResponse(etag string) events.ALBTargetGroupResponse {
+	return events.ALBTargetGroupResponse{
+		StatusCode:        http.StatusNotModified,
+		StatusDescription: http.StatusText(http.StatusNotModified),
+		Headers: map[string]string{
+			"ETag": etag,
+		},
+		Body:            "",
+		IsBase64Encoded: false,
+	}
+}
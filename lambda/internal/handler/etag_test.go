@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+)
+
+func TestEtagMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		headerValue string
+		strong      bool
+		want        bool
+	}{
+		{name: "Empty header", headerValue: "", strong: true, want: false},
+		{name: "Exact match", headerValue: `"abc"`, strong: true, want: true},
+		{name: "Mismatch", headerValue: `"def"`, strong: true, want: false},
+		{name: "Wildcard", headerValue: "*", strong: true, want: true},
+		{name: "List with match", headerValue: `"def", "abc"`, strong: true, want: true},
+		{name: "Weak tag with strong comparison", headerValue: `W/"abc"`, strong: true, want: false},
+		{name: "Weak tag with weak comparison", headerValue: `W/"abc"`, strong: false, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.headerValue, `"abc"`, tt.strong); got != tt.want {
+				t.Errorf("etagMatches(%q) = %v, want %v", tt.headerValue, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyETagHeaders(t *testing.T) {
+	policy := testRolePolicy()
+	etag := policyETag(policy)
+	handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+	response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodGet,
+		Path:       "/rbacpolicy",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.Headers["ETag"] != etag {
+		t.Errorf("Expected GET ETag %s, got %s", etag, response.Headers["ETag"])
+	}
+
+	response, err = handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodPut,
+		Path:       "/rbacpolicy/roles/auditor",
+		Headers:    map[string]string{"if-match": etag},
+		Body:       `{"description":"Auditor"}`,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d", http.StatusCreated, response.StatusCode)
+	}
+	if response.Headers["ETag"] != policyETag(policy) || response.Headers["ETag"] == etag {
+		t.Errorf("Expected PUT to return the new policy ETag, got %s", response.Headers["ETag"])
+	}
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	policy := testRolePolicy()
+	etag := policyETag(policy)
+
+	tests := []struct {
+		name           string
+		headers        map[string]string
+		multiHeaders   map[string][]string
+		expectedStatus int
+	}{
+		{name: "No header", expectedStatus: http.StatusOK},
+		{name: "Matching ETag", headers: map[string]string{"if-none-match": etag}, expectedStatus: http.StatusNotModified},
+		{name: "Weak matching ETag", headers: map[string]string{"If-None-Match": "W/" + etag}, expectedStatus: http.StatusNotModified},
+		{name: "Multi-value header", multiHeaders: map[string][]string{"if-none-match": {`"stale"`, etag}}, expectedStatus: http.StatusNotModified},
+		{name: "Stale ETag", headers: map[string]string{"if-none-match": `"stale"`}, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod:        http.MethodGet,
+				Path:              "/rbacpolicy",
+				Headers:           tt.headers,
+				MultiValueHeaders: tt.multiHeaders,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+			if response.StatusCode == http.StatusNotModified && response.Body != "" {
+				t.Errorf("Expected empty body on 304, got %q", response.Body)
+			}
+		})
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		ifMatch        func(etag string) string
+		expectedStatus int
+	}{
+		{
+			name:           "PUT with current ETag",
+			method:         http.MethodPut,
+			path:           "/rbacpolicy",
+			body:           `{"custom_roles":[]}`,
+			ifMatch:        func(etag string) string { return etag },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "PUT with stale ETag",
+			method:         http.MethodPut,
+			path:           "/rbacpolicy",
+			body:           `{"custom_roles":[]}`,
+			ifMatch:        func(string) string { return `"stale"` },
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "DELETE with stale ETag",
+			method:         http.MethodDelete,
+			path:           "/rbacpolicy",
+			ifMatch:        func(string) string { return `"stale"` },
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "DELETE role with stale ETag",
+			method:         http.MethodDelete,
+			path:           "/rbacpolicy/roles/viewer",
+			ifMatch:        func(string) string { return `"stale"` },
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "DELETE with wildcard",
+			method:         http.MethodDelete,
+			path:           "/rbacpolicy",
+			ifMatch:        func(string) string { return "*" },
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			etag := policyETag(policy)
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: tt.method,
+				Path:       tt.path,
+				Headers:    map[string]string{"if-match": tt.ifMatch(etag)},
+				Body:       tt.body,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+
+			if tt.expectedStatus == http.StatusPreconditionFailed {
+				if response.Headers["ETag"] != etag {
+					t.Errorf("Expected 412 to carry current ETag %s, got %s", etag, response.Headers["ETag"])
+				}
+				if policyETag(policy) != etag {
+					t.Errorf("Expected policy to be unchanged after 412")
+				}
+			}
+		})
+	}
+}
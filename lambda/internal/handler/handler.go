@@ -3,14 +3,36 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/audit"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/desired"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/validation"
 	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
 	"go.uber.org/zap"
 )
 
+const (
+	policyPath          = "/rbacpolicy"
+	diffPath            = "/rbacpolicy/diff"
+	checkPath           = "/rbacpolicy/check"
+	promotePath         = "/rbacpolicy/promote"
+	driftPath           = "/rbacpolicy/drift"
+	rolesPathPrefix     = "/rbacpolicy/roles/"
+	resourcesPathPrefix = "/rbacpolicy/resources/"
+	historyPath         = "/rbacpolicy/history"
+	historyPathPrefix   = "/rbacpolicy/history/"
+)
+
 type RBACPolicyClient interface {
 	Get(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error)
 	Set(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error)
@@ -20,14 +42,41 @@ type Handler struct {
 	client    RBACPolicyClient
 	projectID string
 	logger    *zap.Logger
+	history   history.Store
+	audit     audit.Sink
+	cache     *policyCache
+	desired   desired.Source
+
+	// projects maps the names accepted in /rbacpolicy/projects/{project_id}
+	// paths to project IDs.
+	projects map[string]string
+
+	// healthCheckTimeout bounds the Stytch call made by a deep health check.
+	healthCheckTimeout time.Duration
+
+	authenticator Authenticator
+	authorizer    Authorizer
+	breaker       CircuitBreaker
+
+	hideInternalErrors bool
+	driftAutoReconcile bool
 }
 
-func NewHandler(client RBACPolicyClient, projectID string, logger *zap.Logger) *Handler {
-	return &Handler{
+func NewHandler(client RBACPolicyClient, projectID string, logger *zap.Logger, opts ...Option) *Handler {
+	h := &Handler{
 		client:    client,
 		projectID: projectID,
 		logger:    logger,
+		history:   history.NewMemoryStore(),
+		audit:     audit.NewLogSink(logger),
+		cache:     newPolicyCache(0),
+
+		healthCheckTimeout: defaultHealthCheckTimeout,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 func (h *Handler) HandleRequest(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
@@ -36,147 +85,493 @@ func (h *Handler) HandleRequest(ctx context.Context, request events.ALBTargetGro
 		zap.String("path", request.Path),
 	)
 
-	// Handle health check endpoint
-	if (request.Path == "/health" || request.Path == "/rbacpolicy/health") && request.HTTPMethod == http.MethodGet {
-		return h.handleHealthCheck()
+	// The shallow health check is answered before authentication so that the
+	// ALB target group can probe without a token. It touches nothing outside
+	// the container, and is only served unauthenticated on the unprefixed
+	// paths so that it cannot be used to probe which projects are configured.
+	if isShallowHealthCheck(request) {
+		return h.handleHealthCheck(ctx, request)
+	}
+
+	request, route, routeErr := h.routeProject(request)
+	ctx = withProjectRoute(ctx, route)
+
+	if h.authenticator != nil {
+		authCtx, err := h.authenticate(ctx, request)
+		if err != nil {
+			return h.statusErrorResponse(err)
+		}
+		ctx = authCtx
+	}
+
+	// Unknown projects are reported only to authenticated callers, so the
+	// allow-list cannot be probed anonymously.
+	if routeErr != nil {
+		return h.statusErrorResponse(routeErr)
+	}
+
+	if err := h.authorize(ctx, request); err != nil {
+		if isAuditedWrite(request) {
+			h.recordAudit(ctx, request, auditAttempt{outcome: audit.OutcomeDenied, err: err})
+		}
+		return h.statusErrorResponse(err)
+	}
+
+	// Writes refused by their handler before updatePolicy, such as a body
+	// that does not parse, are audited here so that every write attempt has
+	// a record.
+	if !isAuditedWrite(request) {
+		return h.dispatch(ctx, request)
+	}
+	ctx, audited := withAuditMark(ctx)
+	response, err := h.dispatch(ctx, request)
+	if err == nil && response.StatusCode >= http.StatusBadRequest && !audited.Load() {
+		h.recordAudit(ctx, request, refusedWrite(response))
 	}
+	return response, err
+}
 
-	switch request.HTTPMethod {
-	case http.MethodGet:
-		return h.handleGet(ctx)
-	case http.MethodPut:
-		return h.handlePut(ctx, request.Body)
-	case http.MethodPost:
-		return h.handlePut(ctx, request.Body)
-	case http.MethodDelete:
-		return h.handleDelete(ctx)
+// dispatch serves an authenticated and authorized request.
+func (h *Handler) dispatch(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	switch ep := resolveEndpoint(request.Path); ep.kind {
+	// Deep health checks call Stytch and report the project ID and policy
+	// hash, so they need the same authentication and read_policy as a GET.
+	case endpointHealth:
+		if request.HTTPMethod != http.MethodGet {
+			return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return h.handleHealthCheck(ctx, request)
+	case endpointReady:
+		if request.HTTPMethod != http.MethodGet {
+			return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return h.handleDeepHealthCheck(ctx)
+	case endpointDiff:
+		return h.handleDiff(ctx, request)
+	case endpointCheck:
+		return h.handleCheck(ctx, request)
+	case endpointPromote:
+		return h.handlePromote(ctx, request)
+	case endpointDrift:
+		return h.handleDrift(ctx, request)
+	case endpointHistory:
+		return h.handleHistory(ctx, request)
+	case endpointSnapshot:
+		return h.handleSnapshot(ctx, request, ep.id, ep.sub)
+	case endpointRole:
+		return h.handleRole(ctx, request, ep.id, ep.sub)
+	case endpointResource:
+		return h.handleResource(ctx, request, ep.id, ep.sub)
+	case endpointPolicy:
+		switch request.HTTPMethod {
+		case http.MethodGet:
+			return h.handleGet(ctx, request)
+		case http.MethodPut:
+			return h.handlePut(ctx, request)
+		case http.MethodPost:
+			return h.handlePut(ctx, request)
+		case http.MethodPatch:
+			return h.handlePatch(ctx, request)
+		case http.MethodDelete:
+			return h.handleDelete(ctx, request)
+		default:
+			return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+		}
 	default:
-		return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+		return h.errorResponse(http.StatusNotFound, "Not found")
 	}
 }
 
-func (h *Handler) handleGet(ctx context.Context) (events.ALBTargetGroupResponse, error) {
-	req := rbacpolicy.GetRequest{
-		ProjectID: h.projectID,
+// endpointKind identifies the handler that serves a request path.
+type endpointKind int
+
+const (
+	endpointNotFound endpointKind = iota
+	endpointPolicy
+	endpointHealth
+	endpointReady
+	endpointDiff
+	endpointCheck
+	endpointPromote
+	endpointDrift
+	endpointHistory
+	endpointSnapshot
+	endpointRole
+	endpointResource
+)
+
+// endpoint is a request path resolved to its handler. id and sub are the
+// path parameters of snapshot, role and resource paths.
+type endpoint struct {
+	kind endpointKind
+	id   string
+	sub  string
+}
+
+// resolveEndpoint resolves a path with any project prefix already removed.
+// Dispatch and authorization both use it, so a request is always authorized
+// for the handler that serves it. The whole policy is served only on the
+// exact collection path; anything unrecognized is endpointNotFound.
+func resolveEndpoint(path string) endpoint {
+	switch path {
+	case policyPath:
+		return endpoint{kind: endpointPolicy}
+	case "/health", "/rbacpolicy/health":
+		return endpoint{kind: endpointHealth}
+	case "/ready", "/rbacpolicy/ready":
+		return endpoint{kind: endpointReady}
+	case diffPath:
+		return endpoint{kind: endpointDiff}
+	case checkPath:
+		return endpoint{kind: endpointCheck}
+	case promotePath:
+		return endpoint{kind: endpointPromote}
+	case driftPath:
+		return endpoint{kind: endpointDrift}
+	case historyPath:
+		return endpoint{kind: endpointHistory}
 	}
 
-	resp, err := h.client.Get(ctx, req)
-	if err != nil {
-		h.logger.Error("Failed to get RBAC policy", zap.Error(err))
-		return h.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to get RBAC policy: %v", err))
+	for _, collection := range []struct {
+		prefix string
+		kind   endpointKind
+	}{
+		{historyPathPrefix, endpointSnapshot},
+		{rolesPathPrefix, endpointRole},
+		{resourcesPathPrefix, endpointResource},
+	} {
+		if rest, ok := strings.CutPrefix(path, collection.prefix); ok {
+			id, sub, ok := pathID(rest)
+			if !ok {
+				return endpoint{kind: endpointNotFound}
+			}
+			return endpoint{kind: collection.kind, id: id, sub: sub}
+		}
 	}
+	return endpoint{kind: endpointNotFound}
+}
 
-	body, err := json.Marshal(resp.Policy)
+func (h *Handler) handleGet(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	policy, err := h.getPolicy(ctx, request)
 	if err != nil {
-		h.logger.Error("Failed to marshal response", zap.Error(err))
-		return h.errorResponse(http.StatusInternalServerError, "Failed to marshal response")
+		return h.statusErrorResponse(err)
 	}
 
-	return events.ALBTargetGroupResponse{
-		StatusCode:        http.StatusOK,
-		StatusDescription: http.StatusText(http.StatusOK),
-		Headers: map[string]string{
-			"Content-Type": "application/json",
-		},
-		Body:            string(body),
-		IsBase64Encoded: false,
-	}, nil
+	etag := policyETag(policy)
+	if etagMatches(header(request, "If-None-Match"), etag, false) {
+		return notModifiedResponse(etag), nil
+	}
+
+	return h.policyResponse(http.StatusOK, policy, policy)
+}
+
+// getPolicy fetches the project's current policy for a read, from the cache
+// when possible. Failures are returned as a *statusError.
+func (h *Handler) getPolicy(ctx context.Context, request events.ALBTargetGroupRequest) (rbacpolicy.Policy, error) {
+	if !noCache(request) {
+		if policy, ok := h.cache.get(h.project(ctx)); ok {
+			return policy, nil
+		}
+	}
+	return h.getLivePolicy(ctx)
+}
+
+// getLivePolicy fetches the project's policy straight from Stytch, for
+// answers that must not be up to a cache TTL stale, and refreshes the cache
+// with it. Failures are returned as a *statusError.
+func (h *Handler) getLivePolicy(ctx context.Context) (rbacpolicy.Policy, error) {
+	resp, err := h.client.Get(ctx, rbacpolicy.GetRequest{ProjectID: h.project(ctx)})
+	if err != nil {
+		h.logger.Error("Failed to get RBAC policy", zap.Error(err))
+		return rbacpolicy.Policy{}, h.clientError(err, "Failed to get RBAC policy")
+	}
+	h.cache.put(h.project(ctx), resp.Policy)
+	return resp.Policy, nil
 }
 
-func (h *Handler) handlePut(ctx context.Context, body string) (events.ALBTargetGroupResponse, error) {
+func (h *Handler) handlePut(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
 	var policy rbacpolicy.Policy
-	if err := json.Unmarshal([]byte(body), &policy); err != nil {
+	if err := json.Unmarshal([]byte(request.Body), &policy); err != nil {
 		h.logger.Error("Failed to unmarshal request body", zap.Error(err))
 		return h.errorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
 	}
 
-	req := rbacpolicy.SetRequest{
-		ProjectID: h.projectID,
-		Policy:    policy,
-	}
-
-	resp, err := h.client.Set(ctx, req)
+	write, err := h.updatePolicy(ctx, request, func(p *rbacpolicy.Policy) error {
+		*p = policy
+		return nil
+	})
 	if err != nil {
-		h.logger.Error("Failed to set RBAC policy", zap.Error(err))
-		return h.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to set RBAC policy: %v", err))
+		return h.statusErrorResponse(err)
 	}
+	if write.dryRun {
+		return h.dryRunResponse(write)
+	}
+
+	return h.policyResponse(http.StatusOK, write.after, write.after)
+}
 
-	responseBody, err := json.Marshal(resp.Policy)
+func (h *Handler) handleDelete(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	write, err := h.updatePolicy(ctx, request, func(p *rbacpolicy.Policy) error {
+		// Clear only custom roles and resources, preserve stytch roles
+		p.CustomRoles = []rbacpolicy.Role{}
+		p.CustomResources = []rbacpolicy.Resource{}
+		return nil
+	})
 	if err != nil {
-		h.logger.Error("Failed to marshal response", zap.Error(err))
-		return h.errorResponse(http.StatusInternalServerError, "Failed to marshal response")
+		return h.statusErrorResponse(err)
+	}
+	if write.dryRun {
+		return h.dryRunResponse(write)
 	}
 
-	return events.ALBTargetGroupResponse{
-		StatusCode:        http.StatusOK,
-		StatusDescription: http.StatusText(http.StatusOK),
-		Headers: map[string]string{
-			"Content-Type": "application/json",
-		},
-		Body:            string(responseBody),
-		IsBase64Encoded: false,
-	}, nil
+	return h.policyNoContentResponse(write.after), nil
 }
 
-func (h *Handler) handleDelete(ctx context.Context) (events.ALBTargetGroupResponse, error) {
-	// First get the current policy to preserve stytch roles
-	getReq := rbacpolicy.GetRequest{
-		ProjectID: h.projectID,
+// updatePolicy performs a read-modify-write of the project's RBAC policy.
+// mutate receives the current policy and edits it in place; returning a
+// *statusError from mutate aborts the write with that status. If the request
+// carries If-Match, the write is refused with 412 unless it matches the
+// current policy's ETag. The result is validated before it is sent to Stytch
+// and rejected with 422 if it has any violations, or with 403 if the caller
+// may not make every change in it. With ?dry_run=true nothing is written and
+// the returned policyWrite has dryRun set. Otherwise the current policy is
+// snapshotted before it is overwritten, and the write is abandoned if the
+// snapshot cannot be saved. Every attempt other than a dry run is recorded in
+// the audit log with its outcome, except when mutate returns an error that is
+// not a *statusError, which callers use to abandon a write on purpose. An
+// invalid dry_run is left to HandleRequest to audit with the other refusals.
+func (h *Handler) updatePolicy(ctx context.Context, request events.ALBTargetGroupRequest, mutate func(*rbacpolicy.Policy) error) (*policyWrite, error) {
+	dryRun, err := parseBoolParam(queryParam(request, "dry_run"))
+	if err != nil {
+		return nil, &statusError{
+			statusCode: http.StatusBadRequest,
+			message:    "Invalid dry_run parameter",
+		}
+	}
+	refuse := func(attempt auditAttempt) (*policyWrite, error) {
+		if !dryRun {
+			h.recordAudit(ctx, request, attempt)
+		}
+		return nil, attempt.err
 	}
-	
-	getResp, err := h.client.Get(ctx, getReq)
+
+	getResp, err := h.client.Get(ctx, rbacpolicy.GetRequest{ProjectID: h.project(ctx)})
 	if err != nil {
 		h.logger.Error("Failed to get current RBAC policy", zap.Error(err))
-		return h.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to get current RBAC policy: %v", err))
+		return refuse(auditAttempt{outcome: audit.OutcomeFailed, err: h.clientError(err, "Failed to get current RBAC policy")})
+	}
+	before := &getResp.Policy
+
+	if ifMatch := header(request, "If-Match"); ifMatch != "" {
+		current := policyETag(getResp.Policy)
+		if !etagMatches(ifMatch, current, true) {
+			h.logger.Info("Rejecting write with stale ETag",
+				zap.String("if_match", ifMatch),
+				zap.String("etag", current),
+			)
+			return refuse(auditAttempt{outcome: audit.OutcomeStale, before: before, err: &statusError{
+				statusCode: http.StatusPreconditionFailed,
+				message:    "RBAC policy has changed since it was read",
+				headers:    map[string]string{"ETag": current},
+			}})
+		}
+	}
+
+	policy := rbac.Clone(getResp.Policy)
+	if err := mutate(&policy); err != nil {
+		var se *statusError
+		if !errors.As(err, &se) {
+			return nil, err
+		}
+		return refuse(auditAttempt{outcome: audit.OutcomeRejected, before: before, err: err})
+	}
+
+	// Stytch resources cannot be changed by a write, so validate against the
+	// live set even when the client omitted them.
+	proposed := policy
+	proposed.StytchResources = getResp.Policy.StytchResources
+	if violations := validation.Validate(proposed); violations != nil {
+		h.logger.Info("Rejecting invalid RBAC policy", zap.Int("violations", len(violations)))
+		return refuse(auditAttempt{outcome: audit.OutcomeInvalid, before: before, after: &proposed, err: &statusError{
+			statusCode: http.StatusUnprocessableEntity,
+			message:    "RBAC policy failed validation",
+			details:    map[string]any{"violations": violations},
+		}})
+	}
+
+	if err := h.authorizeChange(ctx, request, rbac.Compare(getResp.Policy, proposed)); err != nil {
+		return refuse(auditAttempt{outcome: audit.OutcomeDenied, before: before, after: &proposed, err: err})
+	}
+
+	if dryRun {
+		return &policyWrite{before: getResp.Policy, after: proposed, dryRun: true}, nil
 	}
-	
-	// Clear only custom roles and resources, preserve stytch roles
-	clearedPolicy := rbacpolicy.Policy{
-		StytchMember:    getResp.Policy.StytchMember,
-		StytchAdmin:     getResp.Policy.StytchAdmin,
-		StytchResources: getResp.Policy.StytchResources,
-		CustomRoles:     []rbacpolicy.Role{},
-		CustomResources: []rbacpolicy.Resource{},
+
+	if err := h.saveSnapshot(ctx, request, getResp.Policy); err != nil {
+		return refuse(auditAttempt{outcome: audit.OutcomeFailed, before: before, after: &proposed, err: err})
 	}
 
-	req := rbacpolicy.SetRequest{
-		ProjectID: h.projectID,
-		Policy:    clearedPolicy,
+	setResp, err := h.client.Set(ctx, rbacpolicy.SetRequest{
+		ProjectID: h.project(ctx),
+		Policy:    policy,
+	})
+	if err != nil {
+		h.logger.Error("Failed to set RBAC policy", zap.Error(err))
+		return refuse(auditAttempt{outcome: audit.OutcomeFailed, before: before, after: &proposed, err: h.clientError(err, "Failed to set RBAC policy")})
 	}
 
-	_, err = h.client.Set(ctx, req)
+	h.cache.invalidate(h.project(ctx))
+	h.recordAudit(ctx, request, auditAttempt{outcome: audit.OutcomeSuccess, before: before, after: &setResp.Policy})
+
+	return &policyWrite{before: getResp.Policy, after: setResp.Policy}, nil
+}
+
+// policyWrite is the outcome of updatePolicy. after is the policy Stytch
+// stored or, for a dry run, the policy that would have been sent.
+type policyWrite struct {
+	before rbacpolicy.Policy
+	after  rbacpolicy.Policy
+	dryRun bool
+}
+
+// dryRunResponse reports what a write would have done. The ETag is that of
+// the current policy, so the change can be applied with If-Match.
+func (h *Handler) dryRunResponse(write *policyWrite) (events.ALBTargetGroupResponse, error) {
+	return h.policyResponse(http.StatusOK, map[string]any{
+		"dry_run": true,
+		"policy":  write.after,
+		"diff":    rbac.Compare(write.before, write.after),
+	}, write.before)
+}
+
+func (h *Handler) jsonResponse(statusCode int, v any) (events.ALBTargetGroupResponse, error) {
+	body, err := json.Marshal(v)
 	if err != nil {
-		h.logger.Error("Failed to clear RBAC policy", zap.Error(err))
-		return h.errorResponse(http.StatusInternalServerError, fmt.Sprintf("Failed to clear RBAC policy: %v", err))
+		h.logger.Error("Failed to marshal response", zap.Error(err))
+		return h.errorResponse(http.StatusInternalServerError, "Failed to marshal response")
 	}
 
 	return events.ALBTargetGroupResponse{
-		StatusCode:        http.StatusNoContent,
-		StatusDescription: http.StatusText(http.StatusNoContent),
+		StatusCode:        statusCode,
+		StatusDescription: http.StatusText(statusCode),
 		Headers: map[string]string{
 			"Content-Type": "application/json",
 		},
-		Body:            "",
+		Body:            string(body),
 		IsBase64Encoded: false,
 	}, nil
 }
 
-func (h *Handler) handleHealthCheck() (events.ALBTargetGroupResponse, error) {
-	healthResponse := map[string]string{
-		"status": "healthy",
+// policyResponse is jsonResponse with an ETag header for the given policy.
+func (h *Handler) policyResponse(statusCode int, v any, policy rbacpolicy.Policy) (events.ALBTargetGroupResponse, error) {
+	response, err := h.jsonResponse(statusCode, v)
+	if response.StatusCode == statusCode {
+		response.Headers["ETag"] = policyETag(policy)
 	}
+	return response, err
+}
 
-	body, _ := json.Marshal(healthResponse)
-
+// policyNoContentResponse is a 204 carrying the ETag of the stored policy.
+func (h *Handler) policyNoContentResponse(policy rbacpolicy.Policy) events.ALBTargetGroupResponse {
 	return events.ALBTargetGroupResponse{
-		StatusCode:        http.StatusOK,
-		StatusDescription: http.StatusText(http.StatusOK),
+		StatusCode:        http.StatusNoContent,
+		StatusDescription: http.StatusText(http.StatusNoContent),
 		Headers: map[string]string{
 			"Content-Type": "application/json",
+			"ETag":         policyETag(policy),
 		},
-		Body:            string(body),
+		Body:            "",
 		IsBase64Encoded: false,
-	}, nil
+	}
+}
+
+// pathID splits "{id}" or "{id}/{sub}" into the decoded ID and the optional
+// sub-resource name, rejecting empty IDs and deeper nesting.
+func pathID(rest string) (id, sub string, ok bool) {
+	escaped, sub, _ := strings.Cut(rest, "/")
+	if strings.Contains(sub, "/") {
+		return "", "", false
+	}
+	id, err := url.PathUnescape(escaped)
+	if err != nil || id == "" || strings.Contains(id, "/") {
+		return "", "", false
+	}
+	return id, sub, true
+}
+
+// header returns the value of a request header, matched case-insensitively
+// across both the single and multi-value header maps.
+func header(request events.ALBTargetGroupRequest, name string) string {
+	for k, v := range request.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	for k, v := range request.MultiValueHeaders {
+		if strings.EqualFold(k, name) && len(v) > 0 {
+			return strings.Join(v, ", ")
+		}
+	}
+	return ""
+}
+
+// queryParam returns the decoded value of a query string parameter. ALB passes
+// query strings through undecoded, in either the single or multi-value map
+// depending on the target group configuration.
+func queryParam(request events.ALBTargetGroupRequest, name string) string {
+	raw, ok := request.QueryStringParameters[name]
+	if !ok {
+		values := request.MultiValueQueryStringParameters[name]
+		if len(values) == 0 {
+			return ""
+		}
+		raw = values[len(values)-1]
+	}
+	value, err := url.QueryUnescape(raw)
+	if err != nil {
+		return raw
+	}
+	return value
+}
+
+// parseBoolParam parses an optional boolean query parameter, defaulting to false.
+func parseBoolParam(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// statusError is returned by request processing steps that want to fail the
+// request with a specific HTTP status rather than a 500.
+type statusError struct {
+	statusCode int
+	message    string
+	// details are merged into the JSON error body alongside "error".
+	details map[string]any
+	// headers are added to the error response.
+	headers map[string]string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+func (h *Handler) statusErrorResponse(err error) (events.ALBTargetGroupResponse, error) {
+	var se *statusError
+	if !errors.As(err, &se) {
+		se = h.internalError(err, "Internal server error")
+	}
+
+	body := map[string]any{"error": se.message}
+	for k, v := range se.details {
+		body[k] = v
+	}
+	response, err := h.jsonResponse(se.statusCode, body)
+	for k, v := range se.headers {
+		response.Headers[k] = v
+	}
+	return response, err
 }
 
 func (h *Handler) errorResponse(statusCode int, message string) (events.ALBTargetGroupResponse, error) {
@@ -5,9 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient"
 	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
 	"go.uber.org/zap"
 )
@@ -31,6 +35,25 @@ func (m *mockRBACPolicyClient) Set(ctx context.Context, body rbacpolicy.SetReque
 	return nil, errors.New("set not implemented")
 }
 
+// getEmptyPolicy is a getFunc for tests that only care about the write path.
+func getEmptyPolicy(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+	return &rbacpolicy.GetResponse{StatusCode: 200}, nil
+}
+
+// newStatefulMock returns a mock client that serves Get from *policy and
+// stores every Set back into it, so read-modify-write flows can be asserted.
+func newStatefulMock(policy *rbacpolicy.Policy) *mockRBACPolicyClient {
+	return &mockRBACPolicyClient{
+		getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+			return &rbacpolicy.GetResponse{StatusCode: 200, Policy: *policy}, nil
+		},
+		setFunc: func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+			*policy = body.Policy
+			return &rbacpolicy.SetResponse{StatusCode: 200, Policy: body.Policy}, nil
+		},
+	}
+}
+
 // mockUnmarshalableType is a type that causes json.Marshal to fail
 type mockUnmarshalableType struct {
 	Channel chan int `json:"channel"` // channels cannot be marshaled to JSON
@@ -164,6 +187,7 @@ func TestHandlePut(t *testing.T) {
 			name: "Successful PUT",
 			body: string(validPolicyJSON),
 			mockClient: &mockRBACPolicyClient{
+				getFunc: getEmptyPolicy,
 				setFunc: func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
 					return &rbacpolicy.SetResponse{
 						StatusCode: 200,
@@ -186,6 +210,7 @@ func TestHandlePut(t *testing.T) {
 			name: "Failed PUT - Client error",
 			body: string(validPolicyJSON),
 			mockClient: &mockRBACPolicyClient{
+				getFunc: getEmptyPolicy,
 				setFunc: func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
 					return nil, errors.New("client error")
 				},
@@ -239,11 +264,19 @@ func TestHandlePost(t *testing.T) {
 				},
 			},
 		},
+		CustomResources: []rbacpolicy.Resource{
+			{
+				ResourceID:       "documents",
+				Description:      "Document resources",
+				AvailableActions: []string{"read"},
+			},
+		},
 	}
 
 	validPolicyJSON, _ := json.Marshal(validPolicy)
 
 	mockClient := &mockRBACPolicyClient{
+		getFunc: getEmptyPolicy,
 		setFunc: func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
 			return &rbacpolicy.SetResponse{
 				StatusCode: 200,
@@ -285,6 +318,15 @@ func TestHandleDelete(t *testing.T) {
 		{
 			name: "Successful DELETE",
 			mockClient: &mockRBACPolicyClient{
+				getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+					return &rbacpolicy.GetResponse{
+						StatusCode: 200,
+						RequestID:  "req-get",
+						Policy: rbacpolicy.Policy{
+							CustomRoles: []rbacpolicy.Role{{RoleID: "editor"}},
+						},
+					}, nil
+				},
 				setFunc: func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
 					// Verify that an empty policy is being set
 					if len(body.Policy.CustomRoles) != 0 || len(body.Policy.CustomResources) != 0 {
@@ -303,6 +345,7 @@ func TestHandleDelete(t *testing.T) {
 		{
 			name: "Failed DELETE - Client error",
 			mockClient: &mockRBACPolicyClient{
+				getFunc: getEmptyPolicy,
 				setFunc: func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
 					return nil, errors.New("client error")
 				},
@@ -346,7 +389,7 @@ func TestHandleUnsupportedMethod(t *testing.T) {
 	handler := NewHandler(mockClient, projectID, logger)
 
 	request := events.ALBTargetGroupRequest{
-		HTTPMethod: http.MethodPatch,
+		HTTPMethod: http.MethodOptions,
 		Path:       "/rbacpolicy",
 	}
 
@@ -361,6 +404,74 @@ func TestHandleUnsupportedMethod(t *testing.T) {
 	}
 }
 
+func TestUnknownPathNotFound(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodDelete, path: "/rbacpolicy/"},
+		{method: http.MethodDelete, path: "/rbacpolicy/role/editor"},
+		{method: http.MethodDelete, path: "/rbacpolicy/rolez"},
+		{method: http.MethodDelete, path: "/anything"},
+		{method: http.MethodGet, path: "/rbacpolicy/role/editor"},
+		{method: http.MethodPut, path: "/rbacpolicy/policy"},
+		{method: http.MethodPatch, path: "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			policy := testRolePolicy()
+			original := rbac.Clone(policy)
+			h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: tt.method,
+				Path:       tt.path,
+				Body:       `{"custom_roles": [], "custom_resources": []}`,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != http.StatusNotFound {
+				t.Errorf("Expected status code %d, got %d: %s", http.StatusNotFound, response.StatusCode, response.Body)
+			}
+			if !reflect.DeepEqual(policy, original) {
+				t.Errorf("Expected the policy to be left alone, got %+v", policy)
+			}
+		})
+	}
+}
+
+func TestResolveEndpoint(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected endpoint
+	}{
+		{path: "/rbacpolicy", expected: endpoint{kind: endpointPolicy}},
+		{path: "/rbacpolicy/", expected: endpoint{kind: endpointNotFound}},
+		{path: "/rbacpolicy/rolez", expected: endpoint{kind: endpointNotFound}},
+		{path: "/anything", expected: endpoint{kind: endpointNotFound}},
+		{path: "/health", expected: endpoint{kind: endpointHealth}},
+		{path: "/rbacpolicy/ready", expected: endpoint{kind: endpointReady}},
+		{path: "/rbacpolicy/diff", expected: endpoint{kind: endpointDiff}},
+		{path: "/rbacpolicy/history", expected: endpoint{kind: endpointHistory}},
+		{path: "/rbacpolicy/history/v1/restore", expected: endpoint{kind: endpointSnapshot, id: "v1", sub: "restore"}},
+		{path: "/rbacpolicy/history/v1/restore/", expected: endpoint{kind: endpointNotFound}},
+		{path: "/rbacpolicy/roles/editor", expected: endpoint{kind: endpointRole, id: "editor"}},
+		{path: "/rbacpolicy/roles/", expected: endpoint{kind: endpointNotFound}},
+		{path: "/rbacpolicy/roles/billing%20admin/effective", expected: endpoint{kind: endpointRole, id: "billing admin", sub: "effective"}},
+		{path: "/rbacpolicy/resources/documents/grants", expected: endpoint{kind: endpointResource, id: "documents", sub: "grants"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := resolveEndpoint(tt.path); got != tt.expected {
+				t.Errorf("resolveEndpoint(%q) = %+v, expected %+v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestErrorResponse(t *testing.T) {
 	logger := zap.NewNop()
 	projectID := "test-project-id"
@@ -417,3 +528,272 @@ func TestErrorResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestHandlePutValidation(t *testing.T) {
+	live := rbacpolicy.Policy{
+		StytchResources: []rbacpolicy.Resource{
+			{ResourceID: "stytch.self", AvailableActions: []string{"read"}},
+		},
+	}
+
+	tests := []struct {
+		name               string
+		body               string
+		expectedStatus     int
+		expectedViolations []string
+	}{
+		{
+			name:           "References live Stytch resource omitted from body",
+			body:           `{"custom_roles":[{"role_id":"reader","permissions":[{"resource_id":"stytch.self","actions":["read"]}]}]}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:               "Unknown resource and reserved prefix",
+			body:               `{"custom_roles":[{"role_id":"stytch_reader","permissions":[{"resource_id":"documents","actions":["read"]}]}]}`,
+			expectedStatus:     http.StatusUnprocessableEntity,
+			expectedViolations: []string{"custom_roles[0].role_id", "custom_roles[0].permissions[0].resource_id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := live
+			setCalled := false
+			mockClient := newStatefulMock(&policy)
+			setFunc := mockClient.setFunc
+			mockClient.setFunc = func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+				setCalled = true
+				return setFunc(ctx, body)
+			}
+			handler := NewHandler(mockClient, "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodPut,
+				Path:       "/rbacpolicy",
+				Body:       tt.body,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			if tt.expectedViolations == nil {
+				return
+			}
+
+			if setCalled {
+				t.Errorf("Expected Set not to be called for an invalid policy")
+			}
+			var body struct {
+				Error      string `json:"error"`
+				Violations []struct {
+					Field   string `json:"field"`
+					Message string `json:"message"`
+				} `json:"violations"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if len(body.Violations) != len(tt.expectedViolations) {
+				t.Fatalf("Expected violations %v, got %+v", tt.expectedViolations, body.Violations)
+			}
+			for i, field := range tt.expectedViolations {
+				if body.Violations[i].Field != field {
+					t.Errorf("Violation %d field = %q, want %q", i, body.Violations[i].Field, field)
+				}
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		headers          map[string]string
+		body             string
+		dryRun           string
+		expectedStatus   int
+		expectedAdded    []string
+		expectedRemoved  []string
+		expectedPolicyID string
+	}{
+		{
+			name:            "PUT",
+			method:          http.MethodPut,
+			path:            "/rbacpolicy",
+			body:            `{"custom_roles":[{"role_id":"auditor"}],"custom_resources":[{"resource_id":"documents","available_actions":["read"]}]}`,
+			dryRun:          "true",
+			expectedStatus:  http.StatusOK,
+			expectedAdded:   []string{"auditor"},
+			expectedRemoved: []string{"editor", "viewer"},
+		},
+		{
+			name:            "PATCH",
+			method:          http.MethodPatch,
+			path:            "/rbacpolicy",
+			headers:         map[string]string{"content-type": "application/json-patch+json"},
+			body:            `[{"op":"remove","path":"/custom_roles/1"}]`,
+			dryRun:          "true",
+			expectedStatus:  http.StatusOK,
+			expectedAdded:   []string{},
+			expectedRemoved: []string{"viewer"},
+		},
+		{
+			name:            "DELETE",
+			method:          http.MethodDelete,
+			path:            "/rbacpolicy",
+			dryRun:          "1",
+			expectedStatus:  http.StatusOK,
+			expectedAdded:   []string{},
+			expectedRemoved: []string{"editor", "viewer"},
+		},
+		{
+			name:            "DELETE role",
+			method:          http.MethodDelete,
+			path:            "/rbacpolicy/roles/editor",
+			dryRun:          "true",
+			expectedStatus:  http.StatusOK,
+			expectedAdded:   []string{},
+			expectedRemoved: []string{"editor"},
+		},
+		{
+			name:           "Invalid policy is still validated",
+			method:         http.MethodPut,
+			path:           "/rbacpolicy/roles/editor",
+			body:           `{"permissions":[{"resource_id":"reports","actions":["read"]}]}`,
+			dryRun:         "true",
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Invalid dry_run value",
+			method:         http.MethodDelete,
+			path:           "/rbacpolicy",
+			dryRun:         "sometimes",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			etag := policyETag(policy)
+			mockClient := newStatefulMock(&policy)
+			mockClient.setFunc = func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+				t.Errorf("Set must not be called on a dry run")
+				return nil, errors.New("unexpected set")
+			}
+			handler := NewHandler(mockClient, "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod:            tt.method,
+				Path:                  tt.path,
+				Headers:               tt.headers,
+				QueryStringParameters: map[string]string{"dry_run": tt.dryRun},
+				Body:                  tt.body,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if response.Headers["ETag"] != etag {
+				t.Errorf("Expected ETag of current policy %s, got %s", etag, response.Headers["ETag"])
+			}
+
+			var body struct {
+				DryRun bool              `json:"dry_run"`
+				Policy rbacpolicy.Policy `json:"policy"`
+				Diff   struct {
+					RolesAdded   []string `json:"roles_added"`
+					RolesRemoved []string `json:"roles_removed"`
+				} `json:"diff"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if !body.DryRun {
+				t.Errorf("Expected dry_run to be true")
+			}
+			if !slices.Equal(body.Diff.RolesAdded, tt.expectedAdded) {
+				t.Errorf("Expected roles added %v, got %v", tt.expectedAdded, body.Diff.RolesAdded)
+			}
+			if !slices.Equal(body.Diff.RolesRemoved, tt.expectedRemoved) {
+				t.Errorf("Expected roles removed %v, got %v", tt.expectedRemoved, body.Diff.RolesRemoved)
+			}
+			if len(body.Policy.CustomRoles) != 2+len(tt.expectedAdded)-len(tt.expectedRemoved) {
+				t.Errorf("Expected resulting policy in body, got %+v", body.Policy)
+			}
+		})
+	}
+}
+
+// fixedBreaker is a CircuitBreaker with a fixed status.
+type fixedBreaker stytchclient.BreakerStatus
+
+func (b fixedBreaker) Status() stytchclient.BreakerStatus {
+	return stytchclient.BreakerStatus(b)
+}
+
+func TestHandleHealthCheck(t *testing.T) {
+	tests := []struct {
+		name            string
+		opts            []Option
+		expectedBreaker map[string]any
+	}{
+		{
+			name: "Without circuit breaker",
+		},
+		{
+			name: "With open circuit breaker",
+			opts: []Option{WithCircuitBreaker(fixedBreaker{State: stytchclient.BreakerOpen, ConsecutiveFailures: 5, RetryAfterSeconds: 12})},
+			expectedBreaker: map[string]any{
+				"state":                "open",
+				"consecutive_failures": float64(5),
+				"retry_after_seconds":  float64(12),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(&mockRBACPolicyClient{}, "test-project-id", zap.NewNop(), tt.opts...)
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       "/health",
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != http.StatusOK {
+				t.Errorf("Expected status code %d, got %d", http.StatusOK, response.StatusCode)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if body["status"] != "healthy" {
+				t.Errorf("Expected healthy status, got %v", body["status"])
+			}
+			breaker, _ := body["circuit_breaker"].(map[string]any)
+			if tt.expectedBreaker == nil {
+				if breaker != nil {
+					t.Errorf("Expected no circuit_breaker, got %v", breaker)
+				}
+				return
+			}
+			if !reflect.DeepEqual(breaker, tt.expectedBreaker) {
+				t.Errorf("Expected circuit_breaker %v, got %v", tt.expectedBreaker, breaker)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+// defaultHealthCheckTimeout bounds the Stytch call made by a deep health
+// check, so a hung upstream fails the probe instead of the probe timing out.
+const defaultHealthCheckTimeout = 3 * time.Second
+
+// stytchHealth is the Stytch section of a deep health check.
+type stytchHealth struct {
+	Reachable bool  `json:"reachable"`
+	LatencyMS int64 `json:"latency_ms"`
+	// CredentialsValid is omitted when Stytch could not be reached, since
+	// nothing is known about the credentials then.
+	CredentialsValid *bool  `json:"credentials_valid,omitempty"`
+	PolicyHash       string `json:"policy_hash,omitempty"`
+	ErrorType        string `json:"error_type,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// isShallowHealthCheck reports whether request is a shallow health check on
+// one of the unprefixed health paths. A deep parameter that does not parse is
+// left to handleHealthCheck to reject.
+func isShallowHealthCheck(request events.ALBTargetGroupRequest) bool {
+	if request.HTTPMethod != http.MethodGet || (request.Path != "/health" && request.Path != "/rbacpolicy/health") {
+		return false
+	}
+	deep, err := parseBoolParam(queryParam(request, "deep"))
+	return err != nil || !deep
+}
+
+// handleHealthCheck answers the shallow health check used by the ALB target
+// group, which touches nothing outside the container. With ?deep=true it
+// becomes a deep health check.
+func (h *Handler) handleHealthCheck(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	deep, err := parseBoolParam(queryParam(request, "deep"))
+	if err != nil {
+		return h.errorResponse(http.StatusBadRequest, "Invalid deep parameter")
+	}
+	if deep {
+		return h.handleDeepHealthCheck(ctx)
+	}
+
+	healthResponse := map[string]any{
+		"status": "healthy",
+	}
+	if h.breaker != nil {
+		healthResponse["circuit_breaker"] = h.breaker.Status()
+	}
+
+	return h.healthResponse(http.StatusOK, healthResponse), nil
+}
+
+// handleDeepHealthCheck reads the project's policy from Stytch, bypassing the
+// cache, and reports how long it took, whether the credentials were accepted
+// and the policy's hash. It is 503 unless the read succeeded.
+func (h *Handler) handleDeepHealthCheck(ctx context.Context) (events.ALBTargetGroupResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, err := h.client.Get(ctx, rbacpolicy.GetRequest{ProjectID: h.project(ctx)})
+	stytch := h.stytchHealth(resp, err)
+	stytch.LatencyMS = time.Since(start).Milliseconds()
+
+	healthResponse := map[string]any{
+		"status":     "healthy",
+		"project_id": h.project(ctx),
+		"stytch":     stytch,
+	}
+	if h.breaker != nil {
+		healthResponse["circuit_breaker"] = h.breaker.Status()
+	}
+
+	statusCode := http.StatusOK
+	if err != nil {
+		h.logger.Warn("Deep health check failed",
+			zap.Error(err),
+			zap.Bool("reachable", stytch.Reachable),
+			zap.Int64("latency_ms", stytch.LatencyMS),
+		)
+		healthResponse["status"] = "unhealthy"
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	return h.healthResponse(statusCode, healthResponse), nil
+}
+
+// stytchHealth interprets the outcome of the deep health check's Get. Any
+// Stytch API error means Stytch was reached; 401 and 403 mean it rejected the
+// workspace key. Network errors, timeouts and an open circuit breaker mean it
+// was not reached.
+func (h *Handler) stytchHealth(resp *rbacpolicy.GetResponse, err error) stytchHealth {
+	if err == nil {
+		valid := true
+		return stytchHealth{
+			Reachable:        true,
+			CredentialsValid: &valid,
+			PolicyHash:       rbac.Hash(resp.Policy),
+		}
+	}
+
+	if stytchErr, ok := asStytchError(err); ok {
+		valid := stytchErr.StatusCode != http.StatusUnauthorized && stytchErr.StatusCode != http.StatusForbidden
+		return stytchHealth{
+			Reachable:        true,
+			CredentialsValid: &valid,
+			ErrorType:        string(stytchErr.ErrorType),
+			Error:            string(stytchErr.ErrorMessage),
+		}
+	}
+
+	health := stytchHealth{Error: "Stytch is unreachable"}
+	var openErr *stytchclient.OpenError
+	switch {
+	case errors.As(err, &openErr):
+		health.Error = "Circuit breaker is open"
+	case errors.Is(err, context.DeadlineExceeded):
+		health.Error = "Timed out after " + h.healthCheckTimeout.String()
+	case !h.hideInternalErrors:
+		health.Error = err.Error()
+	}
+	return health
+}
+
+func (h *Handler) healthResponse(statusCode int, v any) events.ALBTargetGroupResponse {
+	body, _ := json.Marshal(v)
+
+	return events.ALBTargetGroupResponse{
+		StatusCode:        statusCode,
+		StatusDescription: http.StatusText(statusCode),
+		Headers: map[string]string{
+			"Content-Type":  "application/json",
+			"Cache-Control": "no-store",
+		},
+		Body:            string(body),
+		IsBase64Encoded: false,
+	}
+}
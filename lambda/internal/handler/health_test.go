@@ -0,0 +1,287 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/authz"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+	"go.uber.org/zap"
+)
+
+func TestHandleDeepHealthCheck(t *testing.T) {
+	policy := testRolePolicy()
+
+	tests := []struct {
+		name               string
+		request            events.ALBTargetGroupRequest
+		getErr             error
+		hideInternalErrors bool
+		expectedStatus     int
+		expectedReachable  bool
+		// expectedCredentials is nil when credentials_valid should be absent.
+		expectedCredentials *bool
+		expectedHash        string
+		expectedError       string
+	}{
+		{
+			name:                "Deep health check",
+			request:             events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/health", QueryStringParameters: map[string]string{"deep": "true"}},
+			expectedStatus:      http.StatusOK,
+			expectedReachable:   true,
+			expectedCredentials: boolPtr(true),
+			expectedHash:        rbac.Hash(policy),
+		},
+		{
+			name:                "Ready route",
+			request:             events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/ready"},
+			expectedStatus:      http.StatusOK,
+			expectedReachable:   true,
+			expectedCredentials: boolPtr(true),
+			expectedHash:        rbac.Hash(policy),
+		},
+		{
+			name:                "Invalid credentials",
+			request:             events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/ready"},
+			getErr:              stytcherror.Error{StatusCode: 401, ErrorType: "unauthorized_credentials", ErrorMessage: "Unauthorized credentials."},
+			expectedStatus:      http.StatusServiceUnavailable,
+			expectedReachable:   true,
+			expectedCredentials: boolPtr(false),
+			expectedError:       "Unauthorized credentials.",
+		},
+		{
+			name:                "Stytch server error",
+			request:             events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/ready"},
+			getErr:              stytcherror.Error{StatusCode: 500, ErrorType: "internal_server_error", ErrorMessage: "Oops."},
+			expectedStatus:      http.StatusServiceUnavailable,
+			expectedReachable:   true,
+			expectedCredentials: boolPtr(true),
+			expectedError:       "Oops.",
+		},
+		{
+			name:           "Network error",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/ready"},
+			getErr:         errors.New("dial tcp: connection refused"),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  "dial tcp: connection refused",
+		},
+		{
+			name:               "Network error hidden in production",
+			request:            events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/ready"},
+			getErr:             errors.New("dial tcp: connection refused"),
+			hideInternalErrors: true,
+			expectedStatus:     http.StatusServiceUnavailable,
+			expectedError:      "Stytch is unreachable",
+		},
+		{
+			name:           "Circuit breaker open",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/ready"},
+			getErr:         &stytchclient.OpenError{RetryAfter: time.Second},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedError:  "Circuit breaker is open",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockRBACPolicyClient{
+				getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+					if body.ProjectID != "test-project-id" {
+						t.Errorf("Expected project test-project-id, got %q", body.ProjectID)
+					}
+					if tt.getErr != nil {
+						return nil, tt.getErr
+					}
+					return &rbacpolicy.GetResponse{StatusCode: 200, Policy: policy}, nil
+				},
+			}
+			var opts []Option
+			if tt.hideInternalErrors {
+				opts = append(opts, WithHideInternalErrors())
+			}
+			opts = append(opts, WithAuthenticator(groupAuthenticator{}))
+			h := NewHandler(client, "test-project-id", zap.NewNop(), opts...)
+
+			request := tt.request
+			request.Headers = map[string]string{"X-Amzn-Oidc-Data": "deploy"}
+			response, err := h.HandleRequest(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+
+			var body struct {
+				Status    string       `json:"status"`
+				ProjectID string       `json:"project_id"`
+				Stytch    stytchHealth `json:"stytch"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			expectedStatus := "healthy"
+			if tt.expectedStatus != http.StatusOK {
+				expectedStatus = "unhealthy"
+			}
+			if body.Status != expectedStatus {
+				t.Errorf("Expected status %q, got %q", expectedStatus, body.Status)
+			}
+			if body.ProjectID != "test-project-id" {
+				t.Errorf("Expected project_id test-project-id, got %q", body.ProjectID)
+			}
+			if body.Stytch.Reachable != tt.expectedReachable {
+				t.Errorf("Expected reachable %v, got %v", tt.expectedReachable, body.Stytch.Reachable)
+			}
+			switch {
+			case tt.expectedCredentials == nil && body.Stytch.CredentialsValid != nil:
+				t.Errorf("Expected no credentials_valid, got %v", *body.Stytch.CredentialsValid)
+			case tt.expectedCredentials != nil && (body.Stytch.CredentialsValid == nil || *body.Stytch.CredentialsValid != *tt.expectedCredentials):
+				t.Errorf("Expected credentials_valid %v, got %v", *tt.expectedCredentials, body.Stytch.CredentialsValid)
+			}
+			if body.Stytch.PolicyHash != tt.expectedHash {
+				t.Errorf("Expected policy_hash %q, got %q", tt.expectedHash, body.Stytch.PolicyHash)
+			}
+			if body.Stytch.Error != tt.expectedError {
+				t.Errorf("Expected error %q, got %q", tt.expectedError, body.Stytch.Error)
+			}
+			if response.Headers["Cache-Control"] != "no-store" {
+				t.Errorf("Expected Cache-Control no-store, got %q", response.Headers["Cache-Control"])
+			}
+		})
+	}
+}
+
+func TestDeepHealthCheckTimeout(t *testing.T) {
+	client := &mockRBACPolicyClient{
+		getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	h := NewHandler(client, "test-project-id", zap.NewNop())
+	h.healthCheckTimeout = 10 * time.Millisecond
+
+	response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodGet,
+		Path:       "/ready",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, response.StatusCode)
+	}
+
+	var body struct {
+		Stytch stytchHealth `json:"stytch"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response body: %v", err)
+	}
+	if body.Stytch.Error != "Timed out after 10ms" {
+		t.Errorf("Expected timeout error, got %q", body.Stytch.Error)
+	}
+	if body.Stytch.LatencyMS < 10 {
+		t.Errorf("Expected latency of at least 10ms, got %d", body.Stytch.LatencyMS)
+	}
+}
+
+func TestShallowHealthCheck(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          map[string]string
+		expectedStatus int
+	}{
+		{name: "Shallow by default", expectedStatus: http.StatusOK},
+		{name: "Explicitly shallow", query: map[string]string{"deep": "false"}, expectedStatus: http.StatusOK},
+		{name: "Invalid deep parameter", query: map[string]string{"deep": "maybe"}, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The shallow check must not touch Stytch, so Get is left
+			// unimplemented. Probes carry no token, which groupAuthenticator
+			// would reject.
+			h := NewHandler(&mockRBACPolicyClient{}, "test-project-id", zap.NewNop(), WithAuthenticator(groupAuthenticator{}))
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod:            http.MethodGet,
+				Path:                  "/rbacpolicy/health",
+				QueryStringParameters: tt.query,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+		})
+	}
+}
+
+func TestDeepHealthCheckAuthentication(t *testing.T) {
+	authorizer, err := authz.New(authz.Config{Rules: []authz.Rule{
+		{Groups: []string{"reader"}, Capabilities: []authz.Capability{authz.ReadPolicy}},
+	}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		query          map[string]string
+		token          string
+		expectedStatus int
+	}{
+		{name: "Ready without token", path: "/ready", expectedStatus: http.StatusUnauthorized},
+		{name: "Prefixed ready without token", path: "/rbacpolicy/ready", expectedStatus: http.StatusUnauthorized},
+		{name: "Deep parameter without token", path: "/rbacpolicy/health", query: map[string]string{"deep": "true"}, expectedStatus: http.StatusUnauthorized},
+		{name: "Ready without read_policy", path: "/ready", token: "nobody", expectedStatus: http.StatusForbidden},
+		{name: "Ready with read_policy", path: "/ready", token: "reader", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			client := &mockRBACPolicyClient{
+				getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+					calls++
+					return &rbacpolicy.GetResponse{StatusCode: 200}, nil
+				},
+			}
+			h := NewHandler(client, "test-project-id", zap.NewNop(),
+				WithAuthenticator(groupAuthenticator{}),
+				WithAuthorizer(authorizer),
+			)
+
+			request := events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: tt.path, QueryStringParameters: tt.query}
+			if tt.token != "" {
+				request.Headers = map[string]string{"X-Amzn-Oidc-Data": tt.token}
+			}
+			response, err := h.HandleRequest(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			if tt.expectedStatus != http.StatusOK && calls != 0 {
+				t.Errorf("Expected no Stytch call for a rejected probe, got %d", calls)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) handleHistory(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	if request.HTTPMethod != http.MethodGet {
+		return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+
+	snapshots, err := h.history.List(ctx, h.project(ctx))
+	if err != nil {
+		h.logger.Error("Failed to list policy snapshots", zap.Error(err))
+		return h.statusErrorResponse(h.internalError(err, "Failed to list policy snapshots"))
+	}
+
+	return h.jsonResponse(http.StatusOK, map[string]any{"snapshots": snapshots})
+}
+
+func (h *Handler) handleSnapshot(ctx context.Context, request events.ALBTargetGroupRequest, version, sub string) (events.ALBTargetGroupResponse, error) {
+	switch sub {
+	case "":
+		if request.HTTPMethod != http.MethodGet {
+			return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		snapshot, err := h.getSnapshot(ctx, version)
+		if err != nil {
+			return h.statusErrorResponse(err)
+		}
+		return h.jsonResponse(http.StatusOK, snapshot)
+	case "restore":
+		if request.HTTPMethod != http.MethodPost {
+			return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return h.handleRestore(ctx, request, version)
+	default:
+		return h.errorResponse(http.StatusNotFound, "Not found")
+	}
+}
+
+// handleRestore writes a snapshot back as the current policy. The policy being
+// replaced is itself snapshotted, so a restore can be undone.
+func (h *Handler) handleRestore(ctx context.Context, request events.ALBTargetGroupRequest, version string) (events.ALBTargetGroupResponse, error) {
+	snapshot, err := h.getSnapshot(ctx, version)
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+
+	write, err := h.updatePolicy(ctx, request, func(p *rbacpolicy.Policy) error {
+		p.StytchMember = snapshot.Policy.StytchMember
+		p.StytchAdmin = snapshot.Policy.StytchAdmin
+		p.CustomRoles = snapshot.Policy.CustomRoles
+		p.CustomResources = snapshot.Policy.CustomResources
+		return nil
+	})
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+	if write.dryRun {
+		return h.dryRunResponse(write)
+	}
+
+	h.logger.Info("Restored policy snapshot", zap.String("version", version))
+
+	return h.policyResponse(http.StatusOK, write.after, write.after)
+}
+
+func (h *Handler) getSnapshot(ctx context.Context, version string) (*history.Snapshot, error) {
+	snapshot, err := h.history.Get(ctx, h.project(ctx), version)
+	if errors.Is(err, history.ErrNotFound) {
+		return nil, &statusError{
+			statusCode: http.StatusNotFound,
+			message:    fmt.Sprintf("Snapshot %q not found", version),
+		}
+	}
+	if err != nil {
+		h.logger.Error("Failed to get policy snapshot", zap.Error(err))
+		return nil, h.internalError(err, "Failed to get policy snapshot")
+	}
+	return snapshot, nil
+}
+
+// saveSnapshot records the policy about to be overwritten by request.
+func (h *Handler) saveSnapshot(ctx context.Context, request events.ALBTargetGroupRequest, policy rbacpolicy.Policy) error {
+	snapshot, err := h.history.Save(ctx, history.Snapshot{
+		ProjectID: h.project(ctx),
+		Reason:    request.HTTPMethod + " " + requestPath(ctx, request),
+		Hash:      rbac.Hash(policy),
+		Policy:    policy,
+	})
+	if err != nil {
+		h.logger.Error("Failed to save policy snapshot", zap.Error(err))
+		return h.internalError(err, "Failed to save policy snapshot")
+	}
+
+	h.logger.Info("Saved policy snapshot", zap.String("version", snapshot.Version))
+	return nil
+}
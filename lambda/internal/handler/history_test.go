@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"go.uber.org/zap"
+)
+
+// failingStore is a history.Store whose every call fails.
+type failingStore struct{}
+
+func (failingStore) Save(ctx context.Context, snapshot history.Snapshot) (history.Snapshot, error) {
+	return history.Snapshot{}, errors.New("store unavailable")
+}
+
+func (failingStore) List(ctx context.Context, projectID string) ([]history.Snapshot, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func (failingStore) Get(ctx context.Context, projectID, version string) (*history.Snapshot, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func TestWriteSavesSnapshot(t *testing.T) {
+	policy := testRolePolicy()
+	original := rbac.Clone(policy)
+	store := history.NewMemoryStore()
+	h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop(), WithHistoryStore(store))
+
+	response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodDelete,
+		Path:       "/rbacpolicy/roles/viewer",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, response.StatusCode)
+	}
+
+	snapshots, err := store.List(context.Background(), "test-project-id")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("Expected one snapshot, got %d", len(snapshots))
+	}
+	if snapshots[0].Reason != "DELETE /rbacpolicy/roles/viewer" {
+		t.Errorf("Unexpected reason %q", snapshots[0].Reason)
+	}
+	if snapshots[0].Hash != rbac.Hash(original) {
+		t.Errorf("Expected hash of the previous policy, got %q", snapshots[0].Hash)
+	}
+
+	snapshot, err := store.Get(context.Background(), "test-project-id", snapshots[0].Version)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(snapshot.Policy, original) {
+		t.Errorf("Expected snapshot of the previous policy, got %+v", snapshot.Policy)
+	}
+}
+
+func TestWriteWithoutSnapshot(t *testing.T) {
+	tests := []struct {
+		name           string
+		store          history.Store
+		query          map[string]string
+		expectedStatus int
+	}{
+		{
+			name:           "Dry run is not snapshotted",
+			store:          history.NewMemoryStore(),
+			query:          map[string]string{"dry_run": "true"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Snapshot failure aborts write",
+			store:          failingStore{},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			original := rbac.Clone(policy)
+			h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop(), WithHistoryStore(tt.store))
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod:            http.MethodDelete,
+				Path:                  "/rbacpolicy/roles/viewer",
+				QueryStringParameters: tt.query,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+			if !reflect.DeepEqual(policy, original) {
+				t.Errorf("Expected stored policy to be unchanged, got %+v", policy)
+			}
+			if store, ok := tt.store.(*history.MemoryStore); ok {
+				snapshots, _ := store.List(context.Background(), "test-project-id")
+				if len(snapshots) != 0 {
+					t.Errorf("Expected no snapshots, got %d", len(snapshots))
+				}
+			}
+		})
+	}
+}
+
+func TestHandleHistory(t *testing.T) {
+	policy := testRolePolicy()
+	store := history.NewMemoryStore()
+	h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop(), WithHistoryStore(store))
+
+	for _, path := range []string{"/rbacpolicy/roles/viewer", "/rbacpolicy/roles/editor"} {
+		if _, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+			HTTPMethod: http.MethodDelete,
+			Path:       path,
+		}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodGet,
+		Path:       "/rbacpolicy/history",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, response.StatusCode)
+	}
+
+	var body struct {
+		Snapshots []history.Snapshot `json:"snapshots"`
+	}
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		t.Fatalf("Failed to unmarshal response body: %v", err)
+	}
+	if len(body.Snapshots) != 2 {
+		t.Fatalf("Expected two snapshots, got %d", len(body.Snapshots))
+	}
+	if body.Snapshots[0].Reason != "DELETE /rbacpolicy/roles/editor" {
+		t.Errorf("Expected newest snapshot first, got %q", body.Snapshots[0].Reason)
+	}
+	if len(body.Snapshots[0].Policy.CustomRoles) != 0 {
+		t.Errorf("Expected listing without policies, got %+v", body.Snapshots[0].Policy)
+	}
+}
+
+func TestHandleSnapshot(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		store          history.Store
+		expectedStatus int
+	}{
+		{
+			name:           "Existing snapshot",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/history/v1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Unknown snapshot",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/history/v2",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Unknown sub-resource",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/history/v1/other",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Unsupported method",
+			method:         http.MethodDelete,
+			path:           "/rbacpolicy/history/v1",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "Unsupported list method",
+			method:         http.MethodPost,
+			path:           "/rbacpolicy/history",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "Restore requires POST",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/history/v1/restore",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:           "Store error",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/history/v1",
+			store:          failingStore{},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "Store error on list",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/history",
+			store:          failingStore{},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := tt.store
+			if store == nil {
+				memory := history.NewMemoryStore()
+				if _, err := memory.Save(context.Background(), history.Snapshot{
+					Version:   "v1",
+					ProjectID: "test-project-id",
+					Policy:    testRolePolicy(),
+				}); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				store = memory
+			}
+			policy := testRolePolicy()
+			h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop(), WithHistoryStore(store))
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: tt.method,
+				Path:       tt.path,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+		})
+	}
+}
+
+func TestHandleRestore(t *testing.T) {
+	policy := testRolePolicy()
+	original := rbac.Clone(policy)
+	store := history.NewMemoryStore()
+	h := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop(), WithHistoryStore(store))
+
+	if _, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodDelete,
+		Path:       "/rbacpolicy",
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(policy.CustomRoles) != 0 {
+		t.Fatalf("Expected custom roles to be cleared, got %+v", policy.CustomRoles)
+	}
+
+	snapshots, _ := store.List(context.Background(), "test-project-id")
+	if len(snapshots) != 1 {
+		t.Fatalf("Expected one snapshot, got %d", len(snapshots))
+	}
+
+	response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodPost,
+		Path:       "/rbacpolicy/history/" + snapshots[0].Version + "/restore",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, response.StatusCode, response.Body)
+	}
+	if !reflect.DeepEqual(policy, original) {
+		t.Errorf("Expected original policy to be restored, got %+v", policy)
+	}
+	if response.Headers["ETag"] != policyETag(original) {
+		t.Errorf("Expected ETag of the restored policy, got %q", response.Headers["ETag"])
+	}
+
+	// The restore is itself undoable.
+	snapshots, _ = store.List(context.Background(), "test-project-id")
+	if len(snapshots) != 2 {
+		t.Errorf("Expected restore to be snapshotted, got %d snapshots", len(snapshots))
+	}
+
+	response, err = h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodPost,
+		Path:       "/rbacpolicy/history/missing/restore",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, response.StatusCode)
+	}
+}
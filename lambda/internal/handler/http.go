@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.uber.org/zap"
+)
+
+// maxRequestBodySize matches the largest body an ALB passes to a Lambda target.
+const maxRequestBodySize = 1 << 20
+
+// ServeHTTP serves the same routes as HandleRequest over net/http, for running
+// the API locally. The request is converted to the ALB request HandleRequest
+// expects, with the client's address appended to X-Forwarded-For as an ALB
+// would.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request, err := albRequest(w, r)
+	if err != nil {
+		statusCode, message := http.StatusBadRequest, "Failed to read request body"
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			statusCode, message = http.StatusRequestEntityTooLarge, "Request body too large"
+		} else {
+			h.logger.Error("Failed to read request body", zap.Error(err))
+		}
+		response, _ := h.errorResponse(statusCode, message)
+		h.writeResponse(w, response)
+		return
+	}
+
+	response, err := h.HandleRequest(r.Context(), request)
+	if err != nil {
+		h.logger.Error("Request failed", zap.Error(err))
+		response, _ = h.errorResponse(http.StatusInternalServerError, "Internal server error")
+	}
+	h.writeResponse(w, response)
+}
+
+// albRequest converts r to the ALB request model. ALB passes the path and
+// query string through undecoded, so both are kept escaped.
+func albRequest(w http.ResponseWriter, r *http.Request) (events.ALBTargetGroupRequest, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		return events.ALBTargetGroupRequest{}, err
+	}
+
+	headers := r.Header.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	if r.Host != "" {
+		headers.Set("Host", r.Host)
+	}
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if forwarded := headers.Get("X-Forwarded-For"); forwarded != "" {
+			ip = forwarded + ", " + ip
+		}
+		headers.Set("X-Forwarded-For", ip)
+	}
+
+	query := make(map[string][]string)
+	for name, values := range r.URL.Query() {
+		for _, value := range values {
+			query[name] = append(query[name], url.QueryEscape(value))
+		}
+	}
+
+	return events.ALBTargetGroupRequest{
+		HTTPMethod:                      r.Method,
+		Path:                            r.URL.EscapedPath(),
+		MultiValueHeaders:               headers,
+		MultiValueQueryStringParameters: query,
+		Body:                            string(body),
+	}, nil
+}
+
+func (h *Handler) writeResponse(w http.ResponseWriter, response events.ALBTargetGroupResponse) {
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
+	for name, values := range response.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(response.Body)
+		if err != nil {
+			h.logger.Error("Failed to decode response body", zap.Error(err))
+		}
+		body = decoded
+	}
+
+	w.WriteHeader(response.StatusCode)
+	if _, err := w.Write(body); err != nil {
+		h.logger.Debug("Failed to write response", zap.Error(err))
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func TestServeHTTP(t *testing.T) {
+	policy := &rbacpolicy.Policy{}
+	sink := &recordingSink{}
+	h := NewHandler(newStatefulMock(policy), "project-default", zap.NewNop(), WithAuditSink(sink))
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		expectedRoles  int
+	}{
+		{name: "Health check", method: http.MethodGet, path: "/health", expectedStatus: http.StatusOK},
+		{name: "Get policy", method: http.MethodGet, path: "/rbacpolicy", expectedStatus: http.StatusOK},
+		{name: "Dry run from query string", method: http.MethodPut, path: "/rbacpolicy/roles/editor?dry_run=true", body: `{"description": "Editors"}`, expectedStatus: http.StatusOK},
+		{name: "Create role", method: http.MethodPut, path: "/rbacpolicy/roles/editor", body: `{"description": "Editors"}`, expectedStatus: http.StatusCreated, expectedRoles: 1},
+		{name: "Escaped path segment", method: http.MethodGet, path: "/rbacpolicy/roles/%65ditor", expectedStatus: http.StatusOK, expectedRoles: 1},
+		{name: "Body too large", method: http.MethodPut, path: "/rbacpolicy", body: strings.Repeat(" ", maxRequestBodySize+1), expectedStatus: http.StatusRequestEntityTooLarge, expectedRoles: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Failed to build request: %v", err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, resp.StatusCode, body)
+			}
+			if got := resp.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Expected JSON content type, got %q", got)
+			}
+			if !json.Valid(body) {
+				t.Errorf("Expected a JSON body, got %q", body)
+			}
+			if len(policy.CustomRoles) != tt.expectedRoles {
+				t.Errorf("Expected %d custom roles, got %d", tt.expectedRoles, len(policy.CustomRoles))
+			}
+		})
+	}
+
+	if len(sink.records) != 1 {
+		t.Fatalf("Expected 1 audit record, got %d", len(sink.records))
+	}
+	if got := sink.records[0].SourceIP; got != "127.0.0.1" {
+		t.Errorf("Expected the client address as source IP, got %q", got)
+	}
+	if got := sink.records[0].Path; got != "/rbacpolicy/roles/editor" {
+		t.Errorf("Expected audit path /rbacpolicy/roles/editor, got %q", got)
+	}
+}
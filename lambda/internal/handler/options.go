@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/audit"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/desired"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+)
+
+// Option configures optional Handler behaviour.
+type Option func(*Handler)
+
+// WithHistoryStore sets where policy snapshots are kept. Without it snapshots
+// are held in memory for the life of the container.
+func WithHistoryStore(store history.Store) Option {
+	return func(h *Handler) {
+		h.history = store
+	}
+}
+
+// WithAuthenticator requires every request other than the health check to
+// carry a valid ALB OIDC token. The caller's identity is then available via
+// auth.IdentityFromContext.
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(h *Handler) {
+		h.authenticator = authenticator
+	}
+}
+
+// WithAuthorizer restricts callers to the capabilities the authorizer grants
+// them. It needs WithAuthenticator; without an identity nothing is allowed.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(h *Handler) {
+		h.authorizer = authorizer
+	}
+}
+
+// WithAuditSink sets where audit records of policy writes are sent. Without
+// it they are written to the handler's logger.
+func WithAuditSink(sink audit.Sink) Option {
+	return func(h *Handler) {
+		h.audit = sink
+	}
+}
+
+// WithHideInternalErrors leaves the text of unexpected errors out of
+// responses. They are still logged.
+func WithHideInternalErrors() Option {
+	return func(h *Handler) {
+		h.hideInternalErrors = true
+	}
+}
+
+// WithCircuitBreaker reports the breaker's state in the health check. The
+// breaker itself belongs in the client passed to NewHandler.
+func WithCircuitBreaker(breaker CircuitBreaker) Option {
+	return func(h *Handler) {
+		h.breaker = breaker
+	}
+}
+
+// WithProjects makes more projects reachable under
+// /rbacpolicy/projects/{project_id}/. projects maps each accepted name, a
+// project ID or an alias such as "live", to its project ID. The default
+// project passed to NewHandler is always reachable by its ID.
+func WithProjects(projects map[string]string) Option {
+	return func(h *Handler) {
+		h.projects = projects
+	}
+}
+
+// WithDesiredPolicySource enables drift detection against the policies
+// declared by source, on GET/POST /rbacpolicy/drift and in scheduled events.
+func WithDesiredPolicySource(source desired.Source) Option {
+	return func(h *Handler) {
+		h.desired = source
+	}
+}
+
+// WithDriftAutoReconcile makes scheduled drift checks write the desired
+// policy when they find drift, rather than only reporting it.
+func WithDriftAutoReconcile() Option {
+	return func(h *Handler) {
+		h.driftAutoReconcile = true
+	}
+}
+
+// WithCacheTTL serves reads from memory for up to ttl after the policy was
+// fetched, so polling callers on a warm container do not each cost a Stytch
+// call. Writes through this handler invalidate the cache. Zero disables it.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(h *Handler) {
+		h.cache = newPolicyCache(ttl)
+	}
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+// patchFunc applies a patch document to the JSON encoding of a policy.
+type patchFunc func(doc []byte) ([]byte, error)
+
+func (h *Handler) handlePatch(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	apply, err := h.decodePatch(request)
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+
+	write, err := h.updatePolicy(ctx, request, func(p *rbacpolicy.Policy) error {
+		current, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+
+		patched, err := apply(current)
+		if err != nil {
+			h.logger.Info("Failed to apply patch", zap.Error(err))
+			statusCode := http.StatusUnprocessableEntity
+			if errors.Is(err, jsonpatch.ErrTestFailed) {
+				statusCode = http.StatusConflict
+			}
+			return &statusError{
+				statusCode: statusCode,
+				message:    fmt.Sprintf("Failed to apply patch: %v", err),
+			}
+		}
+
+		var updated rbacpolicy.Policy
+		decoder := json.NewDecoder(bytes.NewReader(patched))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&updated); err != nil {
+			return &statusError{
+				statusCode: http.StatusUnprocessableEntity,
+				message:    fmt.Sprintf("Patched document is not a valid RBAC policy: %v", err),
+			}
+		}
+
+		*p = updated
+		return nil
+	})
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+	if write.dryRun {
+		return h.dryRunResponse(write)
+	}
+
+	return h.policyResponse(http.StatusOK, write.after, write.after)
+}
+
+// decodePatch selects the patch format from the Content-Type header and
+// parses the body up front, so malformed patches fail before any Stytch call.
+func (h *Handler) decodePatch(request events.ALBTargetGroupRequest) (patchFunc, error) {
+	mediaType, _, _ := mime.ParseMediaType(header(request, "Content-Type"))
+	body := []byte(request.Body)
+
+	switch mediaType {
+	case mergePatchContentType:
+		if !json.Valid(body) {
+			return nil, &statusError{
+				statusCode: http.StatusBadRequest,
+				message:    "Invalid merge patch: body is not valid JSON",
+			}
+		}
+		return func(doc []byte) ([]byte, error) {
+			return jsonpatch.MergePatch(doc, body)
+		}, nil
+	case jsonPatchContentType:
+		patch, err := jsonpatch.DecodePatch(body)
+		if err != nil {
+			h.logger.Error("Failed to decode JSON patch", zap.Error(err))
+			return nil, &statusError{
+				statusCode: http.StatusBadRequest,
+				message:    fmt.Sprintf("Invalid JSON patch: %v", err),
+			}
+		}
+		return patch.Apply, nil
+	default:
+		return nil, &statusError{
+			statusCode: http.StatusUnsupportedMediaType,
+			message:    fmt.Sprintf("Content-Type must be %s or %s", mergePatchContentType, jsonPatchContentType),
+			headers:    map[string]string{"Accept-Patch": mergePatchContentType + ", " + jsonPatchContentType},
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func TestHandlePatch(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		check          func(t *testing.T, policy rbacpolicy.Policy)
+	}{
+		{
+			name:           "JSON patch adds one action",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op":"add","path":"/custom_roles/0/permissions/0/actions/-","value":"delete"}]`,
+			expectedStatus: http.StatusOK,
+			check: func(t *testing.T, policy rbacpolicy.Policy) {
+				if got := policy.CustomRoles[0].Permissions[0].Actions; !slices.Equal(got, []string{"read", "write", "delete"}) {
+					t.Errorf("Expected delete action to be appended, got %v", got)
+				}
+				if len(policy.CustomRoles) != 2 || len(policy.CustomResources) != 1 {
+					t.Errorf("Expected rest of policy to be preserved")
+				}
+			},
+		},
+		{
+			name:           "Merge patch with charset parameter",
+			contentType:    "application/merge-patch+json; charset=utf-8",
+			body:           `{"stytch_member":{"role_id":"stytch_member","description":"Everyone"}}`,
+			expectedStatus: http.StatusOK,
+			check: func(t *testing.T, policy rbacpolicy.Policy) {
+				if policy.StytchMember.Description != "Everyone" {
+					t.Errorf("Expected stytch_member description to be patched, got %q", policy.StytchMember.Description)
+				}
+				if len(policy.CustomRoles) != 2 {
+					t.Errorf("Expected custom roles to be preserved, got %d", len(policy.CustomRoles))
+				}
+			},
+		},
+		{
+			name:           "Failed test operation",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op":"test","path":"/custom_roles/0/role_id","value":"viewer"},{"op":"remove","path":"/custom_roles/0"}]`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Path does not exist",
+			contentType:    "application/json-patch+json",
+			body:           `[{"op":"replace","path":"/custom_roles/9/description","value":"x"}]`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Patch introduces unknown field",
+			contentType:    "application/merge-patch+json",
+			body:           `{"custom_role":[]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Malformed JSON patch",
+			contentType:    "application/json-patch+json",
+			body:           `{"op":"add"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Malformed merge patch",
+			contentType:    "application/merge-patch+json",
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unsupported content type",
+			contentType:    "application/json",
+			body:           `{}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			original := policyETag(policy)
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodPatch,
+				Path:       "/rbacpolicy",
+				Headers:    map[string]string{"content-type": tt.contentType},
+				Body:       tt.body,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+
+			if tt.check == nil {
+				if policyETag(policy) != original {
+					t.Errorf("Expected policy to be unchanged after failed patch")
+				}
+				return
+			}
+
+			var returned rbacpolicy.Policy
+			if err := json.Unmarshal([]byte(response.Body), &returned); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if response.Headers["ETag"] != policyETag(returned) {
+				t.Errorf("Expected ETag of patched policy, got %s", response.Headers["ETag"])
+			}
+			tt.check(t, policy)
+		})
+	}
+}
+
+func TestHandlePatchIfMatch(t *testing.T) {
+	policy := testRolePolicy()
+	handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+	response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodPatch,
+		Path:       "/rbacpolicy",
+		Headers: map[string]string{
+			"content-type": "application/merge-patch+json",
+			"if-match":     `"stale"`,
+		},
+		Body: `{"custom_resources":[]}`,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected status code %d, got %d", http.StatusPreconditionFailed, response.StatusCode)
+	}
+	if len(policy.CustomResources) != 1 {
+		t.Errorf("Expected policy to be unchanged")
+	}
+}
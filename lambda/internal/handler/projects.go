@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	projectsPath       = "/rbacpolicy/projects"
+	projectsPathPrefix = "/rbacpolicy/projects/"
+)
+
+type projectRouteKey struct{}
+
+// projectRoute records which project a request addressed and the path it was
+// sent to, before the /rbacpolicy/projects/{project_id} prefix was removed.
+type projectRoute struct {
+	projectID string
+	path      string
+}
+
+// routeProject resolves the project a request addresses. Paths under
+// /rbacpolicy/projects/{project_id} name a configured project ID or alias and
+// are rewritten to the equivalent unprefixed path; any other path addresses
+// the default project. A project that is not configured is a 404
+// *statusError, returned with the request unchanged.
+func (h *Handler) routeProject(request events.ALBTargetGroupRequest) (events.ALBTargetGroupRequest, projectRoute, error) {
+	route := projectRoute{projectID: h.projectID, path: request.Path}
+	if request.Path != projectsPath && !strings.HasPrefix(request.Path, projectsPathPrefix) {
+		return request, route, nil
+	}
+
+	escaped, rest, _ := strings.Cut(strings.TrimPrefix(request.Path, projectsPathPrefix), "/")
+	if request.Path == projectsPath {
+		escaped = ""
+	}
+	name, err := url.PathUnescape(escaped)
+	if err != nil {
+		name = escaped
+	}
+	projectID, ok := h.lookupProject(name)
+	if err != nil || !ok {
+		return request, route, &statusError{
+			statusCode: http.StatusNotFound,
+			message:    fmt.Sprintf("Project %q not found", name),
+		}
+	}
+
+	route.projectID = projectID
+	request.Path = "/rbacpolicy"
+	if rest != "" {
+		request.Path += "/" + rest
+	}
+	return request, route, nil
+}
+
+// lookupProject returns the project ID for a configured project ID or alias.
+// The default project is always reachable by its ID.
+func (h *Handler) lookupProject(name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+	if name == h.projectID {
+		return h.projectID, true
+	}
+	projectID, ok := h.projects[name]
+	return projectID, ok
+}
+
+func withProjectRoute(ctx context.Context, route projectRoute) context.Context {
+	return context.WithValue(ctx, projectRouteKey{}, route)
+}
+
+// project returns the ID of the project the request in ctx addresses.
+func (h *Handler) project(ctx context.Context) string {
+	if route, ok := ctx.Value(projectRouteKey{}).(projectRoute); ok {
+		return route.projectID
+	}
+	return h.projectID
+}
+
+// requestPath returns the path the caller sent, including any project prefix,
+// for audit records and snapshot reasons.
+func requestPath(ctx context.Context, request events.ALBTargetGroupRequest) string {
+	if route, ok := ctx.Value(projectRouteKey{}).(projectRoute); ok {
+		return route.path
+	}
+	return request.Path
+}
@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+	"go.uber.org/zap"
+)
+
+// newProjectsMock serves and stores a separate policy per project ID.
+func newProjectsMock(policies map[string]*rbacpolicy.Policy) *mockRBACPolicyClient {
+	return &mockRBACPolicyClient{
+		getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+			policy, ok := policies[body.ProjectID]
+			if !ok {
+				return nil, stytcherror.Error{StatusCode: 404, ErrorMessage: "Project not found."}
+			}
+			return &rbacpolicy.GetResponse{StatusCode: 200, Policy: *policy}, nil
+		},
+		setFunc: func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+			policy, ok := policies[body.ProjectID]
+			if !ok {
+				return nil, stytcherror.Error{StatusCode: 404, ErrorMessage: "Project not found."}
+			}
+			*policy = body.Policy
+			return &rbacpolicy.SetResponse{StatusCode: 200, Policy: body.Policy}, nil
+		},
+	}
+}
+
+// projectPolicy is a policy whose only role is named after the project.
+func projectPolicy(projectID string) *rbacpolicy.Policy {
+	return &rbacpolicy.Policy{
+		CustomRoles: []rbacpolicy.Role{{RoleID: projectID + "_role", Description: "Role in " + projectID}},
+	}
+}
+
+var testProjects = map[string]string{
+	"live":           "project-live",
+	"project-live":   "project-live",
+	"test":           "project-test",
+	"project-test":   "project-test",
+	"project-tenant": "project-tenant",
+}
+
+func TestProjectRouting(t *testing.T) {
+	tests := []struct {
+		name              string
+		path              string
+		expectedStatus    int
+		expectedProjectID string
+	}{
+		{name: "Unprefixed route uses default project", path: "/rbacpolicy", expectedStatus: http.StatusOK, expectedProjectID: "project-default"},
+		{name: "Default project by ID", path: "/rbacpolicy/projects/project-default", expectedStatus: http.StatusOK, expectedProjectID: "project-default"},
+		{name: "Project by alias", path: "/rbacpolicy/projects/live", expectedStatus: http.StatusOK, expectedProjectID: "project-live"},
+		{name: "Project by ID", path: "/rbacpolicy/projects/project-test", expectedStatus: http.StatusOK, expectedProjectID: "project-test"},
+		{name: "Project without alias", path: "/rbacpolicy/projects/project-tenant", expectedStatus: http.StatusOK, expectedProjectID: "project-tenant"},
+		{name: "Escaped project name", path: "/rbacpolicy/projects/project%2Dlive", expectedStatus: http.StatusOK, expectedProjectID: "project-live"},
+		{name: "Nested route", path: "/rbacpolicy/projects/live/roles/project-live_role", expectedStatus: http.StatusOK, expectedProjectID: "project-live"},
+		{name: "Nested route in another project", path: "/rbacpolicy/projects/test/roles/project-live_role", expectedStatus: http.StatusNotFound},
+		{name: "Unknown project", path: "/rbacpolicy/projects/project-other/roles/x", expectedStatus: http.StatusNotFound},
+		{name: "Missing project", path: "/rbacpolicy/projects/", expectedStatus: http.StatusNotFound},
+		{name: "Projects root", path: "/rbacpolicy/projects", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := map[string]*rbacpolicy.Policy{
+				"project-default": projectPolicy("project-default"),
+				"project-live":    projectPolicy("project-live"),
+				"project-test":    projectPolicy("project-test"),
+				"project-tenant":  projectPolicy("project-tenant"),
+				"project-other":   projectPolicy("project-other"),
+			}
+			h := NewHandler(newProjectsMock(policies), "project-default", zap.NewNop(), WithProjects(testProjects))
+
+			response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       tt.path,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			if tt.expectedProjectID == "" {
+				return
+			}
+
+			var body struct {
+				RoleID      string            `json:"role_id"`
+				CustomRoles []rbacpolicy.Role `json:"custom_roles"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			roleID := body.RoleID
+			if len(body.CustomRoles) > 0 {
+				roleID = body.CustomRoles[0].RoleID
+			}
+			if want := tt.expectedProjectID + "_role"; roleID != want {
+				t.Errorf("Expected policy of %s, got role %q", tt.expectedProjectID, roleID)
+			}
+		})
+	}
+}
+
+func TestProjectWrite(t *testing.T) {
+	policies := map[string]*rbacpolicy.Policy{
+		"project-default": projectPolicy("project-default"),
+		"project-live":    projectPolicy("project-live"),
+	}
+	store := history.NewMemoryStore()
+	sink := &recordingSink{}
+	h := NewHandler(newProjectsMock(policies), "project-default", zap.NewNop(),
+		WithProjects(testProjects),
+		WithHistoryStore(store),
+		WithAuditSink(sink),
+		WithCacheTTL(time.Minute),
+	)
+	ctx := context.Background()
+
+	// Warm the cache for both projects so a stale entry would show up.
+	for _, path := range []string{"/rbacpolicy", "/rbacpolicy/projects/live"} {
+		if _, err := h.HandleRequest(ctx, events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: path}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	response, err := h.HandleRequest(ctx, events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodPut,
+		Path:       "/rbacpolicy/projects/live/roles/auditor",
+		Body:       `{"description": "Auditor role"}`,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, response.StatusCode, response.Body)
+	}
+
+	if findRole(policies["project-live"].CustomRoles, "auditor") < 0 {
+		t.Error("Expected role to be written to the live project")
+	}
+	if findRole(policies["project-default"].CustomRoles, "auditor") >= 0 {
+		t.Error("Expected default project to be untouched")
+	}
+
+	response, err = h.HandleRequest(ctx, events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/projects/live/roles/auditor"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected written role to be readable, got status %d", response.StatusCode)
+	}
+
+	snapshots, err := store.List(ctx, "project-live")
+	if err != nil {
+		t.Fatalf("Failed to list snapshots: %v", err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("Expected 1 snapshot for the live project, got %d", len(snapshots))
+	}
+	if want := "PUT /rbacpolicy/projects/live/roles/auditor"; snapshots[0].Reason != want {
+		t.Errorf("Expected snapshot reason %q, got %q", want, snapshots[0].Reason)
+	}
+	if defaults, _ := store.List(ctx, "project-default"); len(defaults) != 0 {
+		t.Errorf("Expected no snapshots for the default project, got %d", len(defaults))
+	}
+
+	if len(sink.records) != 1 {
+		t.Fatalf("Expected 1 audit record, got %d", len(sink.records))
+	}
+	record := sink.records[0]
+	if record.ProjectID != "project-live" {
+		t.Errorf("Expected audit project project-live, got %q", record.ProjectID)
+	}
+	if record.Path != "/rbacpolicy/projects/live/roles/auditor" {
+		t.Errorf("Expected audit path as sent, got %q", record.Path)
+	}
+}
+
+func TestProjectHealthCheck(t *testing.T) {
+	policies := map[string]*rbacpolicy.Policy{
+		"project-default": projectPolicy("project-default"),
+		"project-live":    projectPolicy("project-live"),
+	}
+	h := NewHandler(newProjectsMock(policies), "project-default", zap.NewNop(),
+		WithProjects(testProjects),
+		WithAuthenticator(groupAuthenticator{}),
+	)
+
+	tests := []struct {
+		path              string
+		token             string
+		expectedStatus    int
+		expectedProjectID string
+	}{
+		{path: "/ready", token: "deploy", expectedStatus: http.StatusOK, expectedProjectID: "project-default"},
+		{path: "/rbacpolicy/projects/live/ready", token: "deploy", expectedStatus: http.StatusOK, expectedProjectID: "project-live"},
+		// project-test is configured but Stytch does not know it.
+		{path: "/rbacpolicy/projects/test/ready", token: "deploy", expectedStatus: http.StatusServiceUnavailable, expectedProjectID: "project-test"},
+		// Anonymous probes under a project prefix get the same answer
+		// whether or not the project is configured.
+		{path: "/rbacpolicy/projects/live/health", expectedStatus: http.StatusUnauthorized},
+		{path: "/rbacpolicy/projects/live/ready", expectedStatus: http.StatusUnauthorized},
+		{path: "/rbacpolicy/projects/nope/health", expectedStatus: http.StatusUnauthorized},
+		{path: "/rbacpolicy/projects/nope/ready", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.token, func(t *testing.T) {
+			request := events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: tt.path}
+			if tt.token != "" {
+				request.Headers = map[string]string{"X-Amzn-Oidc-Data": tt.token}
+			}
+			response, err := h.HandleRequest(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			var body struct {
+				ProjectID string `json:"project_id"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if body.ProjectID != tt.expectedProjectID {
+				t.Errorf("Expected project_id %q, got %q", tt.expectedProjectID, body.ProjectID)
+			}
+		})
+	}
+}
+
+func TestUnknownProjectRequiresAuthentication(t *testing.T) {
+	h := NewHandler(&mockRBACPolicyClient{}, "project-default", zap.NewNop(),
+		WithProjects(testProjects),
+		WithAuthenticator(groupAuthenticator{}),
+	)
+
+	for _, path := range []string{"/rbacpolicy/projects/project-other", "/rbacpolicy/projects/project-other/health"} {
+		response, err := h.HandleRequest(context.Background(), events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: path})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: expected status code %d, got %d", path, http.StatusUnauthorized, response.StatusCode)
+		}
+	}
+}
+
+func TestRouteProject(t *testing.T) {
+	h := NewHandler(&mockRBACPolicyClient{}, "project-default", zap.NewNop(), WithProjects(testProjects))
+
+	tests := []struct {
+		path              string
+		expectedPath      string
+		expectedProjectID string
+		expectedErr       string
+	}{
+		{path: "/rbacpolicy/roles/editor", expectedPath: "/rbacpolicy/roles/editor", expectedProjectID: "project-default"},
+		{path: "/health", expectedPath: "/health", expectedProjectID: "project-default"},
+		{path: "/rbacpolicy/projectsx", expectedPath: "/rbacpolicy/projectsx", expectedProjectID: "project-default"},
+		{path: "/rbacpolicy/projects/live", expectedPath: "/rbacpolicy", expectedProjectID: "project-live"},
+		{path: "/rbacpolicy/projects/live/", expectedPath: "/rbacpolicy", expectedProjectID: "project-live"},
+		{path: "/rbacpolicy/projects/live/history/v1/restore", expectedPath: "/rbacpolicy/history/v1/restore", expectedProjectID: "project-live"},
+		{path: "/rbacpolicy/projects/staging/roles", expectedErr: `Project "staging" not found`},
+		{path: "/rbacpolicy/projects/%zz", expectedErr: `Project "%zz" not found`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			routed, route, err := h.routeProject(events.ALBTargetGroupRequest{Path: tt.path})
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("Expected error %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if routed.Path != tt.expectedPath {
+				t.Errorf("Expected path %q, got %q", tt.expectedPath, routed.Path)
+			}
+			if route.projectID != tt.expectedProjectID {
+				t.Errorf("Expected project %q, got %q", tt.expectedProjectID, route.projectID)
+			}
+			if route.path != tt.path {
+				t.Errorf("Expected original path %q, got %q", tt.path, route.path)
+			}
+		})
+	}
+}
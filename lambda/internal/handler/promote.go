@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+// promoteRequest names the source and target of a promotion by project ID or
+// alias.
+type promoteRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// handlePromote copies the custom roles and resources of one project onto
+// another. The Stytch default roles of the target are left as they are, so a
+// promotion that removes a custom resource they still grant is rejected by
+// validation. The write goes through updatePolicy against the target, so it
+// honours dry_run and If-Match, is snapshotted and audited, and needs the same
+// capabilities as the equivalent PUT.
+func (h *Handler) handlePromote(ctx context.Context, request events.ALBTargetGroupRequest) (events.ALBTargetGroupResponse, error) {
+	if request.HTTPMethod != http.MethodPost {
+		return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+
+	var body promoteRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		h.logger.Error("Failed to unmarshal request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+	}
+	if body.From == "" || body.To == "" {
+		return h.errorResponse(http.StatusBadRequest, "Both from and to are required")
+	}
+
+	from, ok := h.lookupProject(body.From)
+	if !ok {
+		return h.errorResponse(http.StatusNotFound, fmt.Sprintf("Project %q not found", body.From))
+	}
+	to, ok := h.lookupProject(body.To)
+	if !ok {
+		return h.errorResponse(http.StatusNotFound, fmt.Sprintf("Project %q not found", body.To))
+	}
+	if from == to {
+		return h.errorResponse(http.StatusBadRequest, "Cannot promote a project to itself")
+	}
+
+	// Always read the source from Stytch: promoting a stale cached copy
+	// would silently revert recent changes on the target.
+	sourceResp, err := h.client.Get(ctx, rbacpolicy.GetRequest{ProjectID: from})
+	if err != nil {
+		h.logger.Error("Failed to get source RBAC policy", zap.Error(err), zap.String("project_id", from))
+		return h.statusErrorResponse(h.clientError(err, "Failed to get source RBAC policy"))
+	}
+	source := rbac.Clone(sourceResp.Policy)
+
+	ctx = withProjectRoute(ctx, projectRoute{projectID: to, path: requestPath(ctx, request)})
+	write, err := h.updatePolicy(ctx, request, func(p *rbacpolicy.Policy) error {
+		p.CustomRoles = source.CustomRoles
+		p.CustomResources = source.CustomResources
+		return nil
+	})
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+
+	diff := rbac.Compare(write.before, write.after)
+	etagPolicy := write.after
+	if write.dryRun {
+		etagPolicy = write.before
+	} else {
+		h.logger.Info("Promoted RBAC policy",
+			zap.String("from", from),
+			zap.String("to", to),
+			zap.Bool("changed", !diff.Empty()),
+		)
+	}
+
+	return h.policyResponse(http.StatusOK, map[string]any{
+		"dry_run": write.dryRun,
+		"from":    from,
+		"to":      to,
+		"diff":    diff,
+		"policy":  write.after,
+	}, etagPolicy)
+}
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/history"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+// promotionPolicies returns a test project with an extra role and resource,
+// and a live project still on the previous version, whose default member role
+// grants a custom resource.
+func promotionPolicies() map[string]*rbacpolicy.Policy {
+	test := testRolePolicy()
+	test.StytchMember = rbacpolicy.Role{RoleID: "stytch_member", Description: "Test member"}
+	test.CustomRoles = append(test.CustomRoles, rbacpolicy.Role{
+		RoleID:      "auditor",
+		Description: "Auditor role",
+		Permissions: []rbacpolicy.Permission{{ResourceID: "reports", Actions: []string{"read"}}},
+	})
+	test.CustomResources = append(test.CustomResources, rbacpolicy.Resource{
+		ResourceID:       "reports",
+		Description:      "Reports",
+		AvailableActions: []string{"read"},
+	})
+
+	live := testRolePolicy()
+	live.StytchMember = rbacpolicy.Role{
+		RoleID:      "stytch_member",
+		Description: "Live member",
+		Permissions: []rbacpolicy.Permission{{ResourceID: "documents", Actions: []string{"read"}}},
+	}
+
+	return map[string]*rbacpolicy.Policy{
+		"project-test": &test,
+		"project-live": &live,
+	}
+}
+
+func TestHandlePromote(t *testing.T) {
+	tests := []struct {
+		name           string
+		request        events.ALBTargetGroupRequest
+		mutate         func(policies map[string]*rbacpolicy.Policy)
+		expectedStatus int
+		expectedError  string
+		// expectWrite is whether the live project should end up with the
+		// test project's custom roles and resources.
+		expectWrite bool
+	}{
+		{
+			name:           "Promote test to live",
+			request:        promoteRequestFor(`{"from": "test", "to": "live"}`),
+			expectedStatus: http.StatusOK,
+			expectWrite:    true,
+		},
+		{
+			name:           "Promote by project ID",
+			request:        promoteRequestFor(`{"from": "project-test", "to": "project-live"}`),
+			expectedStatus: http.StatusOK,
+			expectWrite:    true,
+		},
+		{
+			name: "Dry run",
+			request: events.ALBTargetGroupRequest{
+				HTTPMethod:            http.MethodPost,
+				Path:                  "/rbacpolicy/promote",
+				Body:                  `{"from": "test", "to": "live"}`,
+				QueryStringParameters: map[string]string{"dry_run": "true"},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "Stytch default role grants a removed resource",
+			mutate: func(policies map[string]*rbacpolicy.Policy) {
+				live := policies["project-live"]
+				live.CustomResources = append(live.CustomResources, rbacpolicy.Resource{ResourceID: "billing", AvailableActions: []string{"read"}})
+				live.StytchMember.Permissions = append(live.StytchMember.Permissions, rbacpolicy.Permission{ResourceID: "billing", Actions: []string{"read"}})
+			},
+			request:        promoteRequestFor(`{"from": "test", "to": "live"}`),
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedError:  "RBAC policy failed validation",
+		},
+		{
+			name: "Stale If-Match",
+			request: events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodPost,
+				Path:       "/rbacpolicy/promote",
+				Body:       `{"from": "test", "to": "live"}`,
+				Headers:    map[string]string{"If-Match": `"stale"`},
+			},
+			expectedStatus: http.StatusPreconditionFailed,
+			expectedError:  "RBAC policy has changed since it was read",
+		},
+		{
+			name: "Source missing in Stytch",
+			mutate: func(policies map[string]*rbacpolicy.Policy) {
+				delete(policies, "project-test")
+			},
+			request:        promoteRequestFor(`{"from": "test", "to": "live"}`),
+			expectedStatus: http.StatusNotFound,
+			expectedError:  "Failed to get source RBAC policy: Project not found.",
+		},
+		{
+			name:           "Unknown source",
+			request:        promoteRequestFor(`{"from": "staging", "to": "live"}`),
+			expectedStatus: http.StatusNotFound,
+			expectedError:  `Project "staging" not found`,
+		},
+		{
+			name:           "Unknown target",
+			request:        promoteRequestFor(`{"from": "test", "to": "prod"}`),
+			expectedStatus: http.StatusNotFound,
+			expectedError:  `Project "prod" not found`,
+		},
+		{
+			name:           "Same project",
+			request:        promoteRequestFor(`{"from": "live", "to": "project-live"}`),
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Cannot promote a project to itself",
+		},
+		{
+			name:           "Missing target",
+			request:        promoteRequestFor(`{"from": "test"}`),
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  "Both from and to are required",
+		},
+		{
+			name:           "Invalid body",
+			request:        promoteRequestFor(`{"from": `),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Wrong method",
+			request:        events.ALBTargetGroupRequest{HTTPMethod: http.MethodGet, Path: "/rbacpolicy/promote"},
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := promotionPolicies()
+			if tt.mutate != nil {
+				tt.mutate(policies)
+			}
+			liveBefore := rbac.Clone(*policies["project-live"])
+			var testBefore rbacpolicy.Policy
+			if p, ok := policies["project-test"]; ok {
+				testBefore = rbac.Clone(*p)
+			}
+			h := NewHandler(newProjectsMock(policies), "project-test", zap.NewNop(), WithProjects(testProjects))
+
+			response, err := h.HandleRequest(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, response.StatusCode, response.Body)
+			}
+			if tt.expectedError != "" {
+				var body map[string]any
+				if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+					t.Fatalf("Failed to unmarshal response body: %v", err)
+				}
+				if body["error"] != tt.expectedError {
+					t.Errorf("Expected error %q, got %v", tt.expectedError, body["error"])
+				}
+			}
+
+			live := *policies["project-live"]
+			if !reflect.DeepEqual(live.StytchMember, liveBefore.StytchMember) || !reflect.DeepEqual(live.StytchAdmin, liveBefore.StytchAdmin) {
+				t.Error("Expected live Stytch default roles to be untouched")
+			}
+			if p, ok := policies["project-test"]; ok && !reflect.DeepEqual(*p, testBefore) {
+				t.Error("Expected source project to be untouched")
+			}
+
+			if !tt.expectWrite {
+				if !reflect.DeepEqual(live, liveBefore) {
+					t.Error("Expected live project to be unchanged")
+				}
+				return
+			}
+			if !reflect.DeepEqual(live.CustomRoles, testBefore.CustomRoles) {
+				t.Errorf("Expected live custom roles %v, got %v", testBefore.CustomRoles, live.CustomRoles)
+			}
+			if !reflect.DeepEqual(live.CustomResources, testBefore.CustomResources) {
+				t.Errorf("Expected live custom resources %v, got %v", testBefore.CustomResources, live.CustomResources)
+			}
+			if response.Headers["ETag"] != policyETag(live) {
+				t.Errorf("Expected ETag of the promoted policy, got %q", response.Headers["ETag"])
+			}
+		})
+	}
+}
+
+func TestPromoteResponse(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		policies := promotionPolicies()
+		store := history.NewMemoryStore()
+		sink := &recordingSink{}
+		h := NewHandler(newProjectsMock(policies), "project-test", zap.NewNop(),
+			WithProjects(testProjects),
+			WithHistoryStore(store),
+			WithAuditSink(sink),
+		)
+
+		request := promoteRequestFor(`{"from": "test", "to": "live"}`)
+		if dryRun {
+			request.QueryStringParameters = map[string]string{"dry_run": "true"}
+		}
+		response, err := h.HandleRequest(context.Background(), request)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		var body struct {
+			DryRun bool              `json:"dry_run"`
+			From   string            `json:"from"`
+			To     string            `json:"to"`
+			Diff   rbac.Diff         `json:"diff"`
+			Policy rbacpolicy.Policy `json:"policy"`
+		}
+		if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+			t.Fatalf("Failed to unmarshal response body: %v", err)
+		}
+		if body.DryRun != dryRun || body.From != "project-test" || body.To != "project-live" {
+			t.Errorf("Unexpected promotion summary: dry_run=%v from=%q to=%q", body.DryRun, body.From, body.To)
+		}
+		if !reflect.DeepEqual(body.Diff.RolesAdded, []string{"auditor"}) || !reflect.DeepEqual(body.Diff.ResourcesAdded, []string{"reports"}) {
+			t.Errorf("Expected auditor and reports to be added, got %+v", body.Diff)
+		}
+		if body.Policy.StytchMember.Description != "Live member" {
+			t.Errorf("Expected policy to keep the live member role, got %q", body.Policy.StytchMember.Description)
+		}
+
+		snapshots, _ := store.List(context.Background(), "project-live")
+		wantRecords := 1
+		if dryRun {
+			wantRecords = 0
+		}
+		if len(snapshots) != wantRecords || len(sink.records) != wantRecords {
+			t.Errorf("dry_run=%v: expected %d snapshots and audit records, got %d and %d", dryRun, wantRecords, len(snapshots), len(sink.records))
+		}
+		if len(sink.records) == 1 && sink.records[0].ProjectID != "project-live" {
+			t.Errorf("Expected audit record for project-live, got %q", sink.records[0].ProjectID)
+		}
+	}
+}
+
+func promoteRequestFor(body string) events.ALBTargetGroupRequest {
+	return events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodPost,
+		Path:       "/rbacpolicy/promote",
+		Body:       body,
+	}
+}
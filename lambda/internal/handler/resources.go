@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) handleResource(ctx context.Context, request events.ALBTargetGroupRequest, resourceID, sub string) (events.ALBTargetGroupResponse, error) {
+	switch sub {
+	case "":
+	case "grants":
+		if request.HTTPMethod != http.MethodGet {
+			return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return h.handleGetResourceGrants(ctx, request, resourceID)
+	default:
+		return h.errorResponse(http.StatusNotFound, "Not found")
+	}
+
+	switch request.HTTPMethod {
+	case http.MethodGet:
+		return h.handleGetResource(ctx, request, resourceID)
+	case http.MethodPut:
+		return h.handlePutResource(ctx, request, resourceID)
+	case http.MethodDelete:
+		cascade, err := parseBoolParam(queryParam(request, "cascade"))
+		if err != nil {
+			return h.errorResponse(http.StatusBadRequest, "Invalid cascade parameter")
+		}
+		return h.handleDeleteResource(ctx, request, resourceID, cascade)
+	default:
+		return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (h *Handler) handleGetResource(ctx context.Context, request events.ALBTargetGroupRequest, resourceID string) (events.ALBTargetGroupResponse, error) {
+	policy, err := h.getPolicy(ctx, request)
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+
+	i := findResource(policy.CustomResources, resourceID)
+	if i < 0 {
+		return h.errorResponse(http.StatusNotFound, fmt.Sprintf("Resource %q not found", resourceID))
+	}
+
+	return h.policyResponse(http.StatusOK, policy.CustomResources[i], policy)
+}
+
+// handleGetResourceGrants lists which roles may perform which actions on a
+// custom or Stytch resource.
+func (h *Handler) handleGetResourceGrants(ctx context.Context, request events.ALBTargetGroupRequest, resourceID string) (events.ALBTargetGroupResponse, error) {
+	policy, err := h.getPolicy(ctx, request)
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+
+	if _, ok := rbac.FindResource(policy, resourceID); !ok {
+		return h.errorResponse(http.StatusNotFound, fmt.Sprintf("Resource %q not found", resourceID))
+	}
+
+	return h.policyResponse(http.StatusOK, map[string]any{
+		"resource_id": resourceID,
+		"grants":      rbac.ResourceGrants(policy, resourceID),
+	}, policy)
+}
+
+func (h *Handler) handlePutResource(ctx context.Context, request events.ALBTargetGroupRequest, resourceID string) (events.ALBTargetGroupResponse, error) {
+	var resource rbacpolicy.Resource
+	if err := json.Unmarshal([]byte(request.Body), &resource); err != nil {
+		h.logger.Error("Failed to unmarshal request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+	}
+	if resource.ResourceID != "" && resource.ResourceID != resourceID {
+		return h.errorResponse(http.StatusBadRequest, fmt.Sprintf("Resource ID %q in body does not match %q in path", resource.ResourceID, resourceID))
+	}
+	resource.ResourceID = resourceID
+
+	created := false
+	write, err := h.updatePolicy(ctx, request, func(p *rbacpolicy.Policy) error {
+		if i := findResource(p.CustomResources, resourceID); i >= 0 {
+			p.CustomResources[i] = resource
+			return nil
+		}
+		p.CustomResources = append(p.CustomResources, resource)
+		created = true
+		return nil
+	})
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+	if write.dryRun {
+		return h.dryRunResponse(write)
+	}
+
+	h.logger.Info("Stored custom resource", zap.String("resource_id", resourceID), zap.Bool("created", created))
+
+	if i := findResource(write.after.CustomResources, resourceID); i >= 0 {
+		resource = write.after.CustomResources[i]
+	}
+	statusCode := http.StatusOK
+	if created {
+		statusCode = http.StatusCreated
+	}
+	return h.policyResponse(statusCode, resource, write.after)
+}
+
+func (h *Handler) handleDeleteResource(ctx context.Context, request events.ALBTargetGroupRequest, resourceID string, cascade bool) (events.ALBTargetGroupResponse, error) {
+	write, err := h.updatePolicy(ctx, request, func(p *rbacpolicy.Policy) error {
+		i := findResource(p.CustomResources, resourceID)
+		if i < 0 {
+			return &statusError{
+				statusCode: http.StatusNotFound,
+				message:    fmt.Sprintf("Resource %q not found", resourceID),
+			}
+		}
+
+		if referencing := rolesReferencing(p, resourceID); len(referencing) > 0 {
+			if !cascade {
+				return &statusError{
+					statusCode: http.StatusConflict,
+					message:    fmt.Sprintf("Resource %q is still referenced by roles", resourceID),
+					details:    map[string]any{"roles": referencing},
+				}
+			}
+			stripPermissions(p, resourceID)
+		}
+
+		p.CustomResources = append(p.CustomResources[:i], p.CustomResources[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+	if write.dryRun {
+		return h.dryRunResponse(write)
+	}
+
+	h.logger.Info("Deleted custom resource", zap.String("resource_id", resourceID), zap.Bool("cascade", cascade))
+
+	return h.policyNoContentResponse(write.after), nil
+}
+
+// findResource returns the index of the resource with the given ID, or -1.
+func findResource(resources []rbacpolicy.Resource, resourceID string) int {
+	for i := range resources {
+		if resources[i].ResourceID == resourceID {
+			return i
+		}
+	}
+	return -1
+}
+
+// policyRoles returns pointers to every role in the policy, including the
+// Stytch default roles, which may also be granted custom resources.
+func policyRoles(p *rbacpolicy.Policy) []*rbacpolicy.Role {
+	roles := []*rbacpolicy.Role{&p.StytchMember, &p.StytchAdmin}
+	for i := range p.CustomRoles {
+		roles = append(roles, &p.CustomRoles[i])
+	}
+	return roles
+}
+
+// rolesReferencing lists the IDs of roles holding a permission on resourceID.
+func rolesReferencing(p *rbacpolicy.Policy, resourceID string) []string {
+	var roleIDs []string
+	for _, role := range policyRoles(p) {
+		for _, perm := range role.Permissions {
+			if perm.ResourceID == resourceID {
+				roleIDs = append(roleIDs, role.RoleID)
+				break
+			}
+		}
+	}
+	return roleIDs
+}
+
+// stripPermissions removes every permission on resourceID from every role.
+func stripPermissions(p *rbacpolicy.Policy, resourceID string) {
+	for _, role := range policyRoles(p) {
+		kept := make([]rbacpolicy.Permission, 0, len(role.Permissions))
+		for _, perm := range role.Permissions {
+			if perm.ResourceID != resourceID {
+				kept = append(kept, perm)
+			}
+		}
+		role.Permissions = kept
+	}
+}
@@ -0,0 +1,377 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func testResourcePolicy() rbacpolicy.Policy {
+	policy := testRolePolicy()
+	policy.StytchMember = rbacpolicy.Role{
+		RoleID: "stytch_member",
+		Permissions: []rbacpolicy.Permission{
+			{ResourceID: "reports", Actions: []string{"read"}},
+		},
+	}
+	policy.CustomResources = append(policy.CustomResources,
+		rbacpolicy.Resource{ResourceID: "reports", AvailableActions: []string{"read"}},
+		rbacpolicy.Resource{ResourceID: "archive", AvailableActions: []string{"read"}},
+	)
+	return policy
+}
+
+func TestHandleGetResource(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{
+			name:           "Existing resource",
+			path:           "/rbacpolicy/resources/reports",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Missing resource",
+			path:           "/rbacpolicy/resources/invoices",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Nested path",
+			path:           "/rbacpolicy/resources/reports/extra",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testResourcePolicy()
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       tt.path,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+
+			if tt.expectedStatus == http.StatusOK {
+				var resource rbacpolicy.Resource
+				if err := json.Unmarshal([]byte(response.Body), &resource); err != nil {
+					t.Fatalf("Failed to unmarshal response body: %v", err)
+				}
+				if resource.ResourceID != "reports" {
+					t.Errorf("Expected resource %q, got %q", "reports", resource.ResourceID)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlePutResource(t *testing.T) {
+	tests := []struct {
+		name              string
+		path              string
+		body              string
+		expectedStatus    int
+		expectedResources int
+	}{
+		{
+			name:              "Replace existing resource",
+			path:              "/rbacpolicy/resources/reports",
+			body:              `{"description":"Reports","available_actions":["read","export"]}`,
+			expectedStatus:    http.StatusOK,
+			expectedResources: 3,
+		},
+		{
+			name:              "Create new resource",
+			path:              "/rbacpolicy/resources/invoices",
+			body:              `{"resource_id":"invoices","available_actions":["read"]}`,
+			expectedStatus:    http.StatusCreated,
+			expectedResources: 4,
+		},
+		{
+			name:              "Mismatched resource ID",
+			path:              "/rbacpolicy/resources/reports",
+			body:              `{"resource_id":"invoices"}`,
+			expectedStatus:    http.StatusBadRequest,
+			expectedResources: 3,
+		},
+		{
+			name:              "Invalid JSON body",
+			path:              "/rbacpolicy/resources/reports",
+			body:              "invalid json",
+			expectedStatus:    http.StatusBadRequest,
+			expectedResources: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testResourcePolicy()
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodPut,
+				Path:       tt.path,
+				Body:       tt.body,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+			if len(policy.CustomResources) != tt.expectedResources {
+				t.Errorf("Expected %d custom resources, got %d", tt.expectedResources, len(policy.CustomResources))
+			}
+			if len(policy.CustomRoles) != 2 {
+				t.Errorf("Expected custom roles to be preserved, got %d", len(policy.CustomRoles))
+			}
+		})
+	}
+}
+
+func TestHandleDeleteResource(t *testing.T) {
+	tests := []struct {
+		name              string
+		path              string
+		query             map[string]string
+		expectedStatus    int
+		expectedResources int
+		expectedConflicts []string
+	}{
+		{
+			name:              "Delete unreferenced resource",
+			path:              "/rbacpolicy/resources/archive",
+			expectedStatus:    http.StatusNoContent,
+			expectedResources: 2,
+		},
+		{
+			name:              "Delete missing resource",
+			path:              "/rbacpolicy/resources/invoices",
+			expectedStatus:    http.StatusNotFound,
+			expectedResources: 3,
+		},
+		{
+			name:              "Delete referenced resource without cascade",
+			path:              "/rbacpolicy/resources/documents",
+			expectedStatus:    http.StatusConflict,
+			expectedResources: 3,
+			expectedConflicts: []string{"editor", "viewer"},
+		},
+		{
+			name:              "Referenced by Stytch default role",
+			path:              "/rbacpolicy/resources/reports",
+			expectedStatus:    http.StatusConflict,
+			expectedResources: 3,
+			expectedConflicts: []string{"stytch_member"},
+		},
+		{
+			name:              "Delete referenced resource with cascade",
+			path:              "/rbacpolicy/resources/documents",
+			query:             map[string]string{"cascade": "true"},
+			expectedStatus:    http.StatusNoContent,
+			expectedResources: 2,
+		},
+		{
+			name:              "Invalid cascade flag",
+			path:              "/rbacpolicy/resources/documents",
+			query:             map[string]string{"cascade": "maybe"},
+			expectedStatus:    http.StatusBadRequest,
+			expectedResources: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testResourcePolicy()
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod:            http.MethodDelete,
+				Path:                  tt.path,
+				QueryStringParameters: tt.query,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+			if len(policy.CustomResources) != tt.expectedResources {
+				t.Errorf("Expected %d custom resources, got %d", tt.expectedResources, len(policy.CustomResources))
+			}
+
+			if tt.expectedConflicts != nil {
+				var body struct {
+					Error string   `json:"error"`
+					Roles []string `json:"roles"`
+				}
+				if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+					t.Fatalf("Failed to unmarshal response body: %v", err)
+				}
+				if len(body.Roles) != len(tt.expectedConflicts) {
+					t.Fatalf("Expected referencing roles %v, got %v", tt.expectedConflicts, body.Roles)
+				}
+				for i := range body.Roles {
+					if body.Roles[i] != tt.expectedConflicts[i] {
+						t.Errorf("Expected referencing roles %v, got %v", tt.expectedConflicts, body.Roles)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHandleDeleteResourceCascadeStripsPermissions(t *testing.T) {
+	policy := testResourcePolicy()
+	policy.CustomRoles[0].Permissions = append(policy.CustomRoles[0].Permissions,
+		rbacpolicy.Permission{ResourceID: "reports", Actions: []string{"read"}},
+	)
+	handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+	response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod:                      http.MethodDelete,
+		Path:                            "/rbacpolicy/resources/reports",
+		MultiValueQueryStringParameters: map[string][]string{"cascade": {"1"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, response.StatusCode)
+	}
+
+	for _, role := range policyRoles(&policy) {
+		for _, perm := range role.Permissions {
+			if perm.ResourceID == "reports" {
+				t.Errorf("Expected permission on reports to be stripped from role %q", role.RoleID)
+			}
+		}
+	}
+	if len(policy.CustomRoles[0].Permissions) != 1 {
+		t.Errorf("Expected unrelated permissions to be preserved, got %v", policy.CustomRoles[0].Permissions)
+	}
+}
+
+func TestHandleResourceClientErrors(t *testing.T) {
+	failing := &mockRBACPolicyClient{
+		getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+			return nil, errors.New("client error")
+		},
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			handler := NewHandler(failing, "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: method,
+				Path:       "/rbacpolicy/resources/reports",
+				Body:       `{"available_actions":["read"]}`,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != http.StatusInternalServerError {
+				t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, response.StatusCode)
+			}
+		})
+	}
+}
+
+func TestHandleGetResourceGrants(t *testing.T) {
+	policy := testResourcePolicy()
+	policy.StytchResources = []rbacpolicy.Resource{
+		{ResourceID: "stytch.self", AvailableActions: []string{"read"}},
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expected       map[string][]string
+	}{
+		{
+			name:           "Custom resource",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/resources/documents/grants",
+			expectedStatus: http.StatusOK,
+			expected: map[string][]string{
+				"editor": {"read", "write"},
+				"viewer": {"read"},
+			},
+		},
+		{
+			name:           "Resource granted to Stytch default role",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/resources/reports/grants",
+			expectedStatus: http.StatusOK,
+			expected:       map[string][]string{"stytch_member": {"read"}},
+		},
+		{
+			name:           "Stytch resource with no grants",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/resources/stytch.self/grants",
+			expectedStatus: http.StatusOK,
+			expected:       map[string][]string{},
+		},
+		{
+			name:           "Missing resource",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/resources/invoices/grants",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Unsupported method",
+			method:         http.MethodDelete,
+			path:           "/rbacpolicy/resources/documents/grants",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: tt.method,
+				Path:       tt.path,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+			if tt.expected == nil {
+				return
+			}
+
+			var body struct {
+				ResourceID string              `json:"resource_id"`
+				Grants     map[string][]string `json:"grants"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if !reflect.DeepEqual(body.Grants, tt.expected) {
+				t.Errorf("Expected grants %v, got %v", tt.expected, body.Grants)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) handleRole(ctx context.Context, request events.ALBTargetGroupRequest, roleID, sub string) (events.ALBTargetGroupResponse, error) {
+	switch sub {
+	case "":
+	case "effective":
+		if request.HTTPMethod != http.MethodGet {
+			return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		return h.handleGetRoleEffective(ctx, request, roleID)
+	default:
+		return h.errorResponse(http.StatusNotFound, "Not found")
+	}
+
+	switch request.HTTPMethod {
+	case http.MethodGet:
+		return h.handleGetRole(ctx, request, roleID)
+	case http.MethodPut:
+		return h.handlePutRole(ctx, request, roleID)
+	case http.MethodDelete:
+		return h.handleDeleteRole(ctx, request, roleID)
+	default:
+		return h.errorResponse(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (h *Handler) handleGetRole(ctx context.Context, request events.ALBTargetGroupRequest, roleID string) (events.ALBTargetGroupResponse, error) {
+	policy, err := h.getPolicy(ctx, request)
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+
+	i := findRole(policy.CustomRoles, roleID)
+	if i < 0 {
+		return h.errorResponse(http.StatusNotFound, fmt.Sprintf("Role %q not found", roleID))
+	}
+
+	return h.policyResponse(http.StatusOK, policy.CustomRoles[i], policy)
+}
+
+// handleGetRoleEffective returns the flattened resource to actions map for a
+// custom or Stytch default role, with "*" expanded to the resource's actions.
+func (h *Handler) handleGetRoleEffective(ctx context.Context, request events.ALBTargetGroupRequest, roleID string) (events.ALBTargetGroupResponse, error) {
+	policy, err := h.getPolicy(ctx, request)
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+
+	role, ok := rbac.FindRole(policy, roleID)
+	if !ok {
+		return h.errorResponse(http.StatusNotFound, fmt.Sprintf("Role %q not found", roleID))
+	}
+
+	return h.policyResponse(http.StatusOK, map[string]any{
+		"role_id":     role.RoleID,
+		"permissions": rbac.EffectivePermissions(policy, role),
+	}, policy)
+}
+
+func (h *Handler) handlePutRole(ctx context.Context, request events.ALBTargetGroupRequest, roleID string) (events.ALBTargetGroupResponse, error) {
+	var role rbacpolicy.Role
+	if err := json.Unmarshal([]byte(request.Body), &role); err != nil {
+		h.logger.Error("Failed to unmarshal request body", zap.Error(err))
+		return h.errorResponse(http.StatusBadRequest, fmt.Sprintf("Invalid request body: %v", err))
+	}
+	if role.RoleID != "" && role.RoleID != roleID {
+		return h.errorResponse(http.StatusBadRequest, fmt.Sprintf("Role ID %q in body does not match %q in path", role.RoleID, roleID))
+	}
+	role.RoleID = roleID
+
+	created := false
+	write, err := h.updatePolicy(ctx, request, func(p *rbacpolicy.Policy) error {
+		if i := findRole(p.CustomRoles, roleID); i >= 0 {
+			p.CustomRoles[i] = role
+			return nil
+		}
+		p.CustomRoles = append(p.CustomRoles, role)
+		created = true
+		return nil
+	})
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+	if write.dryRun {
+		return h.dryRunResponse(write)
+	}
+
+	h.logger.Info("Stored custom role", zap.String("role_id", roleID), zap.Bool("created", created))
+
+	if i := findRole(write.after.CustomRoles, roleID); i >= 0 {
+		role = write.after.CustomRoles[i]
+	}
+	statusCode := http.StatusOK
+	if created {
+		statusCode = http.StatusCreated
+	}
+	return h.policyResponse(statusCode, role, write.after)
+}
+
+func (h *Handler) handleDeleteRole(ctx context.Context, request events.ALBTargetGroupRequest, roleID string) (events.ALBTargetGroupResponse, error) {
+	write, err := h.updatePolicy(ctx, request, func(p *rbacpolicy.Policy) error {
+		i := findRole(p.CustomRoles, roleID)
+		if i < 0 {
+			return &statusError{
+				statusCode: http.StatusNotFound,
+				message:    fmt.Sprintf("Role %q not found", roleID),
+			}
+		}
+		p.CustomRoles = append(p.CustomRoles[:i], p.CustomRoles[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		return h.statusErrorResponse(err)
+	}
+	if write.dryRun {
+		return h.dryRunResponse(write)
+	}
+
+	h.logger.Info("Deleted custom role", zap.String("role_id", roleID))
+
+	return h.policyNoContentResponse(write.after), nil
+}
+
+// findRole returns the index of the role with the given ID, or -1.
+func findRole(roles []rbacpolicy.Role, roleID string) int {
+	for i := range roles {
+		if roles[i].RoleID == roleID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,381 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+func testRolePolicy() rbacpolicy.Policy {
+	return rbacpolicy.Policy{
+		CustomRoles: []rbacpolicy.Role{
+			{
+				RoleID:      "editor",
+				Description: "Editor role",
+				Permissions: []rbacpolicy.Permission{
+					{ResourceID: "documents", Actions: []string{"read", "write"}},
+				},
+			},
+			{
+				RoleID:      "viewer",
+				Description: "Viewer role",
+				Permissions: []rbacpolicy.Permission{
+					{ResourceID: "documents", Actions: []string{"read"}},
+				},
+			},
+		},
+		CustomResources: []rbacpolicy.Resource{
+			{
+				ResourceID:       "documents",
+				Description:      "Document resources",
+				AvailableActions: []string{"read", "write", "delete"},
+			},
+		},
+	}
+}
+
+func TestHandleGetRole(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedRole   string
+	}{
+		{
+			name:           "Existing role",
+			path:           "/rbacpolicy/roles/viewer",
+			expectedStatus: http.StatusOK,
+			expectedRole:   "viewer",
+		},
+		{
+			name:           "Escaped role ID",
+			path:           "/rbacpolicy/roles/%65ditor",
+			expectedStatus: http.StatusOK,
+			expectedRole:   "editor",
+		},
+		{
+			name:           "Missing role",
+			path:           "/rbacpolicy/roles/owner",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Empty role ID",
+			path:           "/rbacpolicy/roles/",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       tt.path,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+
+			if tt.expectedRole != "" {
+				var role rbacpolicy.Role
+				if err := json.Unmarshal([]byte(response.Body), &role); err != nil {
+					t.Fatalf("Failed to unmarshal response body: %v", err)
+				}
+				if role.RoleID != tt.expectedRole {
+					t.Errorf("Expected role %q, got %q", tt.expectedRole, role.RoleID)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlePutRole(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+		expectedRoles  int
+	}{
+		{
+			name:           "Replace existing role",
+			path:           "/rbacpolicy/roles/viewer",
+			body:           `{"description":"Read-only","permissions":[{"resource_id":"documents","actions":["read"]}]}`,
+			expectedStatus: http.StatusOK,
+			expectedRoles:  2,
+		},
+		{
+			name:           "Create new role",
+			path:           "/rbacpolicy/roles/auditor",
+			body:           `{"role_id":"auditor","description":"Auditor","permissions":[]}`,
+			expectedStatus: http.StatusCreated,
+			expectedRoles:  3,
+		},
+		{
+			name:           "Mismatched role ID",
+			path:           "/rbacpolicy/roles/viewer",
+			body:           `{"role_id":"editor"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedRoles:  2,
+		},
+		{
+			name:           "Permission on unknown resource",
+			path:           "/rbacpolicy/roles/viewer",
+			body:           `{"permissions":[{"resource_id":"reports","actions":["read"]}]}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedRoles:  2,
+		},
+		{
+			name:           "Invalid JSON body",
+			path:           "/rbacpolicy/roles/viewer",
+			body:           "invalid json",
+			expectedStatus: http.StatusBadRequest,
+			expectedRoles:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodPut,
+				Path:       tt.path,
+				Body:       tt.body,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+			if len(policy.CustomRoles) != tt.expectedRoles {
+				t.Errorf("Expected %d custom roles, got %d", tt.expectedRoles, len(policy.CustomRoles))
+			}
+			if policy.CustomRoles[0].RoleID != "editor" {
+				t.Errorf("Expected unrelated role to be preserved, got %q", policy.CustomRoles[0].RoleID)
+			}
+			if tt.expectedStatus >= http.StatusBadRequest && policyETag(policy) != policyETag(testRolePolicy()) {
+				t.Errorf("Expected stored policy to be unchanged after a rejected write")
+			}
+		})
+	}
+}
+
+func TestHandleDeleteRole(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedRoles  []string
+	}{
+		{
+			name:           "Delete existing role",
+			path:           "/rbacpolicy/roles/editor",
+			expectedStatus: http.StatusNoContent,
+			expectedRoles:  []string{"viewer"},
+		},
+		{
+			name:           "Delete missing role",
+			path:           "/rbacpolicy/roles/owner",
+			expectedStatus: http.StatusNotFound,
+			expectedRoles:  []string{"editor", "viewer"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testRolePolicy()
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: http.MethodDelete,
+				Path:       tt.path,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+
+			var got []string
+			for _, role := range policy.CustomRoles {
+				got = append(got, role.RoleID)
+			}
+			if len(got) != len(tt.expectedRoles) {
+				t.Fatalf("Expected roles %v, got %v", tt.expectedRoles, got)
+			}
+			for i := range got {
+				if got[i] != tt.expectedRoles[i] {
+					t.Errorf("Expected roles %v, got %v", tt.expectedRoles, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleRoleClientErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		mockClient *mockRBACPolicyClient
+	}{
+		{
+			name:   "GET fails",
+			method: http.MethodGet,
+			mockClient: &mockRBACPolicyClient{
+				getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+					return nil, errors.New("client error")
+				},
+			},
+		},
+		{
+			name:   "PUT fails on Set",
+			method: http.MethodPut,
+			mockClient: &mockRBACPolicyClient{
+				getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+					return &rbacpolicy.GetResponse{StatusCode: 200, Policy: testRolePolicy()}, nil
+				},
+				setFunc: func(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+					return nil, errors.New("client error")
+				},
+			},
+		},
+		{
+			name:   "DELETE fails on Get",
+			method: http.MethodDelete,
+			mockClient: &mockRBACPolicyClient{
+				getFunc: func(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+					return nil, errors.New("client error")
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(tt.mockClient, "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: tt.method,
+				Path:       "/rbacpolicy/roles/viewer",
+				Body:       `{"description":"Viewer"}`,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != http.StatusInternalServerError {
+				t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, response.StatusCode)
+			}
+		})
+	}
+}
+
+func TestHandleRoleUnsupportedMethod(t *testing.T) {
+	handler := NewHandler(&mockRBACPolicyClient{}, "test-project-id", zap.NewNop())
+
+	response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+		HTTPMethod: http.MethodPost,
+		Path:       "/rbacpolicy/roles/viewer",
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status code %d, got %d", http.StatusMethodNotAllowed, response.StatusCode)
+	}
+}
+
+func TestHandleGetRoleEffective(t *testing.T) {
+	policy := testRolePolicy()
+	policy.StytchAdmin = rbacpolicy.Role{
+		RoleID:      "stytch_admin",
+		Permissions: []rbacpolicy.Permission{{ResourceID: "documents", Actions: []string{"*"}}},
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		expectedStatus int
+		expected       map[string][]string
+	}{
+		{
+			name:           "Custom role",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/roles/editor/effective",
+			expectedStatus: http.StatusOK,
+			expected:       map[string][]string{"documents": {"read", "write"}},
+		},
+		{
+			name:           "Stytch default role with wildcard",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/roles/stytch_admin/effective",
+			expectedStatus: http.StatusOK,
+			expected:       map[string][]string{"documents": {"delete", "read", "write"}},
+		},
+		{
+			name:           "Missing role",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/roles/owner/effective",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Unknown sub-resource",
+			method:         http.MethodGet,
+			path:           "/rbacpolicy/roles/editor/members",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Unsupported method",
+			method:         http.MethodPut,
+			path:           "/rbacpolicy/roles/editor/effective",
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(newStatefulMock(&policy), "test-project-id", zap.NewNop())
+
+			response, err := handler.HandleRequest(context.Background(), events.ALBTargetGroupRequest{
+				HTTPMethod: tt.method,
+				Path:       tt.path,
+			})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if response.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, response.StatusCode)
+			}
+			if tt.expected == nil {
+				return
+			}
+
+			var body struct {
+				RoleID      string              `json:"role_id"`
+				Permissions map[string][]string `json:"permissions"`
+			}
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("Failed to unmarshal response body: %v", err)
+			}
+			if !reflect.DeepEqual(body.Permissions, tt.expected) {
+				t.Errorf("Expected permissions %v, got %v", tt.expected, body.Permissions)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/storekey"
+)
+
+// FileStore keeps one JSON file per snapshot under dir/<project_id>/.
+type FileStore struct {
+	dir string
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) Save(ctx context.Context, snapshot Snapshot) (Snapshot, error) {
+	snapshot = prepare(snapshot)
+	if !storekey.Valid(snapshot.ProjectID) || !storekey.Valid(snapshot.Version) {
+		return Snapshot{}, fmt.Errorf("invalid snapshot key %q/%q", snapshot.ProjectID, snapshot.Version)
+	}
+
+	projectDir := filepath.Join(s.dir, snapshot.ProjectID)
+	if err := os.MkdirAll(projectDir, 0o750); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, snapshot.Version+".json"), body, 0o600); err != nil {
+		return Snapshot{}, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+func (s *FileStore) List(ctx context.Context, projectID string) ([]Snapshot, error) {
+	if !storekey.Valid(projectID) {
+		return []Snapshot{}, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, projectID))
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	out := make([]Snapshot, 0, len(entries))
+	for _, entry := range entries {
+		version, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		snapshot, err := s.Get(ctx, projectID, version)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, summary(*snapshot))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version > out[j].Version })
+	return out, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, projectID, version string) (*Snapshot, error) {
+	if !storekey.Valid(projectID) || !storekey.Valid(version) {
+		return nil, ErrNotFound
+	}
+
+	body, err := os.ReadFile(filepath.Join(s.dir, projectID, version+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(body, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
@@ -0,0 +1,44 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	testStore(t, NewFileStore(t.TempDir()))
+}
+
+func TestFileStoreLayout(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+
+	saved, err := store.Save(context.Background(), Snapshot{ProjectID: "project-a", Policy: testPolicy("editor")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "project-a", saved.Version+".json")); err != nil {
+		t.Errorf("Expected snapshot file: %v", err)
+	}
+
+	// Stray files in the project directory are ignored.
+	if err := os.WriteFile(filepath.Join(dir, "project-a", "notes.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	snapshots, err := store.List(context.Background(), "project-a")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(snapshots) != 1 {
+		t.Errorf("Expected one snapshot, got %d", len(snapshots))
+	}
+}
+
+func TestFileStoreRejectsInvalidProject(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	if _, err := store.Save(context.Background(), Snapshot{ProjectID: "../escape"}); err == nil {
+		t.Error("Expected error for invalid project ID")
+	}
+}
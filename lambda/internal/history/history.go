@@ -0,0 +1,65 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// ErrNotFound is returned by Store.Get when no snapshot has the given version.
+var ErrNotFound = errors.New("snapshot not found")
+
+// Snapshot is a copy of a project's policy taken immediately before it was
+// overwritten.
+type Snapshot struct {
+	// Version identifies the snapshot within its project. Versions sort
+	// lexically in the order they were taken.
+	Version   string    `json:"version"`
+	ProjectID string    `json:"project_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// Reason describes the write that replaced this policy, e.g. "PUT /rbacpolicy".
+	Reason string            `json:"reason"`
+	Hash   string            `json:"hash"`
+	Policy rbacpolicy.Policy `json:"policy"`
+}
+
+// Store persists policy snapshots.
+type Store interface {
+	// Save stores a snapshot. Version and CreatedAt are assigned by the
+	// store when empty, and the stored snapshot is returned.
+	Save(ctx context.Context, snapshot Snapshot) (Snapshot, error)
+	// List returns a project's snapshots, newest first, without policies.
+	List(ctx context.Context, projectID string) ([]Snapshot, error)
+	// Get returns a single snapshot, or ErrNotFound.
+	Get(ctx context.Context, projectID, version string) (*Snapshot, error)
+}
+
+var sequence atomic.Uint32
+
+// newVersion returns a sortable version string for a snapshot taken at t. The
+// trailing counter keeps versions unique within a process even when the clock
+// does not advance between snapshots.
+func newVersion(t time.Time) string {
+	return fmt.Sprintf("%s-%04d", t.UTC().Format("20060102T150405.000000000Z"), sequence.Add(1)%10000)
+}
+
+// prepare fills in the fields a store is responsible for assigning.
+func prepare(snapshot Snapshot) Snapshot {
+	if snapshot.CreatedAt.IsZero() {
+		snapshot.CreatedAt = time.Now().UTC()
+	}
+	if snapshot.Version == "" {
+		snapshot.Version = newVersion(snapshot.CreatedAt)
+	}
+	return snapshot
+}
+
+// summary strips the policy from a snapshot for listings.
+func summary(snapshot Snapshot) Snapshot {
+	snapshot.Policy = rbacpolicy.Policy{}
+	return snapshot
+}
@@ -0,0 +1,111 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+func testPolicy(roleID string) rbacpolicy.Policy {
+	return rbacpolicy.Policy{
+		CustomRoles: []rbacpolicy.Role{
+			{
+				RoleID:      roleID,
+				Description: "Test role",
+				Permissions: []rbacpolicy.Permission{
+					{ResourceID: "documents", Actions: []string{"read"}},
+				},
+			},
+		},
+		CustomResources: []rbacpolicy.Resource{
+			{ResourceID: "documents", AvailableActions: []string{"read"}},
+		},
+	}
+}
+
+// testStore exercises the Store contract shared by every implementation.
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	snapshots, err := store.List(ctx, "project-a")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(snapshots) != 0 {
+		t.Fatalf("Expected no snapshots, got %d", len(snapshots))
+	}
+
+	first, err := store.Save(ctx, Snapshot{ProjectID: "project-a", Reason: "PUT /rbacpolicy", Hash: "a", Policy: testPolicy("first")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first.Version == "" || first.CreatedAt.IsZero() {
+		t.Errorf("Expected version and created_at to be assigned, got %+v", first)
+	}
+	second, err := store.Save(ctx, Snapshot{ProjectID: "project-a", Reason: "DELETE /rbacpolicy", Hash: "b", Policy: testPolicy("second")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := store.Save(ctx, Snapshot{ProjectID: "project-b", Policy: testPolicy("other")}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	snapshots, err = store.List(ctx, "project-a")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("Expected two snapshots, got %d", len(snapshots))
+	}
+	if snapshots[0].Version != second.Version || snapshots[1].Version != first.Version {
+		t.Errorf("Expected newest first, got %q, %q", snapshots[0].Version, snapshots[1].Version)
+	}
+	if snapshots[0].Reason != "DELETE /rbacpolicy" || snapshots[0].Hash != "b" {
+		t.Errorf("Expected summary fields to be listed, got %+v", snapshots[0])
+	}
+	if snapshots[0].Policy.CustomRoles != nil {
+		t.Errorf("Expected listing without policies, got %+v", snapshots[0].Policy)
+	}
+
+	got, err := store.Get(ctx, "project-a", first.Version)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got.Policy, testPolicy("first")) {
+		t.Errorf("Expected stored policy, got %+v", got.Policy)
+	}
+	if !got.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("Expected created_at %v, got %v", first.CreatedAt, got.CreatedAt)
+	}
+
+	for _, tt := range []struct{ projectID, version string }{
+		{"project-a", "missing"},
+		{"project-b", first.Version},
+		{"project-a", ".."},
+		{"project-a", "../project-b"},
+	} {
+		if _, err := store.Get(ctx, tt.projectID, tt.version); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q, %q): expected ErrNotFound, got %v", tt.projectID, tt.version, err)
+		}
+	}
+}
+
+func TestNewVersionSortsChronologically(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	versions := []string{
+		newVersion(now),
+		newVersion(now),
+		newVersion(now.Add(time.Millisecond)),
+		newVersion(now.Add(time.Hour)),
+	}
+	for i := 1; i < len(versions); i++ {
+		if versions[i] <= versions[i-1] {
+			t.Errorf("Expected %q to sort after %q", versions[i], versions[i-1])
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package history
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+)
+
+// MemoryStore keeps snapshots in process memory. Snapshots survive only as
+// long as the Lambda container, so it is intended for tests and local runs.
+type MemoryStore struct {
+	mu        sync.RWMutex
+	snapshots map[string][]Snapshot
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		snapshots: make(map[string][]Snapshot),
+	}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, snapshot Snapshot) (Snapshot, error) {
+	snapshot = prepare(snapshot)
+	snapshot.Policy = rbac.Clone(snapshot.Policy)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[snapshot.ProjectID] = append(s.snapshots[snapshot.ProjectID], snapshot)
+	return snapshot, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, projectID string) ([]Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	out := make([]Snapshot, 0, len(s.snapshots[projectID]))
+	for _, snapshot := range s.snapshots[projectID] {
+		out = append(out, summary(snapshot))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version > out[j].Version })
+	return out, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, projectID, version string) (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, snapshot := range s.snapshots[projectID] {
+		if snapshot.Version == version {
+			snapshot.Policy = rbac.Clone(snapshot.Policy)
+			return &snapshot, nil
+		}
+	}
+	return nil, ErrNotFound
+}
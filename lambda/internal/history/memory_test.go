@@ -0,0 +1,36 @@
+package history
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStoreCopiesPolicies(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	policy := testPolicy("editor")
+	saved, err := store.Save(ctx, Snapshot{ProjectID: "project-a", Policy: policy})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	policy.CustomRoles[0].RoleID = "changed"
+
+	got, err := store.Get(ctx, "project-a", saved.Version)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	got.Policy.CustomRoles[0].Permissions[0].Actions[0] = "changed"
+
+	got, err = store.Get(ctx, "project-a", saved.Version)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got.Policy.CustomRoles[0].RoleID != "editor" || got.Policy.CustomRoles[0].Permissions[0].Actions[0] != "read" {
+		t.Errorf("Expected stored snapshot to be isolated from callers, got %+v", got.Policy)
+	}
+}
@@ -0,0 +1,141 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/storekey"
+)
+
+// S3API is the subset of the S3 client used by S3Store.
+type S3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+// S3Store keeps one JSON object per snapshot at <prefix>/<project_id>/<version>.json.
+type S3Store struct {
+	client S3API
+	bucket string
+	prefix string
+}
+
+func NewS3Store(client S3API, bucket, prefix string) *S3Store {
+	return &S3Store{
+		client: client,
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+func (s *S3Store) Save(ctx context.Context, snapshot Snapshot) (Snapshot, error) {
+	snapshot = prepare(snapshot)
+	if !storekey.Valid(snapshot.ProjectID) || !storekey.Valid(snapshot.Version) {
+		return Snapshot{}, fmt.Errorf("invalid snapshot key %q/%q", snapshot.ProjectID, snapshot.Version)
+	}
+
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.key(snapshot.ProjectID, snapshot.Version)),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to put snapshot: %w", err)
+	}
+
+	return snapshot, nil
+}
+
+// List reads every snapshot object for the project, so its cost grows with
+// the number of snapshots; pair the bucket with a lifecycle rule.
+func (s *S3Store) List(ctx context.Context, projectID string) ([]Snapshot, error) {
+	if !storekey.Valid(projectID) {
+		return []Snapshot{}, nil
+	}
+
+	out := []Snapshot{}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(s.key(projectID, "")),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list snapshots: %w", err)
+		}
+		for _, object := range page.Contents {
+			version, ok := strings.CutSuffix(path.Base(aws.ToString(object.Key)), ".json")
+			if !ok {
+				continue
+			}
+			snapshot, err := s.Get(ctx, projectID, version)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, summary(*snapshot))
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Version > out[j].Version })
+	return out, nil
+}
+
+func (s *S3Store) Get(ctx context.Context, projectID, version string) (*Snapshot, error) {
+	if !storekey.Valid(projectID) || !storekey.Valid(version) {
+		return nil, ErrNotFound
+	}
+
+	obj, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(projectID, version)),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get snapshot: %w", err)
+	}
+	defer obj.Body.Close()
+
+	body, err := io.ReadAll(obj.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(body, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// key returns the object key for a snapshot, or the project prefix when
+// version is empty.
+func (s *S3Store) key(projectID, version string) string {
+	key := projectID + "/"
+	if version != "" {
+		key += version + ".json"
+	}
+	if s.prefix != "" {
+		key = s.prefix + "/" + key
+	}
+	return key
+}
@@ -0,0 +1,129 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// fakeS3 is an in-memory S3API. pageSize limits ListObjectsV2 results so
+// pagination is exercised.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	pageSize int
+	err      error
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string][]byte), pageSize: 1}
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)] = body
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (f *fakeS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	body, ok := f.objects[aws.ToString(params.Bucket)+"/"+aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func (f *fakeS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucketPrefix := aws.ToString(params.Bucket) + "/"
+	var keys []string
+	for k := range f.objects {
+		key, ok := strings.CutPrefix(k, bucketPrefix)
+		if ok && strings.HasPrefix(key, aws.ToString(params.Prefix)) && key > aws.ToString(params.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectsV2Output{}
+	if len(keys) > f.pageSize {
+		keys = keys[:f.pageSize]
+		out.IsTruncated = aws.Bool(true)
+		out.NextContinuationToken = aws.String(keys[len(keys)-1])
+	}
+	for _, key := range keys {
+		out.Contents = append(out.Contents, types.Object{Key: aws.String(key)})
+	}
+	return out, nil
+}
+
+func TestS3Store(t *testing.T) {
+	testStore(t, NewS3Store(newFakeS3(), "snapshots", "rbacpolicy/history/"))
+}
+
+func TestS3StoreKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		expected string
+	}{
+		{name: "With prefix", prefix: "/history/", expected: "snapshots/history/project-a/v1.json"},
+		{name: "Without prefix", prefix: "", expected: "snapshots/project-a/v1.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newFakeS3()
+			store := NewS3Store(client, "snapshots", tt.prefix)
+			if _, err := store.Save(context.Background(), Snapshot{Version: "v1", ProjectID: "project-a"}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if _, ok := client.objects[tt.expected]; !ok {
+				t.Errorf("Expected object %q, got %v", tt.expected, client.objects)
+			}
+		})
+	}
+}
+
+func TestS3StoreErrors(t *testing.T) {
+	client := newFakeS3()
+	client.err = errors.New("access denied")
+	store := NewS3Store(client, "snapshots", "")
+
+	if _, err := store.Save(context.Background(), Snapshot{ProjectID: "project-a"}); err == nil {
+		t.Error("Expected Save error")
+	}
+	if _, err := store.List(context.Background(), "project-a"); err == nil {
+		t.Error("Expected List error")
+	}
+	if _, err := store.Get(context.Background(), "project-a", "v1"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected non-ErrNotFound Get error, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package rbac
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// Canonicalize returns a deep copy of p with every list sorted and nil lists
+// replaced by empty ones, so two policies that differ only in ordering or in
+// null-versus-empty encoding compare and hash identically.
+func Canonicalize(p rbacpolicy.Policy) rbacpolicy.Policy {
+	out := rbacpolicy.Policy{
+		StytchMember:    canonicalRole(p.StytchMember),
+		StytchAdmin:     canonicalRole(p.StytchAdmin),
+		StytchResources: canonicalResources(p.StytchResources),
+		CustomRoles:     make([]rbacpolicy.Role, 0, len(p.CustomRoles)),
+		CustomResources: canonicalResources(p.CustomResources),
+	}
+	for _, role := range p.CustomRoles {
+		out.CustomRoles = append(out.CustomRoles, canonicalRole(role))
+	}
+	slices.SortFunc(out.CustomRoles, func(a, b rbacpolicy.Role) int {
+		return strings.Compare(a.RoleID, b.RoleID)
+	})
+	return out
+}
+
+// Hash returns a hex-encoded SHA-256 of the canonical JSON form of p.
+func Hash(p rbacpolicy.Policy) string {
+	// Marshalling plain structs of strings and slices cannot fail.
+	b, _ := json.Marshal(Canonicalize(p))
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func canonicalRole(r rbacpolicy.Role) rbacpolicy.Role {
+	out := rbacpolicy.Role{
+		RoleID:      r.RoleID,
+		Description: r.Description,
+		Permissions: make([]rbacpolicy.Permission, 0, len(r.Permissions)),
+	}
+	for _, perm := range r.Permissions {
+		out.Permissions = append(out.Permissions, rbacpolicy.Permission{
+			ResourceID: perm.ResourceID,
+			Actions:    sortedStrings(perm.Actions),
+		})
+	}
+	// A role may hold several permissions on one resource; ties are broken
+	// by their sorted actions so the order they were listed in cannot leak
+	// into the hash.
+	slices.SortFunc(out.Permissions, func(a, b rbacpolicy.Permission) int {
+		if c := strings.Compare(a.ResourceID, b.ResourceID); c != 0 {
+			return c
+		}
+		return slices.Compare(a.Actions, b.Actions)
+	})
+	return out
+}
+
+func canonicalResources(resources []rbacpolicy.Resource) []rbacpolicy.Resource {
+	out := make([]rbacpolicy.Resource, 0, len(resources))
+	for _, res := range resources {
+		out = append(out, rbacpolicy.Resource{
+			ResourceID:       res.ResourceID,
+			Description:      res.Description,
+			AvailableActions: sortedStrings(res.AvailableActions),
+		})
+	}
+	slices.SortFunc(out, func(a, b rbacpolicy.Resource) int {
+		return strings.Compare(a.ResourceID, b.ResourceID)
+	})
+	return out
+}
+
+func sortedStrings(values []string) []string {
+	out := make([]string, len(values))
+	copy(out, values)
+	slices.Sort(out)
+	return out
+}
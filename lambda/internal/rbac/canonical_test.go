@@ -0,0 +1,104 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+func testPolicy() rbacpolicy.Policy {
+	return rbacpolicy.Policy{
+		StytchMember: rbacpolicy.Role{RoleID: "stytch_member"},
+		CustomRoles: []rbacpolicy.Role{
+			{
+				RoleID: "viewer",
+				Permissions: []rbacpolicy.Permission{
+					{ResourceID: "reports", Actions: []string{"read"}},
+					{ResourceID: "documents", Actions: []string{"read"}},
+				},
+			},
+			{
+				RoleID: "editor",
+				Permissions: []rbacpolicy.Permission{
+					{ResourceID: "documents", Actions: []string{"write", "read"}},
+				},
+			},
+		},
+		CustomResources: []rbacpolicy.Resource{
+			{ResourceID: "reports", AvailableActions: []string{"read"}},
+			{ResourceID: "documents", AvailableActions: []string{"write", "read", "delete"}},
+		},
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	original := testPolicy()
+	got := Canonicalize(original)
+
+	if got.CustomRoles[0].RoleID != "editor" || got.CustomRoles[1].RoleID != "viewer" {
+		t.Errorf("Expected roles sorted by ID, got %q, %q", got.CustomRoles[0].RoleID, got.CustomRoles[1].RoleID)
+	}
+	if got.CustomRoles[1].Permissions[0].ResourceID != "documents" {
+		t.Errorf("Expected permissions sorted by resource ID, got %v", got.CustomRoles[1].Permissions)
+	}
+	if actions := got.CustomResources[0].AvailableActions; actions[0] != "delete" || actions[2] != "write" {
+		t.Errorf("Expected actions sorted, got %v", actions)
+	}
+	if got.StytchResources == nil || got.StytchMember.Permissions == nil {
+		t.Errorf("Expected nil lists to become empty lists")
+	}
+
+	if original.CustomRoles[0].RoleID != "viewer" || original.CustomResources[1].AvailableActions[0] != "write" {
+		t.Errorf("Canonicalize modified its input")
+	}
+}
+
+func TestHash(t *testing.T) {
+	base := testPolicy()
+
+	reordered := testPolicy()
+	reordered.CustomRoles[0], reordered.CustomRoles[1] = reordered.CustomRoles[1], reordered.CustomRoles[0]
+	reordered.CustomResources[1].AvailableActions = []string{"delete", "read", "write"}
+	reordered.StytchResources = []rbacpolicy.Resource{}
+
+	changed := testPolicy()
+	changed.CustomRoles[1].Permissions[0].Actions = []string{"read"}
+
+	split := testPolicy()
+	split.CustomRoles[1].Permissions = []rbacpolicy.Permission{
+		{ResourceID: "documents", Actions: []string{"read"}},
+		{ResourceID: "documents", Actions: []string{"write"}},
+	}
+	splitReordered := testPolicy()
+	splitReordered.CustomRoles[1].Permissions = []rbacpolicy.Permission{
+		{ResourceID: "documents", Actions: []string{"write"}},
+		{ResourceID: "documents", Actions: []string{"read"}},
+	}
+
+	tests := []struct {
+		name      string
+		other     rbacpolicy.Policy
+		wantEqual bool
+	}{
+		{name: "Identical policy", other: testPolicy(), wantEqual: true},
+		{name: "Reordered policy", other: reordered, wantEqual: true},
+		{name: "Changed actions", other: changed, wantEqual: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			equal := Hash(base) == Hash(tt.other)
+			if equal != tt.wantEqual {
+				t.Errorf("Hash equality = %v, want %v", equal, tt.wantEqual)
+			}
+		})
+	}
+
+	if Hash(split) != Hash(splitReordered) {
+		t.Errorf("Expected permissions on the same resource to hash identically in any order")
+	}
+
+	if len(Hash(base)) != 64 {
+		t.Errorf("Expected 64 hex characters, got %q", Hash(base))
+	}
+}
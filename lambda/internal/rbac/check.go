@@ -0,0 +1,57 @@
+package rbac
+
+import (
+	"slices"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// WildcardAction in a permission grants every action on its resource.
+const WildcardAction = "*"
+
+// Decision is the result of an authorization check. When Allowed is true,
+// RoleID and Permission identify the first grant that allowed the action.
+type Decision struct {
+	Allowed    bool                   `json:"allowed"`
+	RoleID     string                 `json:"role_id,omitempty"`
+	Permission *rbacpolicy.Permission `json:"permission,omitempty"`
+}
+
+// Roles returns every role in the policy: the Stytch default roles followed by
+// the custom roles.
+func Roles(p rbacpolicy.Policy) []rbacpolicy.Role {
+	roles := make([]rbacpolicy.Role, 0, len(p.CustomRoles)+2)
+	roles = append(roles, p.StytchMember, p.StytchAdmin)
+	return append(roles, p.CustomRoles...)
+}
+
+// FindRole looks up a role by ID among the Stytch default and custom roles.
+func FindRole(p rbacpolicy.Policy, roleID string) (rbacpolicy.Role, bool) {
+	for _, role := range Roles(p) {
+		if role.RoleID == roleID {
+			return role, true
+		}
+	}
+	return rbacpolicy.Role{}, false
+}
+
+// Check reports whether any of roleIDs may perform action on resourceID.
+// Roles are evaluated in the order given; unknown role IDs grant nothing.
+func Check(p rbacpolicy.Policy, roleIDs []string, resourceID, action string) Decision {
+	for _, roleID := range roleIDs {
+		role, ok := FindRole(p, roleID)
+		if !ok {
+			continue
+		}
+		for _, perm := range role.Permissions {
+			if perm.ResourceID != resourceID {
+				continue
+			}
+			if slices.Contains(perm.Actions, action) || slices.Contains(perm.Actions, WildcardAction) {
+				granted := perm
+				return Decision{Allowed: true, RoleID: role.RoleID, Permission: &granted}
+			}
+		}
+	}
+	return Decision{Allowed: false}
+}
@@ -0,0 +1,109 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+func TestCheck(t *testing.T) {
+	policy := testPolicy()
+	policy.StytchAdmin = rbacpolicy.Role{
+		RoleID: "stytch_admin",
+		Permissions: []rbacpolicy.Permission{
+			{ResourceID: "documents", Actions: []string{"*"}},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		roles        []string
+		resourceID   string
+		action       string
+		wantAllowed  bool
+		wantRoleID   string
+		wantResource string
+	}{
+		{
+			name:         "Custom role grants action",
+			roles:        []string{"editor"},
+			resourceID:   "documents",
+			action:       "write",
+			wantAllowed:  true,
+			wantRoleID:   "editor",
+			wantResource: "documents",
+		},
+		{
+			name:        "Custom role lacks action",
+			roles:       []string{"viewer"},
+			resourceID:  "documents",
+			action:      "write",
+			wantAllowed: false,
+		},
+		{
+			name:         "Wildcard on Stytch admin",
+			roles:        []string{"viewer", "stytch_admin"},
+			resourceID:   "documents",
+			action:       "delete",
+			wantAllowed:  true,
+			wantRoleID:   "stytch_admin",
+			wantResource: "documents",
+		},
+		{
+			name:         "First granting role wins",
+			roles:        []string{"viewer", "editor"},
+			resourceID:   "documents",
+			action:       "read",
+			wantAllowed:  true,
+			wantRoleID:   "viewer",
+			wantResource: "documents",
+		},
+		{
+			name:        "Unknown role",
+			roles:       []string{"ghost"},
+			resourceID:  "documents",
+			action:      "read",
+			wantAllowed: false,
+		},
+		{
+			name:        "Wrong resource",
+			roles:       []string{"editor"},
+			resourceID:  "reports",
+			action:      "read",
+			wantAllowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Check(policy, tt.roles, tt.resourceID, tt.action)
+
+			if got.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v", got.Allowed, tt.wantAllowed)
+			}
+			if got.RoleID != tt.wantRoleID {
+				t.Errorf("RoleID = %q, want %q", got.RoleID, tt.wantRoleID)
+			}
+			if tt.wantAllowed && (got.Permission == nil || got.Permission.ResourceID != tt.wantResource) {
+				t.Errorf("Permission = %+v, want resource %q", got.Permission, tt.wantResource)
+			}
+			if !tt.wantAllowed && got.Permission != nil {
+				t.Errorf("Expected no permission on denial, got %+v", got.Permission)
+			}
+		})
+	}
+}
+
+func TestFindRole(t *testing.T) {
+	policy := testPolicy()
+
+	if role, ok := FindRole(policy, "stytch_member"); !ok || role.RoleID != "stytch_member" {
+		t.Errorf("Expected to find stytch_member, got %+v, %v", role, ok)
+	}
+	if role, ok := FindRole(policy, "editor"); !ok || role.RoleID != "editor" {
+		t.Errorf("Expected to find editor, got %+v, %v", role, ok)
+	}
+	if _, ok := FindRole(policy, "ghost"); ok {
+		t.Errorf("Expected unknown role not to be found")
+	}
+}
@@ -0,0 +1,56 @@
+package rbac
+
+import (
+	"slices"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// Clone returns a deep copy of p that shares no slices with it, preserving
+// the original ordering.
+func Clone(p rbacpolicy.Policy) rbacpolicy.Policy {
+	return rbacpolicy.Policy{
+		StytchMember:    cloneRole(p.StytchMember),
+		StytchAdmin:     cloneRole(p.StytchAdmin),
+		StytchResources: cloneResources(p.StytchResources),
+		CustomRoles:     cloneRoles(p.CustomRoles),
+		CustomResources: cloneResources(p.CustomResources),
+	}
+}
+
+func cloneRoles(roles []rbacpolicy.Role) []rbacpolicy.Role {
+	if roles == nil {
+		return nil
+	}
+	out := make([]rbacpolicy.Role, len(roles))
+	for i, role := range roles {
+		out[i] = cloneRole(role)
+	}
+	return out
+}
+
+func cloneRole(r rbacpolicy.Role) rbacpolicy.Role {
+	out := r
+	if r.Permissions != nil {
+		out.Permissions = make([]rbacpolicy.Permission, len(r.Permissions))
+		for i, perm := range r.Permissions {
+			out.Permissions[i] = rbacpolicy.Permission{
+				ResourceID: perm.ResourceID,
+				Actions:    slices.Clone(perm.Actions),
+			}
+		}
+	}
+	return out
+}
+
+func cloneResources(resources []rbacpolicy.Resource) []rbacpolicy.Resource {
+	if resources == nil {
+		return nil
+	}
+	out := make([]rbacpolicy.Resource, len(resources))
+	for i, res := range resources {
+		out[i] = res
+		out[i].AvailableActions = slices.Clone(res.AvailableActions)
+	}
+	return out
+}
@@ -0,0 +1,29 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	original := testPolicy()
+	clone := Clone(original)
+
+	if !reflect.DeepEqual(original, clone) {
+		t.Fatalf("Clone() = %+v, want %+v", clone, original)
+	}
+
+	clone.CustomRoles[0].RoleID = "changed"
+	clone.CustomRoles[0].Permissions[0].Actions[0] = "changed"
+	clone.CustomResources[0].AvailableActions[0] = "changed"
+
+	if original.CustomRoles[0].RoleID != "viewer" ||
+		original.CustomRoles[0].Permissions[0].Actions[0] != "read" ||
+		original.CustomResources[0].AvailableActions[0] != "read" {
+		t.Errorf("Modifying the clone changed the original: %+v", original)
+	}
+
+	if clone.StytchResources != nil {
+		t.Errorf("Expected nil lists to stay nil, got %v", clone.StytchResources)
+	}
+}
@@ -0,0 +1,214 @@
+package rbac
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// Diff is a semantic, order-insensitive comparison of two policies. Reordering
+// roles, resources, permissions or actions never produces a change.
+type Diff struct {
+	RolesAdded       []string         `json:"roles_added"`
+	RolesRemoved     []string         `json:"roles_removed"`
+	RoleChanges      []RoleChange     `json:"role_changes"`
+	ResourcesAdded   []string         `json:"resources_added"`
+	ResourcesRemoved []string         `json:"resources_removed"`
+	ResourceChanges  []ResourceChange `json:"resource_changes"`
+}
+
+// RoleChange describes how a role present in both policies changed. The
+// Stytch default roles are reported here under their role IDs.
+type RoleChange struct {
+	RoleID             string             `json:"role_id"`
+	Description        *DescriptionChange `json:"description,omitempty"`
+	PermissionsAdded   []Grant            `json:"permissions_added,omitempty"`
+	PermissionsRemoved []Grant            `json:"permissions_removed,omitempty"`
+}
+
+// ResourceChange describes how a resource present in both policies changed.
+type ResourceChange struct {
+	ResourceID     string             `json:"resource_id"`
+	Description    *DescriptionChange `json:"description,omitempty"`
+	ActionsAdded   []string           `json:"actions_added,omitempty"`
+	ActionsRemoved []string           `json:"actions_removed,omitempty"`
+}
+
+// DescriptionChange records an old and new description.
+type DescriptionChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Grant is a single action on a single resource.
+type Grant struct {
+	ResourceID string `json:"resource_id"`
+	Action     string `json:"action"`
+}
+
+// Empty reports whether the diff contains no changes.
+func (d Diff) Empty() bool {
+	return len(d.RolesAdded) == 0 && len(d.RolesRemoved) == 0 && len(d.RoleChanges) == 0 &&
+		len(d.ResourcesAdded) == 0 && len(d.ResourcesRemoved) == 0 && len(d.ResourceChanges) == 0
+}
+
+// Compare returns the changes needed to turn before into after. Stytch
+// resources are ignored since they cannot be changed through the policy.
+func Compare(before, after rbacpolicy.Policy) Diff {
+	d := Diff{
+		RolesAdded:       []string{},
+		RolesRemoved:     []string{},
+		RoleChanges:      []RoleChange{},
+		ResourcesAdded:   []string{},
+		ResourcesRemoved: []string{},
+		ResourceChanges:  []ResourceChange{},
+	}
+
+	for _, pair := range [][2]rbacpolicy.Role{
+		{before.StytchMember, after.StytchMember},
+		{before.StytchAdmin, after.StytchAdmin},
+	} {
+		roleID := pair[1].RoleID
+		if roleID == "" {
+			roleID = pair[0].RoleID
+		}
+		if change, ok := compareRole(roleID, pair[0], pair[1]); ok {
+			d.RoleChanges = append(d.RoleChanges, change)
+		}
+	}
+
+	beforeRoles := rolesByID(before.CustomRoles)
+	afterRoles := rolesByID(after.CustomRoles)
+	for _, id := range sortedKeys(afterRoles) {
+		old, ok := beforeRoles[id]
+		if !ok {
+			d.RolesAdded = append(d.RolesAdded, id)
+			continue
+		}
+		if change, ok := compareRole(id, old, afterRoles[id]); ok {
+			d.RoleChanges = append(d.RoleChanges, change)
+		}
+	}
+	for _, id := range sortedKeys(beforeRoles) {
+		if _, ok := afterRoles[id]; !ok {
+			d.RolesRemoved = append(d.RolesRemoved, id)
+		}
+	}
+
+	beforeResources := resourcesByID(before.CustomResources)
+	afterResources := resourcesByID(after.CustomResources)
+	for _, id := range sortedKeys(afterResources) {
+		old, ok := beforeResources[id]
+		if !ok {
+			d.ResourcesAdded = append(d.ResourcesAdded, id)
+			continue
+		}
+		if change, ok := compareResource(id, old, afterResources[id]); ok {
+			d.ResourceChanges = append(d.ResourceChanges, change)
+		}
+	}
+	for _, id := range sortedKeys(beforeResources) {
+		if _, ok := afterResources[id]; !ok {
+			d.ResourcesRemoved = append(d.ResourcesRemoved, id)
+		}
+	}
+
+	return d
+}
+
+func compareRole(roleID string, before, after rbacpolicy.Role) (RoleChange, bool) {
+	change := RoleChange{RoleID: roleID}
+	if before.Description != after.Description {
+		change.Description = &DescriptionChange{From: before.Description, To: after.Description}
+	}
+
+	beforeGrants := Grants(before)
+	afterGrants := Grants(after)
+	for _, g := range afterGrants {
+		if !slices.Contains(beforeGrants, g) {
+			change.PermissionsAdded = append(change.PermissionsAdded, g)
+		}
+	}
+	for _, g := range beforeGrants {
+		if !slices.Contains(afterGrants, g) {
+			change.PermissionsRemoved = append(change.PermissionsRemoved, g)
+		}
+	}
+
+	changed := change.Description != nil || len(change.PermissionsAdded) > 0 || len(change.PermissionsRemoved) > 0
+	return change, changed
+}
+
+func compareResource(resourceID string, before, after rbacpolicy.Resource) (ResourceChange, bool) {
+	change := ResourceChange{ResourceID: resourceID}
+	if before.Description != after.Description {
+		change.Description = &DescriptionChange{From: before.Description, To: after.Description}
+	}
+
+	beforeActions := uniqueSorted(before.AvailableActions)
+	afterActions := uniqueSorted(after.AvailableActions)
+	for _, a := range afterActions {
+		if !slices.Contains(beforeActions, a) {
+			change.ActionsAdded = append(change.ActionsAdded, a)
+		}
+	}
+	for _, a := range beforeActions {
+		if !slices.Contains(afterActions, a) {
+			change.ActionsRemoved = append(change.ActionsRemoved, a)
+		}
+	}
+
+	changed := change.Description != nil || len(change.ActionsAdded) > 0 || len(change.ActionsRemoved) > 0
+	return change, changed
+}
+
+// Grants flattens a role's permissions into a sorted, de-duplicated list of
+// resource/action pairs.
+func Grants(role rbacpolicy.Role) []Grant {
+	var grants []Grant
+	for _, perm := range role.Permissions {
+		for _, action := range perm.Actions {
+			g := Grant{ResourceID: perm.ResourceID, Action: action}
+			if !slices.Contains(grants, g) {
+				grants = append(grants, g)
+			}
+		}
+	}
+	slices.SortFunc(grants, func(a, b Grant) int {
+		if c := strings.Compare(a.ResourceID, b.ResourceID); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Action, b.Action)
+	})
+	return grants
+}
+
+func rolesByID(roles []rbacpolicy.Role) map[string]rbacpolicy.Role {
+	m := make(map[string]rbacpolicy.Role, len(roles))
+	for _, role := range roles {
+		m[role.RoleID] = role
+	}
+	return m
+}
+
+func resourcesByID(resources []rbacpolicy.Resource) map[string]rbacpolicy.Resource {
+	m := make(map[string]rbacpolicy.Resource, len(resources))
+	for _, res := range resources {
+		m[res.ResourceID] = res
+	}
+	return m
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func uniqueSorted(values []string) []string {
+	return slices.Compact(sortedStrings(values))
+}
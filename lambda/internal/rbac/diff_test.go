@@ -0,0 +1,132 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(p *rbacpolicy.Policy)
+		want   Diff
+	}{
+		{
+			name: "Reordering is not a change",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomRoles[0], p.CustomRoles[1] = p.CustomRoles[1], p.CustomRoles[0]
+				p.CustomRoles[0].Permissions[0].Actions = []string{"read", "write", "read"}
+				p.CustomResources[1].AvailableActions = []string{"delete", "read", "write"}
+			},
+			want: Diff{},
+		},
+		{
+			name: "Roles and resources added and removed",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomRoles = append(p.CustomRoles[:1], rbacpolicy.Role{RoleID: "auditor"})
+				p.CustomResources = append(p.CustomResources[1:], rbacpolicy.Resource{ResourceID: "invoices"})
+			},
+			want: Diff{
+				RolesAdded:       []string{"auditor"},
+				RolesRemoved:     []string{"editor"},
+				ResourcesAdded:   []string{"invoices"},
+				ResourcesRemoved: []string{"reports"},
+			},
+		},
+		{
+			name: "Permission and description changes",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomRoles[1].Description = "Edits documents"
+				p.CustomRoles[1].Permissions = []rbacpolicy.Permission{
+					{ResourceID: "documents", Actions: []string{"read", "delete"}},
+				}
+				p.CustomResources[1].AvailableActions = []string{"read", "write", "archive"}
+				p.StytchMember.Permissions = []rbacpolicy.Permission{
+					{ResourceID: "reports", Actions: []string{"read"}},
+				}
+			},
+			want: Diff{
+				RoleChanges: []RoleChange{
+					{
+						RoleID:           "stytch_member",
+						PermissionsAdded: []Grant{{ResourceID: "reports", Action: "read"}},
+					},
+					{
+						RoleID:             "editor",
+						Description:        &DescriptionChange{From: "", To: "Edits documents"},
+						PermissionsAdded:   []Grant{{ResourceID: "documents", Action: "delete"}},
+						PermissionsRemoved: []Grant{{ResourceID: "documents", Action: "write"}},
+					},
+				},
+				ResourceChanges: []ResourceChange{
+					{
+						ResourceID:     "documents",
+						ActionsAdded:   []string{"archive"},
+						ActionsRemoved: []string{"delete"},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			after := Clone(testPolicy())
+			tt.mutate(&after)
+
+			got := Compare(testPolicy(), after)
+
+			want := normalizeDiff(tt.want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Compare() = %+v, want %+v", got, want)
+			}
+			if got.Empty() != tt.want.Empty() {
+				t.Errorf("Empty() = %v, want %v", got.Empty(), tt.want.Empty())
+			}
+		})
+	}
+}
+
+func TestGrants(t *testing.T) {
+	role := rbacpolicy.Role{
+		Permissions: []rbacpolicy.Permission{
+			{ResourceID: "reports", Actions: []string{"read"}},
+			{ResourceID: "documents", Actions: []string{"write", "read"}},
+			{ResourceID: "reports", Actions: []string{"read"}},
+		},
+	}
+
+	want := []Grant{
+		{ResourceID: "documents", Action: "read"},
+		{ResourceID: "documents", Action: "write"},
+		{ResourceID: "reports", Action: "read"},
+	}
+	if got := Grants(role); !reflect.DeepEqual(got, want) {
+		t.Errorf("Grants() = %v, want %v", got, want)
+	}
+}
+
+// normalizeDiff fills nil lists with empty ones to match Compare's output.
+func normalizeDiff(d Diff) Diff {
+	if d.RolesAdded == nil {
+		d.RolesAdded = []string{}
+	}
+	if d.RolesRemoved == nil {
+		d.RolesRemoved = []string{}
+	}
+	if d.RoleChanges == nil {
+		d.RoleChanges = []RoleChange{}
+	}
+	if d.ResourcesAdded == nil {
+		d.ResourcesAdded = []string{}
+	}
+	if d.ResourcesRemoved == nil {
+		d.ResourcesRemoved = []string{}
+	}
+	if d.ResourceChanges == nil {
+		d.ResourceChanges = []ResourceChange{}
+	}
+	return d
+}
@@ -0,0 +1,57 @@
+package rbac
+
+import (
+	"slices"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// FindResource looks up a resource by ID among the custom and Stytch
+// resources.
+func FindResource(p rbacpolicy.Policy, resourceID string) (rbacpolicy.Resource, bool) {
+	for _, resources := range [][]rbacpolicy.Resource{p.CustomResources, p.StytchResources} {
+		for _, res := range resources {
+			if res.ResourceID == resourceID {
+				return res, true
+			}
+		}
+	}
+	return rbacpolicy.Resource{}, false
+}
+
+// EffectivePermissions flattens a role's permissions into a map of resource
+// ID to the sorted, de-duplicated actions it may perform. A "*" action is
+// expanded to the resource's available actions when the resource is known.
+func EffectivePermissions(p rbacpolicy.Policy, role rbacpolicy.Role) map[string][]string {
+	effective := make(map[string][]string)
+	for _, perm := range role.Permissions {
+		effective[perm.ResourceID] = append(effective[perm.ResourceID], expandActions(p, perm)...)
+	}
+	for resourceID, actions := range effective {
+		effective[resourceID] = uniqueSorted(actions)
+	}
+	return effective
+}
+
+// ResourceGrants returns, for every role holding a permission on resourceID,
+// the sorted actions it may perform there, keyed by role ID.
+func ResourceGrants(p rbacpolicy.Policy, resourceID string) map[string][]string {
+	grants := make(map[string][]string)
+	for _, role := range Roles(p) {
+		if actions, ok := EffectivePermissions(p, role)[resourceID]; ok && len(actions) > 0 {
+			grants[role.RoleID] = actions
+		}
+	}
+	return grants
+}
+
+func expandActions(p rbacpolicy.Policy, perm rbacpolicy.Permission) []string {
+	if !slices.Contains(perm.Actions, WildcardAction) {
+		return perm.Actions
+	}
+	res, ok := FindResource(p, perm.ResourceID)
+	if !ok {
+		return perm.Actions
+	}
+	return res.AvailableActions
+}
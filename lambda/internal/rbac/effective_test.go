@@ -0,0 +1,98 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+func effectivePolicy() rbacpolicy.Policy {
+	policy := testPolicy()
+	policy.StytchAdmin = rbacpolicy.Role{
+		RoleID: "stytch_admin",
+		Permissions: []rbacpolicy.Permission{
+			{ResourceID: "documents", Actions: []string{"*"}},
+			{ResourceID: "stytch.self", Actions: []string{"*"}},
+		},
+	}
+	policy.StytchResources = []rbacpolicy.Resource{
+		{ResourceID: "stytch.self", AvailableActions: []string{"update", "read"}},
+	}
+	policy.CustomRoles[0].Permissions = append(policy.CustomRoles[0].Permissions,
+		rbacpolicy.Permission{ResourceID: "reports", Actions: []string{"read", "read"}},
+		rbacpolicy.Permission{ResourceID: "archive", Actions: []string{"*"}},
+	)
+	return policy
+}
+
+func TestEffectivePermissions(t *testing.T) {
+	policy := effectivePolicy()
+
+	tests := []struct {
+		name string
+		role rbacpolicy.Role
+		want map[string][]string
+	}{
+		{
+			name: "Merges duplicate permissions and keeps unknown wildcard",
+			role: policy.CustomRoles[0],
+			want: map[string][]string{
+				"reports":   {"read"},
+				"documents": {"read"},
+				"archive":   {"*"},
+			},
+		},
+		{
+			name: "Expands wildcards on custom and Stytch resources",
+			role: policy.StytchAdmin,
+			want: map[string][]string{
+				"documents":   {"delete", "read", "write"},
+				"stytch.self": {"read", "update"},
+			},
+		},
+		{
+			name: "Role without permissions",
+			role: policy.StytchMember,
+			want: map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EffectivePermissions(policy, tt.role); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EffectivePermissions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceGrants(t *testing.T) {
+	policy := effectivePolicy()
+
+	want := map[string][]string{
+		"stytch_admin": {"delete", "read", "write"},
+		"viewer":       {"read"},
+		"editor":       {"read", "write"},
+	}
+	if got := ResourceGrants(policy, "documents"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ResourceGrants() = %v, want %v", got, want)
+	}
+
+	if got := ResourceGrants(policy, "invoices"); len(got) != 0 {
+		t.Errorf("Expected no grants on unknown resource, got %v", got)
+	}
+}
+
+func TestFindResource(t *testing.T) {
+	policy := effectivePolicy()
+
+	for _, id := range []string{"documents", "stytch.self"} {
+		if res, ok := FindResource(policy, id); !ok || res.ResourceID != id {
+			t.Errorf("Expected to find %q, got %+v, %v", id, res, ok)
+		}
+	}
+	if _, ok := FindResource(policy, "invoices"); ok {
+		t.Errorf("Expected unknown resource not to be found")
+	}
+}
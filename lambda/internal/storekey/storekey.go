@@ -0,0 +1,11 @@
+// Package storekey checks names used to build file paths and S3 object keys.
+package storekey
+
+import "strings"
+
+// Valid reports whether s can be used as a path or object key component.
+// Project IDs and versions arrive from request paths, so this guards against
+// traversal.
+func Valid(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
@@ -0,0 +1,24 @@
+package storekey
+
+import "testing"
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{"20240102T030405.000000000Z-0001", true},
+		{"project-live-1234", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"a/b", false},
+		{`a\b`, false},
+	}
+
+	for _, tt := range tests {
+		if got := Valid(tt.key); got != tt.expected {
+			t.Errorf("Valid(%q) = %v, expected %v", tt.key, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package stytchclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+// BreakerState is the state of a Breaker.
+type BreakerState string
+
+const (
+	// BreakerClosed passes calls through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen fails calls immediately until the cooldown has passed.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single trial call through to test recovery.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// ErrBreakerOpen is wrapped by OpenError.
+var ErrBreakerOpen = errors.New("circuit breaker open")
+
+// OpenError is returned without calling Stytch while the breaker is open.
+type OpenError struct {
+	// RetryAfter is how long until the breaker will let a call through.
+	RetryAfter time.Duration
+}
+
+func (e *OpenError) Error() string {
+	return fmt.Sprintf("%v: Stytch calls suspended for %s", ErrBreakerOpen, e.RetryAfter)
+}
+
+func (e *OpenError) Unwrap() error {
+	return ErrBreakerOpen
+}
+
+// BreakerStatus is a snapshot of a Breaker for health checks.
+type BreakerStatus struct {
+	State BreakerState `json:"state"`
+	// ConsecutiveFailures counts transient failures since the last success.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// RetryAfterSeconds is set while the breaker is open.
+	RetryAfterSeconds int `json:"retry_after_seconds,omitempty"`
+}
+
+// Breaker stops calling Stytch after repeated transient failures, so that
+// during an outage requests fail fast instead of each waiting out its
+// timeout. After the cooldown one trial call is let through; its success
+// closes the breaker and its failure reopens it. Client errors such as 400
+// and 404 do not count as failures; deadline overruns do. Failures are
+// classified with the status code Transport recorded, so error pages that are
+// not Stytch JSON, such as a 502 from a proxy, still count.
+type Breaker struct {
+	next      Client
+	logger    *zap.Logger
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// BreakerOption configures a Breaker.
+type BreakerOption func(*Breaker)
+
+// WithFailureThreshold sets how many consecutive failures open the breaker.
+func WithFailureThreshold(n int) BreakerOption {
+	return func(b *Breaker) {
+		b.threshold = n
+	}
+}
+
+// WithCooldown sets how long the breaker stays open before a trial call.
+func WithCooldown(d time.Duration) BreakerOption {
+	return func(b *Breaker) {
+		b.cooldown = d
+	}
+}
+
+func NewBreaker(next Client, logger *zap.Logger, opts ...BreakerOption) *Breaker {
+	b := &Breaker{
+		next:      next,
+		logger:    logger,
+		threshold: 5,
+		cooldown:  30 * time.Second,
+		now:       time.Now,
+		state:     BreakerClosed,
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *Breaker) Get(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	ctx, response := withResponse(ctx)
+	resp, err := b.next.Get(ctx, body)
+	err = withStatus(err, response)
+	b.record(err, response)
+	return resp, err
+}
+
+func (b *Breaker) Set(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+	if err := b.allow(); err != nil {
+		return nil, err
+	}
+	ctx, response := withResponse(ctx)
+	resp, err := b.next.Set(ctx, body)
+	err = withStatus(err, response)
+	b.record(err, response)
+	return resp, err
+}
+
+// Status reports the breaker's current state.
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state == BreakerOpen {
+		remaining := b.remaining()
+		if remaining <= 0 {
+			status.State = BreakerHalfOpen
+		} else {
+			status.RetryAfterSeconds = int((remaining + time.Second - 1) / time.Second)
+		}
+	}
+	return status
+}
+
+// allow decides whether a call may go through.
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if remaining := b.remaining(); remaining > 0 {
+			return &OpenError{RetryAfter: remaining}
+		}
+		b.transition(BreakerHalfOpen)
+		b.probing = true
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return &OpenError{RetryAfter: time.Second}
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of a call.
+func (b *Breaker) record(err error, response *response) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	// A call that ran out of time is the typical symptom of an outage, so
+	// unlike in the retrier it counts against Stytch.
+	statusCode, _ := response.get()
+	failed := errors.Is(err, context.DeadlineExceeded) || (err != nil && classify(err, statusCode).transient)
+	if !failed {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.transition(BreakerClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.transition(BreakerOpen)
+	}
+}
+
+func (b *Breaker) remaining() time.Duration {
+	return b.openedAt.Add(b.cooldown).Sub(b.now())
+}
+
+func (b *Breaker) transition(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	b.logger.Warn("Circuit breaker state changed",
+		zap.String("from", string(b.state)),
+		zap.String("to", string(state)),
+		zap.Int("consecutive_failures", b.failures),
+	)
+	b.state = state
+}
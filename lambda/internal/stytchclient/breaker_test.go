@@ -0,0 +1,210 @@
+package stytchclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/api"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// switchClient fails with err while it is set.
+type switchClient struct {
+	err   error
+	calls int
+}
+
+func (c *switchClient) Get(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &rbacpolicy.GetResponse{StatusCode: 200}, nil
+}
+
+func (c *switchClient) Set(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &rbacpolicy.SetResponse{StatusCode: 200}, nil
+}
+
+// fakeClock is a settable time source.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestBreaker(next Client, logger *zap.Logger) (*Breaker, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	b := NewBreaker(next, logger, WithFailureThreshold(3), WithCooldown(10*time.Second))
+	b.now = clock.now
+	return b, clock
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	client := &switchClient{err: errUnavailable}
+	b, _ := newTestBreaker(client, zap.NewNop())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.Get(ctx, rbacpolicy.GetRequest{}); !errors.Is(err, errUnavailable) {
+			t.Fatalf("Call %d: expected Stytch error, got %v", i, err)
+		}
+	}
+	if status := b.Status(); status.State != BreakerOpen || status.RetryAfterSeconds != 10 {
+		t.Fatalf("Expected open breaker with 10s retry, got %+v", status)
+	}
+
+	_, err := b.Set(ctx, rbacpolicy.SetRequest{})
+	var openErr *OpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("Expected OpenError, got %v", err)
+	}
+	if openErr.RetryAfter != 10*time.Second {
+		t.Errorf("Expected 10s retry after, got %v", openErr.RetryAfter)
+	}
+	if client.calls != 3 {
+		t.Errorf("Expected open breaker not to call Stytch, got %d calls", client.calls)
+	}
+}
+
+func TestBreakerIgnoresClientErrors(t *testing.T) {
+	client := &switchClient{err: errBadRequest}
+	b, _ := newTestBreaker(client, zap.NewNop())
+
+	for i := 0; i < 5; i++ {
+		_, _ = b.Get(context.Background(), rbacpolicy.GetRequest{})
+	}
+	if status := b.Status(); status.State != BreakerClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("Expected closed breaker, got %+v", status)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	client := &switchClient{err: errUnavailable}
+	b, _ := newTestBreaker(client, zap.NewNop())
+	ctx := context.Background()
+
+	_, _ = b.Get(ctx, rbacpolicy.GetRequest{})
+	_, _ = b.Get(ctx, rbacpolicy.GetRequest{})
+	client.err = nil
+	_, _ = b.Get(ctx, rbacpolicy.GetRequest{})
+	client.err = errUnavailable
+	_, _ = b.Get(ctx, rbacpolicy.GetRequest{})
+
+	if status := b.Status(); status.State != BreakerClosed || status.ConsecutiveFailures != 1 {
+		t.Errorf("Expected closed breaker with one failure, got %+v", status)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	client := &switchClient{err: errUnavailable}
+	b, clock := newTestBreaker(client, zap.New(core))
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, _ = b.Get(ctx, rbacpolicy.GetRequest{})
+	}
+
+	// After the cooldown a failed trial reopens the breaker.
+	clock.t = clock.t.Add(10 * time.Second)
+	if status := b.Status(); status.State != BreakerHalfOpen {
+		t.Fatalf("Expected half-open breaker after cooldown, got %+v", status)
+	}
+	if _, err := b.Get(ctx, rbacpolicy.GetRequest{}); !errors.Is(err, errUnavailable) {
+		t.Fatalf("Expected trial call to reach Stytch, got %v", err)
+	}
+	if status := b.Status(); status.State != BreakerOpen {
+		t.Fatalf("Expected failed trial to reopen breaker, got %+v", status)
+	}
+
+	// A successful trial closes it.
+	clock.t = clock.t.Add(10 * time.Second)
+	client.err = nil
+	if _, err := b.Get(ctx, rbacpolicy.GetRequest{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status := b.Status(); status.State != BreakerClosed {
+		t.Errorf("Expected successful trial to close breaker, got %+v", status)
+	}
+
+	// closed -> open -> half_open -> open -> half_open -> closed
+	if n := logs.FilterMessage("Circuit breaker state changed").Len(); n != 5 {
+		t.Errorf("Expected 5 state change logs, got %d", n)
+	}
+}
+
+func TestBreakerSingleTrial(t *testing.T) {
+	client := &switchClient{err: errUnavailable}
+	b, clock := newTestBreaker(client, zap.NewNop())
+	for i := 0; i < 3; i++ {
+		_, _ = b.Get(context.Background(), rbacpolicy.GetRequest{})
+	}
+	clock.t = clock.t.Add(10 * time.Second)
+
+	// Simulate a trial in flight.
+	if err := b.allow(); err != nil {
+		t.Fatalf("Expected trial to be allowed, got %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("Expected concurrent call to be rejected during trial, got %v", err)
+	}
+}
+
+func TestBreakerContextErrors(t *testing.T) {
+	client := &switchClient{err: context.Canceled}
+	b, _ := newTestBreaker(client, zap.NewNop())
+	for i := 0; i < 3; i++ {
+		_, _ = b.Get(context.Background(), rbacpolicy.GetRequest{})
+	}
+	if status := b.Status(); status.State != BreakerClosed {
+		t.Errorf("Expected cancellations to be ignored, got %+v", status)
+	}
+
+	client.err = context.DeadlineExceeded
+	for i := 0; i < 3; i++ {
+		_, _ = b.Get(context.Background(), rbacpolicy.GetRequest{})
+	}
+	if status := b.Status(); status.State != BreakerOpen {
+		t.Errorf("Expected timeouts to open the breaker, got %+v", status)
+	}
+}
+
+func TestBreakerCountsNonJSONServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte(`<html><body>502 Bad Gateway</body></html>`))
+	}))
+	defer server.Close()
+
+	client := api.NewClient("key-id", "key-secret",
+		api.WithBaseURI(server.URL),
+		api.WithHTTPClient(&http.Client{Transport: NewTransport(nil)}),
+	)
+	// The breaker sits outside the retrier, as it does in production, so the
+	// status the transport records has to reach it through the retrier.
+	r, _ := newTestRetrier(client.RBACPolicy, zap.NewNop())
+	b, _ := newTestBreaker(r, zap.NewNop())
+
+	for i := 0; i < 3; i++ {
+		_, err := b.Get(context.Background(), rbacpolicy.GetRequest{ProjectID: "project-1"})
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+			t.Fatalf("Call %d: expected a 502 StatusError, got %v", i, err)
+		}
+	}
+	if status := b.Status(); status.State != BreakerOpen {
+		t.Errorf("Expected HTML 502s to open the breaker, got %+v", status)
+	}
+}
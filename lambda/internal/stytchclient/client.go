@@ -0,0 +1,94 @@
+// Package stytchclient provides decorators around the Stytch RBAC policy
+// client that make calls to the management API more resilient.
+package stytchclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+)
+
+// Client is the subset of the Stytch RBAC policy client the decorators wrap.
+// It matches handler.RBACPolicyClient.
+type Client interface {
+	Get(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error)
+	Set(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error)
+}
+
+// StatusError carries the HTTP status Stytch answered with, and the delay it
+// asked for in Retry-After, alongside the error the client returned. The
+// client only reports a status when the body is a Stytch error, so without
+// this a 429 or 5xx page from a proxy in front of Stytch is indistinguishable
+// from a network failure.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Stytch returned HTTP %d: %v", e.StatusCode, e.Err)
+}
+
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// withStatus wraps err in a *StatusError when the Transport recorded an error
+// status for the call. Errors that already carry one are returned as-is.
+func withStatus(err error, response *response) error {
+	statusCode, retryAfter := response.get()
+	var statusErr *StatusError
+	if err == nil || statusCode < http.StatusBadRequest || errors.As(err, &statusErr) {
+		return err
+	}
+	return &StatusError{StatusCode: statusCode, RetryAfter: retryAfter, Err: err}
+}
+
+// failure classifies an error from a Stytch call.
+type failure struct {
+	// transient failures may succeed if the call is repeated.
+	transient bool
+	// unapplied failures are known not to have reached Stytch's write path,
+	// so repeating a Set cannot apply it twice.
+	unapplied bool
+}
+
+// classify inspects err, and the status code the Transport recorded for the
+// attempt if there was one.
+func classify(err error, statusCode int) failure {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return failure{}
+	}
+
+	var stytchErr stytcherror.Error
+	if errors.As(err, &stytchErr) {
+		statusCode = stytchErr.StatusCode
+	}
+	switch {
+	case statusCode == http.StatusTooManyRequests:
+		return failure{transient: true, unapplied: true}
+	case statusCode >= 500:
+		return failure{transient: true}
+	case statusCode != 0:
+		return failure{}
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return failure{transient: true, unapplied: true}
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return failure{transient: true, unapplied: opErr.Op == "dial"}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return failure{transient: true}
+	}
+	return failure{}
+}
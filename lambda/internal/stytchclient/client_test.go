@@ -0,0 +1,85 @@
+package stytchclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+		expected   failure
+	}{
+		{name: "Rate limited", err: stytcherror.Error{StatusCode: 429}, expected: failure{transient: true, unapplied: true}},
+		{name: "Server error", err: stytcherror.Error{StatusCode: 500}, expected: failure{transient: true}},
+		{name: "Bad gateway with non-JSON body", err: errors.New("error decoding http request: invalid character '<'"), statusCode: 502, expected: failure{transient: true}},
+		{name: "Client error", err: stytcherror.Error{StatusCode: 400}, expected: failure{}},
+		{name: "Not found", err: stytcherror.Error{StatusCode: 404}, expected: failure{}},
+		{name: "DNS failure", err: fmt.Errorf("error sending http request: %w", &net.DNSError{Err: "no such host"}), expected: failure{transient: true, unapplied: true}},
+		{name: "Dial failure", err: fmt.Errorf("error sending http request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), expected: failure{transient: true, unapplied: true}},
+		{name: "Read failure", err: fmt.Errorf("error sending http request: %w", &net.OpError{Op: "read", Err: errors.New("connection reset")}), expected: failure{transient: true}},
+		{name: "Canceled", err: fmt.Errorf("error sending http request: %w", context.Canceled), expected: failure{}},
+		{name: "Deadline", err: context.DeadlineExceeded, expected: failure{}},
+		{name: "Other error", err: errors.New("boom"), expected: failure{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classify(tt.err, tt.statusCode); got != tt.expected {
+				t.Errorf("classify() = %+v, expected %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestWithStatus(t *testing.T) {
+	errDecode := errors.New("error decoding http request: invalid character '<'")
+
+	tests := []struct {
+		name       string
+		err        error
+		statusCode int
+		expected   *StatusError
+	}{
+		{name: "No error", statusCode: 429},
+		{name: "No response", err: errDial},
+		{name: "Rate limited", err: errDecode, statusCode: 429, expected: &StatusError{StatusCode: 429, RetryAfter: time.Second, Err: errDecode}},
+		{name: "Bad gateway", err: errDecode, statusCode: 502, expected: &StatusError{StatusCode: 502, RetryAfter: time.Second, Err: errDecode}},
+		{name: "Already wrapped", err: &StatusError{StatusCode: 503, Err: errDecode}, statusCode: 429, expected: &StatusError{StatusCode: 503, Err: errDecode}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, response := withResponse(context.Background())
+			if tt.statusCode != 0 {
+				response.record(tt.statusCode, time.Second)
+			}
+
+			err := withStatus(tt.err, response)
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) {
+				if tt.expected != nil {
+					t.Fatalf("withStatus() = %v, expected a StatusError", err)
+				}
+				if err != tt.err {
+					t.Errorf("withStatus() = %v, expected %v", err, tt.err)
+				}
+				return
+			}
+			if tt.expected == nil || *statusErr != *tt.expected {
+				t.Errorf("withStatus() = %+v, expected %+v", statusErr, tt.expected)
+			}
+			if !errors.Is(err, errDecode) {
+				t.Errorf("Expected withStatus() to wrap the client error, got %v", err)
+			}
+		})
+	}
+}
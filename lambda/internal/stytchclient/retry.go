@@ -0,0 +1,163 @@
+package stytchclient
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"go.uber.org/zap"
+)
+
+// Retrier retries transient Stytch failures with exponential backoff and full
+// jitter. Get is retried on any transient failure. Set is only retried when
+// the failure shows the request was not applied (429, or the connection could
+// not be made), since a retried Set could otherwise overwrite a change made
+// by someone else in between.
+type Retrier struct {
+	next        Client
+	logger      *zap.Logger
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+	// minAttemptTime is the least time worth leaving for an attempt before
+	// the context deadline.
+	minAttemptTime time.Duration
+
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func(d time.Duration) time.Duration
+	now    func() time.Time
+}
+
+// RetryOption configures a Retrier.
+type RetryOption func(*Retrier)
+
+// WithMaxAttempts sets the total number of attempts, including the first.
+func WithMaxAttempts(n int) RetryOption {
+	return func(r *Retrier) {
+		r.maxAttempts = n
+	}
+}
+
+// WithBackoff sets the delay before the first retry and the cap on delays.
+func WithBackoff(base, max time.Duration) RetryOption {
+	return func(r *Retrier) {
+		r.baseDelay = base
+		r.maxDelay = max
+	}
+}
+
+func NewRetrier(next Client, logger *zap.Logger, opts ...RetryOption) *Retrier {
+	r := &Retrier{
+		next:           next,
+		logger:         logger,
+		maxAttempts:    3,
+		baseDelay:      100 * time.Millisecond,
+		maxDelay:       2 * time.Second,
+		minAttemptTime: 500 * time.Millisecond,
+		sleep:          sleep,
+		jitter:         func(d time.Duration) time.Duration { return rand.N(d + 1) },
+		now:            time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *Retrier) Get(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+	var resp *rbacpolicy.GetResponse
+	err := r.do(ctx, "get", false, func(ctx context.Context) error {
+		var err error
+		resp, err = r.next.Get(ctx, body)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Retrier) Set(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+	var resp *rbacpolicy.SetResponse
+	err := r.do(ctx, "set", true, func(ctx context.Context) error {
+		var err error
+		resp, err = r.next.Set(ctx, body)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Retrier) do(ctx context.Context, op string, write bool, call func(context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		attemptCtx, response := withResponse(ctx)
+		err := withStatus(call(attemptCtx), response)
+		if err == nil {
+			if attempt > 1 {
+				r.logger.Info("Stytch call succeeded after retries",
+					zap.String("op", op),
+					zap.Int("retries", attempt-1),
+				)
+			}
+			return nil
+		}
+
+		statusCode, retryAfter := response.get()
+		f := classify(err, statusCode)
+		if !f.transient || (write && !f.unapplied) {
+			return err
+		}
+		if attempt >= r.maxAttempts {
+			r.logger.Warn("Giving up on Stytch call",
+				zap.String("op", op),
+				zap.Int("retries", attempt-1),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		delay := r.delay(attempt, retryAfter)
+		if deadline, ok := ctx.Deadline(); ok && r.now().Add(delay+r.minAttemptTime).After(deadline) {
+			r.logger.Warn("Not retrying Stytch call past the request deadline",
+				zap.String("op", op),
+				zap.Int("retries", attempt-1),
+				zap.Duration("delay", delay),
+				zap.Error(err),
+			)
+			return err
+		}
+
+		r.logger.Warn("Retrying Stytch call",
+			zap.String("op", op),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Int("status_code", statusCode),
+			zap.Error(err),
+		)
+		if err := r.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// delay is the backoff before the given retry. A Retry-After from Stytch is
+// used when it asks for longer.
+func (r *Retrier) delay(attempt int, retryAfter time.Duration) time.Duration {
+	backoff := r.baseDelay << (attempt - 1)
+	if backoff > r.maxDelay || backoff <= 0 {
+		backoff = r.maxDelay
+	}
+	delay := r.jitter(backoff)
+	if retryAfter > delay {
+		delay = retryAfter
+	}
+	return delay
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,199 @@
+package stytchclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/api"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// scriptedClient returns the scripted errors in order, then succeeds.
+type scriptedClient struct {
+	errs  []error
+	calls int
+}
+
+func (c *scriptedClient) next() error {
+	c.calls++
+	if c.calls <= len(c.errs) {
+		return c.errs[c.calls-1]
+	}
+	return nil
+}
+
+func (c *scriptedClient) Get(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+	if err := c.next(); err != nil {
+		return nil, err
+	}
+	return &rbacpolicy.GetResponse{StatusCode: 200}, nil
+}
+
+func (c *scriptedClient) Set(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+	if err := c.next(); err != nil {
+		return nil, err
+	}
+	return &rbacpolicy.SetResponse{StatusCode: 200, Policy: body.Policy}, nil
+}
+
+// newTestRetrier returns a Retrier that records its sleeps instead of
+// sleeping and always uses the full backoff.
+func newTestRetrier(next Client, logger *zap.Logger, opts ...RetryOption) (*Retrier, *[]time.Duration) {
+	var sleeps []time.Duration
+	r := NewRetrier(next, logger, opts...)
+	r.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	r.jitter = func(d time.Duration) time.Duration { return d }
+	return r, &sleeps
+}
+
+var (
+	errRateLimited = stytcherror.Error{StatusCode: 429}
+	errUnavailable = stytcherror.Error{StatusCode: 503}
+	errBadRequest  = stytcherror.Error{StatusCode: 400}
+	errDial        = fmt.Errorf("error sending http request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+)
+
+func TestRetrier(t *testing.T) {
+	tests := []struct {
+		name          string
+		write         bool
+		errs          []error
+		expectedCalls int
+		expectErr     bool
+		expectedSleep []time.Duration
+	}{
+		{name: "Get succeeds first time", expectedCalls: 1},
+		{name: "Get retries server errors", errs: []error{errUnavailable, errUnavailable}, expectedCalls: 3, expectedSleep: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}},
+		{name: "Get gives up after max attempts", errs: []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}, expectedCalls: 3, expectErr: true},
+		{name: "Get does not retry client errors", errs: []error{errBadRequest}, expectedCalls: 1, expectErr: true},
+		{name: "Set retries rate limiting", write: true, errs: []error{errRateLimited}, expectedCalls: 2},
+		{name: "Set retries dial failures", write: true, errs: []error{errDial}, expectedCalls: 2},
+		{name: "Set does not retry server errors", write: true, errs: []error{errUnavailable}, expectedCalls: 1, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &scriptedClient{errs: tt.errs}
+			r, sleeps := newTestRetrier(client, zap.NewNop())
+
+			var err error
+			if tt.write {
+				_, err = r.Set(context.Background(), rbacpolicy.SetRequest{})
+			} else {
+				_, err = r.Get(context.Background(), rbacpolicy.GetRequest{})
+			}
+
+			if (err != nil) != tt.expectErr {
+				t.Errorf("Expected error %v, got %v", tt.expectErr, err)
+			}
+			if client.calls != tt.expectedCalls {
+				t.Errorf("Expected %d calls, got %d", tt.expectedCalls, client.calls)
+			}
+			if tt.expectedSleep != nil {
+				if len(*sleeps) != len(tt.expectedSleep) {
+					t.Fatalf("Expected sleeps %v, got %v", tt.expectedSleep, *sleeps)
+				}
+				for i := range tt.expectedSleep {
+					if (*sleeps)[i] != tt.expectedSleep[i] {
+						t.Errorf("Expected sleeps %v, got %v", tt.expectedSleep, *sleeps)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRetrierBackoffCap(t *testing.T) {
+	client := &scriptedClient{errs: []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable}}
+	r, sleeps := newTestRetrier(client, zap.NewNop(), WithMaxAttempts(5), WithBackoff(time.Second, 3*time.Second))
+
+	if _, err := r.Get(context.Background(), rbacpolicy.GetRequest{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second}
+	for i := range expected {
+		if (*sleeps)[i] != expected[i] {
+			t.Fatalf("Expected sleeps %v, got %v", expected, *sleeps)
+		}
+	}
+}
+
+func TestRetrierRespectsDeadline(t *testing.T) {
+	client := &scriptedClient{errs: []error{errUnavailable}}
+	r, sleeps := newTestRetrier(client, zap.NewNop(), WithBackoff(time.Second, time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 800*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.Get(ctx, rbacpolicy.GetRequest{}); err == nil {
+		t.Error("Expected error when the deadline leaves no time to retry")
+	}
+	if client.calls != 1 || len(*sleeps) != 0 {
+		t.Errorf("Expected a single attempt and no sleep, got %d calls and %v", client.calls, *sleeps)
+	}
+}
+
+func TestRetrierLogsRetries(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	client := &scriptedClient{errs: []error{errUnavailable, errRateLimited}}
+	r, _ := newTestRetrier(client, zap.New(core))
+
+	if _, err := r.Get(context.Background(), rbacpolicy.GetRequest{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := logs.FilterMessage("Retrying Stytch call").Len(); n != 2 {
+		t.Errorf("Expected 2 retry log entries, got %d", n)
+	}
+	done := logs.FilterMessage("Stytch call succeeded after retries").All()
+	if len(done) != 1 || done[0].ContextMap()["retries"] != int64(2) {
+		t.Errorf("Expected success log with 2 retries, got %+v", done)
+	}
+}
+
+func TestRetrierHonoursRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"status_code":429,"error_type":"too_many_requests"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status_code":200,"policy":{}}`))
+	}))
+	defer server.Close()
+
+	client := api.NewClient("key-id", "key-secret",
+		api.WithBaseURI(server.URL),
+		api.WithHTTPClient(&http.Client{Transport: NewTransport(nil)}),
+	)
+	r, sleeps := newTestRetrier(client.RBACPolicy, zap.NewNop())
+
+	if _, err := r.Get(context.Background(), rbacpolicy.GetRequest{ProjectID: "project-1"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(*sleeps) != 1 || (*sleeps)[0] != time.Second {
+		t.Errorf("Expected a single 1s sleep from Retry-After, got %v", *sleeps)
+	}
+}
+
+func TestSleepCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleep(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
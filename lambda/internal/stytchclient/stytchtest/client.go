@@ -0,0 +1,249 @@
+// Package stytchtest provides an in-memory stand-in for the Stytch RBAC policy
+// API. Each project starts with the Stytch default roles and resources, and
+// latency and failures can be injected to exercise retries and the circuit
+// breaker. Writes are checked with this repository's own validation rules,
+// which are not an independent copy of Stytch's, so the fake cannot catch a
+// rule the handler misses; inject errors with FailNext to test how rejections
+// from Stytch are handled.
+package stytchtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/validation"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+)
+
+// Error types the Client returns, mirroring the Stytch management API.
+const (
+	ErrorTypeProjectNotFound stytcherror.Type = "project_not_found"
+	ErrorTypeInvalidPolicy   stytcherror.Type = "invalid_rbac_policy"
+	ErrorTypeTooManyRequests stytcherror.Type = "too_many_requests"
+	ErrorTypeInternalServer  stytcherror.Type = "internal_server_error"
+)
+
+// Client is an in-memory implementation of handler.RBACPolicyClient that is
+// safe for concurrent use. The zero value is not usable; call NewClient.
+type Client struct {
+	mu        sync.Mutex
+	policies  map[string]rbacpolicy.Policy
+	latency   time.Duration
+	failures  []error
+	requestID int
+}
+
+// NewClient returns a Client serving projectIDs, each holding the default
+// policy. Calls for any other project fail with a 404.
+func NewClient(projectIDs ...string) *Client {
+	c := &Client{policies: make(map[string]rbacpolicy.Policy)}
+	for _, projectID := range projectIDs {
+		c.policies[projectID] = DefaultPolicy()
+	}
+	return c
+}
+
+// DefaultPolicy returns the policy Stytch creates a project with: the
+// stytch_member and stytch_admin roles over the Stytch resources, and no
+// custom roles or resources.
+func DefaultPolicy() rbacpolicy.Policy {
+	resources := []rbacpolicy.Resource{
+		{
+			ResourceID:       "stytch.organization",
+			Description:      "Stytch organization resource",
+			AvailableActions: []string{"update.info.name", "update.info.slug", "update.info.logo_url", "update.settings.allowed-auth-methods", "update.settings.email-jit-provisioning", "delete"},
+		},
+		{
+			ResourceID:       "stytch.member",
+			Description:      "Stytch member resource",
+			AvailableActions: []string{"create", "search", "delete", "update.info.name", "update.info.untrusted-metadata", "update.info.mfa-phone", "update.settings.is-breakglass", "update.settings.roles", "update.settings.mfa-enrolled"},
+		},
+		{
+			ResourceID:       "stytch.sso",
+			Description:      "Stytch SSO connection resource",
+			AvailableActions: []string{"create", "update", "delete"},
+		},
+		{
+			ResourceID:       "stytch.scim",
+			Description:      "Stytch SCIM connection resource",
+			AvailableActions: []string{"create", "update", "delete", "get", "rotate"},
+		},
+		{
+			ResourceID:       "stytch.self",
+			Description:      "The member's own record",
+			AvailableActions: []string{"update.info.name", "update.info.untrusted-metadata", "update.info.mfa-phone", "update.settings.mfa-enrolled", "revoke-sessions", "delete"},
+		},
+	}
+
+	admin := rbacpolicy.Role{
+		RoleID:      "stytch_admin",
+		Description: "Granted to Members who create an Organization",
+	}
+	for _, res := range resources {
+		admin.Permissions = append(admin.Permissions, rbacpolicy.Permission{ResourceID: res.ResourceID, Actions: []string{rbac.WildcardAction}})
+	}
+
+	return rbacpolicy.Policy{
+		StytchMember: rbacpolicy.Role{
+			RoleID:      "stytch_member",
+			Description: "Granted to all Members upon creation",
+			Permissions: []rbacpolicy.Permission{{ResourceID: "stytch.self", Actions: []string{rbac.WildcardAction}}},
+		},
+		StytchAdmin:     admin,
+		StytchResources: resources,
+		CustomRoles:     []rbacpolicy.Role{},
+		CustomResources: []rbacpolicy.Resource{},
+	}
+}
+
+// Get returns the project's policy.
+func (c *Client) Get(ctx context.Context, body rbacpolicy.GetRequest) (*rbacpolicy.GetResponse, error) {
+	if err := c.begin(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	requestID := c.nextRequestID()
+	policy, ok := c.policies[body.ProjectID]
+	if !ok {
+		return nil, projectNotFound(requestID, body.ProjectID)
+	}
+	return &rbacpolicy.GetResponse{StatusCode: http.StatusOK, RequestID: requestID, Policy: rbac.Clone(policy)}, nil
+}
+
+// Set replaces the project's custom roles and resources and the permissions
+// of the default roles. As with Stytch, the Stytch resources and the default
+// role IDs cannot be changed; whatever is sent for them is ignored. A policy
+// that fails validation.Validate is rejected with a 400.
+func (c *Client) Set(ctx context.Context, body rbacpolicy.SetRequest) (*rbacpolicy.SetResponse, error) {
+	if err := c.begin(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	requestID := c.nextRequestID()
+	current, ok := c.policies[body.ProjectID]
+	if !ok {
+		return nil, projectNotFound(requestID, body.ProjectID)
+	}
+
+	policy := rbac.Clone(body.Policy)
+	policy.StytchResources = rbac.Clone(current).StytchResources
+	policy.StytchMember.RoleID = current.StytchMember.RoleID
+	policy.StytchAdmin.RoleID = current.StytchAdmin.RoleID
+	if policy.CustomRoles == nil {
+		policy.CustomRoles = []rbacpolicy.Role{}
+	}
+	if policy.CustomResources == nil {
+		policy.CustomResources = []rbacpolicy.Resource{}
+	}
+	if violations := validation.Validate(policy); violations != nil {
+		return nil, stytcherror.Error{
+			StatusCode:   http.StatusBadRequest,
+			RequestID:    requestID,
+			ErrorType:    ErrorTypeInvalidPolicy,
+			ErrorMessage: stytcherror.Message(violations.Error()),
+		}
+	}
+
+	c.policies[body.ProjectID] = policy
+	return &rbacpolicy.SetResponse{StatusCode: http.StatusOK, RequestID: requestID, Policy: rbac.Clone(policy)}, nil
+}
+
+// Policy returns a copy of the project's stored policy.
+func (c *Client) Policy(projectID string) (rbacpolicy.Policy, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	policy, ok := c.policies[projectID]
+	return rbac.Clone(policy), ok
+}
+
+// SetPolicy stores policy for projectID as is, adding the project if it does
+// not exist. It bypasses validation so tests can seed any state.
+func (c *Client) SetPolicy(projectID string, policy rbacpolicy.Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policies[projectID] = rbac.Clone(policy)
+}
+
+// SetLatency delays every subsequent call by d, or until the call's context
+// is done.
+func (c *Client) SetLatency(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latency = d
+}
+
+// FailNext makes the next len(errs) calls return errs, in order, without
+// touching any policy.
+func (c *Client) FailNext(errs ...error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = append(c.failures, errs...)
+}
+
+// TooManyRequests returns the error Stytch gives a rate limited call.
+func TooManyRequests() error {
+	return stytcherror.Error{
+		StatusCode:   http.StatusTooManyRequests,
+		ErrorType:    ErrorTypeTooManyRequests,
+		ErrorMessage: "Too many requests have been made.",
+	}
+}
+
+// InternalServerError returns the error Stytch gives when it fails a call.
+func InternalServerError() error {
+	return stytcherror.Error{
+		StatusCode:   http.StatusInternalServerError,
+		ErrorType:    ErrorTypeInternalServer,
+		ErrorMessage: "Oops, something seems to have gone wrong.",
+	}
+}
+
+// begin applies the configured latency and returns the next injected
+// failure, if any.
+func (c *Client) begin(ctx context.Context) error {
+	c.mu.Lock()
+	latency := c.latency
+	c.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.failures) == 0 {
+		return nil
+	}
+	err := c.failures[0]
+	c.failures = c.failures[1:]
+	return err
+}
+
+func (c *Client) nextRequestID() string {
+	c.requestID++
+	return fmt.Sprintf("request-id-test-%d", c.requestID)
+}
+
+func projectNotFound(requestID, projectID string) error {
+	return stytcherror.Error{
+		StatusCode:   http.StatusNotFound,
+		RequestID:    requestID,
+		ErrorType:    ErrorTypeProjectNotFound,
+		ErrorMessage: stytcherror.Message(fmt.Sprintf("Project %s could not be found.", projectID)),
+	}
+}
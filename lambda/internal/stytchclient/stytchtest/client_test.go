@@ -0,0 +1,212 @@
+package stytchtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/stytchclient"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+	"go.uber.org/zap"
+)
+
+// stytchStatus returns the status code of a Stytch error, or 0.
+func stytchStatus(err error) int {
+	var stytchErr stytcherror.Error
+	if errors.As(err, &stytchErr) {
+		return stytchErr.StatusCode
+	}
+	return 0
+}
+
+func TestClientGet(t *testing.T) {
+	c := NewClient("project-test")
+
+	resp, err := c.Get(context.Background(), rbacpolicy.GetRequest{ProjectID: "project-test"})
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.RequestID == "" {
+		t.Errorf("Expected a 200 with a request ID, got %d %q", resp.StatusCode, resp.RequestID)
+	}
+	if rbac.Hash(resp.Policy) != rbac.Hash(DefaultPolicy()) {
+		t.Errorf("Expected the default policy, got %+v", resp.Policy)
+	}
+
+	resp.Policy.StytchResources[0].ResourceID = "mutated"
+	if policy, _ := c.Policy("project-test"); policy.StytchResources[0].ResourceID == "mutated" {
+		t.Error("Get() returned the stored policy rather than a copy")
+	}
+
+	_, err = c.Get(context.Background(), rbacpolicy.GetRequest{ProjectID: "project-missing"})
+	if got := stytchStatus(err); got != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown project, got %v", err)
+	}
+}
+
+func TestClientSet(t *testing.T) {
+	documents := rbacpolicy.Resource{ResourceID: "documents", AvailableActions: []string{"read", "write"}}
+	editor := rbacpolicy.Role{RoleID: "editor", Permissions: []rbacpolicy.Permission{{ResourceID: "documents", Actions: []string{"write"}}}}
+
+	tests := []struct {
+		name       string
+		mutate     func(p *rbacpolicy.Policy)
+		wantStatus int
+		check      func(t *testing.T, stored rbacpolicy.Policy)
+	}{
+		{
+			name: "Custom roles and resources",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomResources = []rbacpolicy.Resource{documents}
+				p.CustomRoles = []rbacpolicy.Role{editor}
+			},
+			check: func(t *testing.T, stored rbacpolicy.Policy) {
+				if len(stored.CustomRoles) != 1 || len(stored.CustomResources) != 1 {
+					t.Errorf("Expected the custom role and resource to be stored, got %+v", stored)
+				}
+			},
+		},
+		{
+			name: "Default role permissions",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.StytchMember.Permissions = append(p.StytchMember.Permissions, rbacpolicy.Permission{ResourceID: "stytch.member", Actions: []string{"search"}})
+			},
+			check: func(t *testing.T, stored rbacpolicy.Policy) {
+				if len(stored.StytchMember.Permissions) != 2 {
+					t.Errorf("Expected the stytch_member permission to be stored, got %+v", stored.StytchMember)
+				}
+			},
+		},
+		{
+			name: "Stytch resources and default role IDs are ignored",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.StytchResources = nil
+				p.StytchAdmin.RoleID = "admin"
+				p.CustomRoles = nil
+			},
+			check: func(t *testing.T, stored rbacpolicy.Policy) {
+				want := DefaultPolicy()
+				if rbac.Hash(stored) != rbac.Hash(want) {
+					t.Errorf("Expected the default policy to be kept, got %+v", stored)
+				}
+			},
+		},
+		{
+			name: "Unknown resource",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomRoles = []rbacpolicy.Role{editor}
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Reserved prefix",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomRoles = []rbacpolicy.Role{{RoleID: "stytch_owner"}}
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "Unavailable action",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomResources = []rbacpolicy.Resource{documents}
+				p.CustomRoles = []rbacpolicy.Role{{RoleID: "editor", Permissions: []rbacpolicy.Permission{{ResourceID: "documents", Actions: []string{"delete"}}}}}
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClient("project-test")
+			policy := DefaultPolicy()
+			tt.mutate(&policy)
+
+			resp, err := c.Set(context.Background(), rbacpolicy.SetRequest{ProjectID: "project-test", Policy: policy})
+			stored, _ := c.Policy("project-test")
+			if tt.wantStatus != 0 {
+				var stytchErr stytcherror.Error
+				if !errors.As(err, &stytchErr) || stytchErr.StatusCode != tt.wantStatus || stytchErr.ErrorType != ErrorTypeInvalidPolicy {
+					t.Fatalf("Expected a %d %s error, got %v", tt.wantStatus, ErrorTypeInvalidPolicy, err)
+				}
+				if rbac.Hash(stored) != rbac.Hash(DefaultPolicy()) {
+					t.Errorf("Rejected Set() changed the stored policy: %+v", stored)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Set() unexpected error: %v", err)
+			}
+			if rbac.Hash(resp.Policy) != rbac.Hash(stored) {
+				t.Errorf("Expected the response to carry the stored policy, got %+v", resp.Policy)
+			}
+			tt.check(t, stored)
+		})
+	}
+
+	c := NewClient()
+	_, err := c.Set(context.Background(), rbacpolicy.SetRequest{ProjectID: "project-missing", Policy: DefaultPolicy()})
+	if got := stytchStatus(err); got != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown project, got %v", err)
+	}
+}
+
+func TestClientSetPolicy(t *testing.T) {
+	c := NewClient()
+	seeded := rbacpolicy.Policy{CustomRoles: []rbacpolicy.Role{{RoleID: "stytch_invalid"}}}
+	c.SetPolicy("project-seeded", seeded)
+
+	resp, err := c.Get(context.Background(), rbacpolicy.GetRequest{ProjectID: "project-seeded"})
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if rbac.Hash(resp.Policy) != rbac.Hash(seeded) {
+		t.Errorf("Expected the seeded policy, got %+v", resp.Policy)
+	}
+}
+
+func TestClientFailNext(t *testing.T) {
+	c := NewClient("project-test")
+	c.FailNext(TooManyRequests(), InternalServerError())
+
+	ctx := context.Background()
+	request := rbacpolicy.GetRequest{ProjectID: "project-test"}
+	for _, want := range []int{http.StatusTooManyRequests, http.StatusInternalServerError} {
+		if _, err := c.Get(ctx, request); stytchStatus(err) != want {
+			t.Errorf("Expected injected %d, got %v", want, err)
+		}
+	}
+	if _, err := c.Get(ctx, request); err != nil {
+		t.Errorf("Expected success once the failures are used up, got %v", err)
+	}
+
+	// Injected failures are transient to the retrier, as they are from Stytch.
+	c.FailNext(TooManyRequests(), InternalServerError())
+	retrier := stytchclient.NewRetrier(c, zap.NewNop(), stytchclient.WithBackoff(time.Millisecond, time.Millisecond))
+	if _, err := retrier.Get(ctx, request); err != nil {
+		t.Errorf("Expected the retrier to recover, got %v", err)
+	}
+}
+
+func TestClientLatency(t *testing.T) {
+	c := NewClient("project-test")
+	c.SetLatency(20 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := c.Get(context.Background(), rbacpolicy.GetRequest{ProjectID: "project-test"}); err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected the call to take at least 20ms, took %v", elapsed)
+	}
+
+	c.SetLatency(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Set(ctx, rbacpolicy.SetRequest{ProjectID: "project-test", Policy: DefaultPolicy()}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context deadline to cut the delay short, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package stytchtest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+)
+
+// ErrorTypeUnauthorized is returned when a request's workspace key does not
+// match the Server's.
+const ErrorTypeUnauthorized stytcherror.Type = "unauthorized_credentials"
+
+// Server serves the Stytch management API's RBAC policy endpoints,
+//
+//	GET /v1/projects/{project_id}/rbac_policy
+//	PUT /v1/projects/{project_id}/rbac_policy
+//
+// from a Client, so the real management API client can be pointed at it with
+// api.WithBaseURI(server.URL). Requests must carry the workspace key the
+// Server was started with as basic auth. Errors are encoded the way Stytch
+// encodes them.
+type Server struct {
+	*httptest.Server
+	Client *Client
+
+	workspaceKeyID     string
+	workspaceKeySecret string
+}
+
+// NewServer starts a Server backed by client; callers must Close it.
+func NewServer(client *Client, workspaceKeyID, workspaceKeySecret string) *Server {
+	s := &Server{
+		Client:             client,
+		workspaceKeyID:     workspaceKeyID,
+		workspaceKeySecret: workspaceKeySecret,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if id, secret, ok := r.BasicAuth(); !ok || id != s.workspaceKeyID || secret != s.workspaceKeySecret {
+		writeError(w, stytcherror.Error{
+			StatusCode:   http.StatusUnauthorized,
+			ErrorType:    ErrorTypeUnauthorized,
+			ErrorMessage: "Unauthorized credentials.",
+		})
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.Path, "/v1/projects/")
+	projectID, ok2 := strings.CutSuffix(rest, "/rbac_policy")
+	if !ok || !ok2 || projectID == "" || strings.Contains(projectID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		resp, err := s.Client.Get(r.Context(), rbacpolicy.GetRequest{ProjectID: projectID})
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	case http.MethodPut:
+		var body rbacpolicy.SetRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, stytcherror.Error{
+				StatusCode:   http.StatusBadRequest,
+				ErrorType:    "invalid_request_body",
+				ErrorMessage: stytcherror.Message("Request body could not be parsed: " + err.Error()),
+			})
+			return
+		}
+		body.ProjectID = projectID
+		resp, err := s.Client.Set(r.Context(), body)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeError encodes err as a Stytch error body. Errors that are not Stytch
+// errors, such as a cancelled context, are reported as a 500.
+func writeError(w http.ResponseWriter, err error) {
+	var stytchErr stytcherror.Error
+	if !errors.As(err, &stytchErr) {
+		stytchErr = stytcherror.Error{
+			StatusCode:   http.StatusInternalServerError,
+			ErrorType:    ErrorTypeInternalServer,
+			ErrorMessage: stytcherror.Message(err.Error()),
+		}
+	}
+	writeJSON(w, stytchErr.StatusCode, stytchErr)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,133 @@
+package stytchtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/api"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/stytcherror"
+)
+
+func TestServer(t *testing.T) {
+	server := NewServer(NewClient("project-test"), "workspace-key", "workspace-secret")
+	defer server.Close()
+
+	ctx := context.Background()
+	client := api.NewClient("workspace-key", "workspace-secret", api.WithBaseURI(server.URL)).RBACPolicy
+
+	got, err := client.Get(ctx, rbacpolicy.GetRequest{ProjectID: "project-test"})
+	if err != nil {
+		t.Fatalf("Get() unexpected error: %v", err)
+	}
+	if got.StatusCode != http.StatusOK || got.RequestID == "" || len(got.Policy.StytchResources) == 0 {
+		t.Errorf("Expected the default policy with a request ID, got %+v", got)
+	}
+
+	policy := got.Policy
+	policy.CustomResources = []rbacpolicy.Resource{{ResourceID: "documents", AvailableActions: []string{"read"}}}
+	set, err := client.Set(ctx, rbacpolicy.SetRequest{ProjectID: "project-test", Policy: policy})
+	if err != nil {
+		t.Fatalf("Set() unexpected error: %v", err)
+	}
+	if len(set.Policy.CustomResources) != 1 {
+		t.Errorf("Expected the stored policy in the response, got %+v", set.Policy)
+	}
+	if stored, _ := server.Client.Policy("project-test"); len(stored.CustomResources) != 1 {
+		t.Errorf("Expected Set() to reach the backing client, got %+v", stored)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		secret     string
+		projectID  string
+		inject     error
+		policy     func(p *rbacpolicy.Policy)
+		wantStatus int
+		wantType   stytcherror.Type
+	}{
+		{name: "Wrong workspace secret", secret: "wrong", projectID: "project-test", wantStatus: http.StatusUnauthorized, wantType: ErrorTypeUnauthorized},
+		// The management client discards the body of a 404.
+		{name: "Unknown project", secret: "workspace-secret", projectID: "project-missing", wantStatus: http.StatusNotFound},
+		{
+			name:      "Invalid policy",
+			secret:    "workspace-secret",
+			projectID: "project-test",
+			policy: func(p *rbacpolicy.Policy) {
+				p.CustomRoles = []rbacpolicy.Role{{RoleID: "stytch_owner"}}
+			},
+			wantStatus: http.StatusBadRequest,
+			wantType:   ErrorTypeInvalidPolicy,
+		},
+		{name: "Injected failure", secret: "workspace-secret", projectID: "project-test", inject: TooManyRequests(), wantStatus: http.StatusTooManyRequests, wantType: ErrorTypeTooManyRequests},
+		{name: "Injected non-Stytch failure", secret: "workspace-secret", projectID: "project-test", inject: errors.New("boom"), wantStatus: http.StatusInternalServerError, wantType: ErrorTypeInternalServer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(NewClient("project-test"), "workspace-key", "workspace-secret")
+			defer server.Close()
+			if tt.inject != nil {
+				server.Client.FailNext(tt.inject)
+			}
+			client := api.NewClient("workspace-key", tt.secret, api.WithBaseURI(server.URL)).RBACPolicy
+
+			var err error
+			if tt.policy != nil {
+				policy := DefaultPolicy()
+				tt.policy(&policy)
+				_, err = client.Set(context.Background(), rbacpolicy.SetRequest{ProjectID: tt.projectID, Policy: policy})
+			} else {
+				_, err = client.Get(context.Background(), rbacpolicy.GetRequest{ProjectID: tt.projectID})
+			}
+
+			var stytchErr stytcherror.Error
+			if !errors.As(err, &stytchErr) {
+				t.Fatalf("Expected a Stytch error, got %v", err)
+			}
+			if stytchErr.StatusCode != tt.wantStatus || stytchErr.ErrorType != tt.wantType {
+				t.Errorf("Expected %d %q, got %d %q", tt.wantStatus, tt.wantType, stytchErr.StatusCode, stytchErr.ErrorType)
+			}
+		})
+	}
+}
+
+func TestServerRoutes(t *testing.T) {
+	server := NewServer(NewClient("project-test"), "workspace-key", "workspace-secret")
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{name: "Unsupported method", method: http.MethodPost, path: "/v1/projects/project-test/rbac_policy", wantStatus: http.StatusMethodNotAllowed},
+		{name: "Unknown route", method: http.MethodGet, path: "/v1/projects/project-test/secrets", wantStatus: http.StatusNotFound},
+		{name: "Malformed body", method: http.MethodPut, path: "/v1/projects/project-test/rbac_policy", body: "{", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, server.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Failed to build request: %v", err)
+			}
+			req.SetBasicAuth("workspace-key", "workspace-secret")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package stytchclient
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Transport is an http.RoundTripper for the Stytch API client that records
+// each response's status code and Retry-After header for the decorators in
+// this package. The Stytch client does not expose response headers in its
+// errors, so without it Retry-After is not honoured and error responses that
+// are not Stytch JSON cannot be classified.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if resp != nil {
+		if r, ok := req.Context().Value(responseKey{}).(*response); ok {
+			r.record(resp.StatusCode, parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()))
+		}
+	}
+	return resp, err
+}
+
+// response carries what Transport saw back to the decorator that made the
+// call. Decorators nest, so a response also passes what it records to the one
+// the enclosing decorator is waiting on.
+type response struct {
+	parent *response
+
+	mu         sync.Mutex
+	statusCode int
+	retryAfter time.Duration
+}
+
+type responseKey struct{}
+
+func withResponse(ctx context.Context) (context.Context, *response) {
+	parent, _ := ctx.Value(responseKey{}).(*response)
+	r := &response{parent: parent}
+	return context.WithValue(ctx, responseKey{}, r), r
+}
+
+func (r *response) record(statusCode int, retryAfter time.Duration) {
+	r.mu.Lock()
+	r.statusCode = statusCode
+	r.retryAfter = retryAfter
+	r.mu.Unlock()
+
+	if r.parent != nil {
+		r.parent.record(statusCode, retryAfter)
+	}
+}
+
+func (r *response) get() (int, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.statusCode, r.retryAfter
+}
+
+// parseRetryAfter accepts either delay-seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package stytchclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+	}{
+		{name: "Empty", value: "", expected: 0},
+		{name: "Seconds", value: "3", expected: 3 * time.Second},
+		{name: "Negative seconds", value: "-1", expected: 0},
+		{name: "HTTP date", value: now.Add(10 * time.Second).Format(http.TimeFormat), expected: 10 * time.Second},
+		{name: "Past HTTP date", value: now.Add(-time.Minute).Format(http.TimeFormat), expected: 0},
+		{name: "Garbage", value: "soon", expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.expected {
+				t.Errorf("parseRetryAfter(%q) = %v, expected %v", tt.value, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTransportRecordsResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(nil)}
+
+	ctx, response := withResponse(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	statusCode, retryAfter := response.get()
+	if statusCode != http.StatusTooManyRequests || retryAfter != 2*time.Second {
+		t.Errorf("Expected 429 and 2s, got %d and %v", statusCode, retryAfter)
+	}
+
+	// Requests made outside a decorator are passed through untouched.
+	req, _ = http.NewRequest(http.MethodGet, server.URL, nil)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestResponsePropagatesToOuterDecorator(t *testing.T) {
+	ctx, outer := withResponse(context.Background())
+	_, inner := withResponse(ctx)
+	inner.record(http.StatusBadGateway, time.Second)
+
+	if statusCode, retryAfter := outer.get(); statusCode != http.StatusBadGateway || retryAfter != time.Second {
+		t.Errorf("Expected outer response to see 502 and 1s, got %d and %v", statusCode, retryAfter)
+	}
+}
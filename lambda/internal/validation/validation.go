@@ -0,0 +1,116 @@
+package validation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/srnext/stytch-rbacpolicy-lambda/internal/rbac"
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+// ReservedPrefix is reserved by Stytch for its own role IDs and may not be
+// used by custom roles or resources.
+const ReservedPrefix = "stytch_"
+
+// Violation describes a single problem with a policy. Field is a path into the
+// policy's JSON encoding, such as "custom_roles[0].permissions[1].resource_id".
+type Violation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Violations is the list of problems found in a policy.
+type Violations []Violation
+
+func (v Violations) Error() string {
+	msgs := make([]string, 0, len(v))
+	for _, violation := range v {
+		msgs = append(msgs, violation.Field+": "+violation.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks a policy for referential integrity and ID rules before it
+// is sent to Stytch. Permissions may reference either custom resources or the
+// Stytch resources listed in p.StytchResources. It returns nil when the policy
+// is valid.
+func Validate(p rbacpolicy.Policy) Violations {
+	var v Violations
+
+	resources := make(map[string]rbacpolicy.Resource)
+	for _, res := range p.StytchResources {
+		resources[res.ResourceID] = res
+	}
+	for i, res := range p.CustomResources {
+		field := fmt.Sprintf("custom_resources[%d].resource_id", i)
+		if !v.checkID(field, res.ResourceID) {
+			continue
+		}
+		if _, ok := resources[res.ResourceID]; ok {
+			v.add(field, fmt.Sprintf("duplicate resource ID %q", res.ResourceID))
+			continue
+		}
+		resources[res.ResourceID] = res
+	}
+
+	v.checkPermissions("stytch_member", p.StytchMember.Permissions, resources)
+	v.checkPermissions("stytch_admin", p.StytchAdmin.Permissions, resources)
+
+	roleIDs := make(map[string]bool)
+	for i, role := range p.CustomRoles {
+		prefix := fmt.Sprintf("custom_roles[%d]", i)
+		if v.checkID(prefix+".role_id", role.RoleID) {
+			if roleIDs[role.RoleID] {
+				v.add(prefix+".role_id", fmt.Sprintf("duplicate role ID %q", role.RoleID))
+			}
+			roleIDs[role.RoleID] = true
+		}
+		v.checkPermissions(prefix, role.Permissions, resources)
+	}
+
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v *Violations) add(field, message string) {
+	*v = append(*v, Violation{Field: field, Message: message})
+}
+
+// checkID validates a custom role or resource ID and reports whether it is
+// usable for further checks.
+func (v *Violations) checkID(field, id string) bool {
+	switch {
+	case strings.TrimSpace(id) == "":
+		v.add(field, "must not be empty")
+		return false
+	case strings.HasPrefix(id, ReservedPrefix):
+		v.add(field, fmt.Sprintf("must not use the reserved %q prefix", ReservedPrefix))
+		return false
+	}
+	return true
+}
+
+func (v *Violations) checkPermissions(rolePath string, perms []rbacpolicy.Permission, resources map[string]rbacpolicy.Resource) {
+	for i, perm := range perms {
+		prefix := fmt.Sprintf("%s.permissions[%d]", rolePath, i)
+		if perm.ResourceID == "" {
+			v.add(prefix+".resource_id", "must not be empty")
+			continue
+		}
+		res, ok := resources[perm.ResourceID]
+		if !ok {
+			v.add(prefix+".resource_id", fmt.Sprintf("unknown resource %q", perm.ResourceID))
+			continue
+		}
+		for j, action := range perm.Actions {
+			if action == rbac.WildcardAction || slices.Contains(res.AvailableActions, action) {
+				continue
+			}
+			v.add(fmt.Sprintf("%s.actions[%d]", prefix, j),
+				fmt.Sprintf("action %q is not available on resource %q", action, perm.ResourceID))
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stytchauth/stytch-management-go/v2/pkg/models/rbacpolicy"
+)
+
+func validPolicy() rbacpolicy.Policy {
+	return rbacpolicy.Policy{
+		StytchMember: rbacpolicy.Role{
+			RoleID: "stytch_member",
+			Permissions: []rbacpolicy.Permission{
+				{ResourceID: "stytch.self", Actions: []string{"*"}},
+			},
+		},
+		StytchAdmin: rbacpolicy.Role{RoleID: "stytch_admin"},
+		StytchResources: []rbacpolicy.Resource{
+			{ResourceID: "stytch.self", AvailableActions: []string{"read", "update"}},
+		},
+		CustomRoles: []rbacpolicy.Role{
+			{
+				RoleID: "editor",
+				Permissions: []rbacpolicy.Permission{
+					{ResourceID: "documents", Actions: []string{"read", "write"}},
+					{ResourceID: "stytch.self", Actions: []string{"read"}},
+				},
+			},
+		},
+		CustomResources: []rbacpolicy.Resource{
+			{ResourceID: "documents", AvailableActions: []string{"read", "write"}},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name           string
+		mutate         func(p *rbacpolicy.Policy)
+		expectedFields []string
+	}{
+		{
+			name:   "Valid policy",
+			mutate: func(p *rbacpolicy.Policy) {},
+		},
+		{
+			name: "Unknown resource",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomRoles[0].Permissions[0].ResourceID = "reports"
+			},
+			expectedFields: []string{"custom_roles[0].permissions[0].resource_id"},
+		},
+		{
+			name: "Unavailable action",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomRoles[0].Permissions[0].Actions = []string{"read", "delete"}
+			},
+			expectedFields: []string{"custom_roles[0].permissions[0].actions[1]"},
+		},
+		{
+			name: "Unavailable action on Stytch default role",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.StytchAdmin.Permissions = []rbacpolicy.Permission{{ResourceID: "documents", Actions: []string{"purge"}}}
+			},
+			expectedFields: []string{"stytch_admin.permissions[0].actions[0]"},
+		},
+		{
+			name: "Duplicate role ID",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomRoles = append(p.CustomRoles, rbacpolicy.Role{RoleID: "editor"})
+			},
+			expectedFields: []string{"custom_roles[1].role_id"},
+		},
+		{
+			name: "Duplicate resource ID",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomResources = append(p.CustomResources, rbacpolicy.Resource{ResourceID: "documents"})
+			},
+			expectedFields: []string{"custom_resources[1].resource_id"},
+		},
+		{
+			name: "Custom resource shadows Stytch resource",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomResources = append(p.CustomResources, rbacpolicy.Resource{ResourceID: "stytch.self"})
+			},
+			expectedFields: []string{"custom_resources[1].resource_id"},
+		},
+		{
+			name: "Empty IDs",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomRoles = append(p.CustomRoles, rbacpolicy.Role{
+					RoleID:      " ",
+					Permissions: []rbacpolicy.Permission{{ResourceID: ""}},
+				})
+				p.CustomResources = append(p.CustomResources, rbacpolicy.Resource{})
+			},
+			expectedFields: []string{
+				"custom_resources[1].resource_id",
+				"custom_roles[1].role_id",
+				"custom_roles[1].permissions[0].resource_id",
+			},
+		},
+		{
+			name: "Reserved prefix",
+			mutate: func(p *rbacpolicy.Policy) {
+				p.CustomRoles[0].RoleID = "stytch_editor"
+				p.CustomResources = append(p.CustomResources, rbacpolicy.Resource{ResourceID: "stytch_documents"})
+			},
+			expectedFields: []string{
+				"custom_resources[1].resource_id",
+				"custom_roles[0].role_id",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := validPolicy()
+			tt.mutate(&policy)
+
+			violations := Validate(policy)
+
+			if len(violations) != len(tt.expectedFields) {
+				t.Fatalf("Expected %d violations, got %d: %v", len(tt.expectedFields), len(violations), violations)
+			}
+			for i, field := range tt.expectedFields {
+				if violations[i].Field != field {
+					t.Errorf("Violation %d field = %q, want %q", i, violations[i].Field, field)
+				}
+				if violations[i].Message == "" {
+					t.Errorf("Violation %d has empty message", i)
+				}
+			}
+		})
+	}
+}
+
+func TestViolationsError(t *testing.T) {
+	v := Violations{
+		{Field: "custom_roles[0].role_id", Message: "must not be empty"},
+		{Field: "custom_resources[0].resource_id", Message: "must not be empty"},
+	}
+
+	want := "custom_roles[0].role_id: must not be empty; custom_resources[0].resource_id: must not be empty"
+	if v.Error() != want {
+		t.Errorf("Error() = %q, want %q", v.Error(), want)
+	}
+}